@@ -0,0 +1,495 @@
+package channel
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
+	log "github.com/sirupsen/logrus"
+	yaml "gopkg.in/yaml.v2"
+)
+
+const (
+	archiveIndexFile          = "channels.yaml"
+	archiveIndexSignatureFile = "channels.yaml.sig"
+	archivePrefix             = "archive_"
+	archiveTimeout            = 5 * time.Minute
+)
+
+var (
+	archiveVersionRE = regexp.MustCompile(`^[\w][\w.-]*$`)
+
+	// maxExtractedSize limits the total size of the files unpacked from an
+	// archive.
+	maxExtractedSize int64 = 1 << 30
+
+	// maxIndexSize limits the size of the index and its signature.
+	maxIndexSize int64 = 10 << 20
+)
+
+// archiveStore fetches the files of an archive based channel source.
+type archiveStore interface {
+	// fetch returns the contents of the file with the provided name.
+	fetch(ctx context.Context, name string) (io.ReadCloser, error)
+}
+
+// ArchiveIndex lists the channels of an archive based channel source and the
+// checksums of the archives of their versions.
+type ArchiveIndex struct {
+	// Channels maps the channel names to versions.
+	Channels map[string]ConfigVersion `yaml:"channels"`
+	// Versions are the available versions.
+	Versions map[ConfigVersion]*ArchiveVersion `yaml:"versions"`
+}
+
+// ArchiveVersion is a version of the configuration, stored as
+// <version>.tar.gz next to the index.
+type ArchiveVersion struct {
+	// SHA256 is the hex encoded SHA-256 checksum of the archive.
+	SHA256 string `yaml:"sha256"`
+}
+
+// parseArchiveIndex parses a YAML encoded archive index.
+func parseArchiveIndex(data []byte) (*ArchiveIndex, error) {
+	var index ArchiveIndex
+	err := yaml.Unmarshal(data, &index)
+	if err != nil {
+		return nil, err
+	}
+
+	for version, archive := range index.Versions {
+		if !archiveVersionRE.MatchString(string(version)) {
+			return nil, fmt.Errorf("invalid version: %s", version)
+		}
+		if archive == nil || len(archive.SHA256) != sha256.Size*2 {
+			return nil, fmt.Errorf("invalid checksum of version %s", version)
+		}
+	}
+
+	for channel, version := range index.Channels {
+		if _, ok := index.Versions[version]; !ok {
+			return nil, fmt.Errorf("channel %s points to unknown version %s", channel, version)
+		}
+	}
+
+	return &index, nil
+}
+
+// Version returns the version of the channel. Versions listed in the index
+// can be used as channels as well.
+func (index *ArchiveIndex) Version(channel string) (ConfigVersion, error) {
+	if version, ok := index.Channels[channel]; ok {
+		return version, nil
+	}
+	if _, ok := index.Versions[ConfigVersion(channel)]; ok {
+		return ConfigVersion(channel), nil
+	}
+	return "", fmt.Errorf("unknown channel: %s", channel)
+}
+
+// Archive defines a channel source where the versions of the configuration
+// are stored as tarballs, e.g. in an artifact store or an S3 bucket.
+type Archive struct {
+	ctx       context.Context
+	workdir   string
+	store     archiveStore
+	checkouts *checkoutCache
+
+	// verifies the signature of the index, nil if it's not signed
+	verifier *signatureVerifier
+
+	// the index of the last update
+	index *ArchiveIndex
+	mutex *sync.Mutex
+}
+
+// ArchiveOptions configures the access to archive based channel sources.
+type ArchiveOptions struct {
+	// S3Client is used for s3:// URLs.
+	S3Client s3iface.S3API
+	// HTTPClient is used for http(s):// URLs instead of a client with the
+	// default timeout, e.g. to trust additional certificates.
+	HTTPClient *http.Client
+	// Verification configures the keys trusted to sign the index. The
+	// index isn't verified if no keys are configured.
+	Verification *SignatureVerification
+}
+
+// NewArchive initializes a new archive based ChannelSource and removes the
+// checkouts left behind in workdir by previous runs. Supported are
+// http(s)://<url> and s3://<bucket>/<prefix>, which are expected to contain
+// the index channels.yaml and the <version>.tar.gz archives. The archives are
+// authenticated by the checksums in the index, which in turn is authenticated
+// by the transport or, if trusted keys are configured, by its detached
+// signature channels.yaml.sig. Plain http:// is therefore only allowed with
+// trusted keys. Downloads are aborted once ctx is canceled.
+func NewArchive(ctx context.Context, workdir, uri string, options *ArchiveOptions) (ConfigSource, error) {
+	absWorkdir, err := filepath.Abs(workdir)
+	if err != nil {
+		return nil, err
+	}
+
+	url, err := url.Parse(uri)
+	if err != nil {
+		return nil, err
+	}
+
+	var verifier *signatureVerifier
+	if options != nil && options.Verification.Enabled() {
+		verifier = newSignatureVerifier(options.Verification)
+	}
+
+	var store archiveStore
+	switch url.Scheme {
+	case "http", "https":
+		if url.Scheme == "http" && verifier == nil {
+			return nil, fmt.Errorf("refusing unauthenticated archive source %s, use https:// or configure trusted keys", uri)
+		}
+		client := &http.Client{Timeout: archiveTimeout}
+		if options != nil && options.HTTPClient != nil {
+			client = options.HTTPClient
+		}
+		store = &httpArchiveStore{
+			baseURL: strings.TrimSuffix(url.String(), "/"),
+			client:  client,
+		}
+	case "s3":
+		if options == nil || options.S3Client == nil {
+			return nil, fmt.Errorf("no S3 client configured for %s", uri)
+		}
+		store = &s3ArchiveStore{
+			client: options.S3Client,
+			bucket: url.Host,
+			prefix: strings.Trim(url.Path, "/"),
+		}
+	default:
+		return nil, fmt.Errorf("unknown archive source type: %v", url.Scheme)
+	}
+
+	err = collectGarbage(absWorkdir, archivePrefix)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Archive{
+		ctx:       ctx,
+		workdir:   absWorkdir,
+		store:     store,
+		checkouts: newCheckoutCache(),
+		verifier:  verifier,
+		mutex:     &sync.Mutex{},
+	}, nil
+}
+
+// Update fetches the index of the available channels and versions. If
+// trusted keys are configured, indexes which aren't signed by one of them
+// are refused.
+func (a *Archive) Update(logger *log.Entry) (ConfigVersions, error) {
+	data, err := a.fetchAll(archiveIndexFile)
+	if err != nil {
+		return nil, err
+	}
+
+	if a.verifier != nil {
+		err = a.verifyIndex(logger, data)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	index, err := parseArchiveIndex(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid archive index: %v", err)
+	}
+
+	a.mutex.Lock()
+	a.index = index
+	a.mutex.Unlock()
+
+	return index, nil
+}
+
+// verifyIndex checks that the index is signed by a trusted key. The keys are
+// loaded again every time, so that removed keys are no longer trusted.
+func (a *Archive) verifyIndex(logger *log.Entry, data []byte) error {
+	err := a.verifier.prepare(logger)
+	if err != nil {
+		return err
+	}
+
+	signature, err := a.fetchAll(archiveIndexSignatureFile)
+	if err != nil {
+		return fmt.Errorf("unable to fetch the signature of the archive index: %v", err)
+	}
+
+	signer, err := a.verifier.verifySignature(sshFileNamespace, string(signature), data)
+	if err != nil {
+		return fmt.Errorf("archive index failed signature verification: %v", err)
+	}
+
+	logger.Debugf("Archive index has a valid signature by %s", signer)
+	return nil
+}
+
+// fetchAll returns the contents of a small file like the index.
+func (a *Archive) fetchAll(name string) ([]byte, error) {
+	body, err := a.store.fetch(a.ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+
+	data, err := ioutil.ReadAll(io.LimitReader(body, maxIndexSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > maxIndexSize {
+		return nil, fmt.Errorf("%s exceeds the maximum size of %d bytes", name, maxIndexSize)
+	}
+	return data, nil
+}
+
+// Get downloads and unpacks the archive of the specified version. The
+// checkout is shared by everyone getting the same version, so it must not be
+// modified. Archives whose checksum doesn't match the index are refused.
+func (a *Archive) Get(logger *log.Entry, version ConfigVersion) (*Config, error) {
+	a.mutex.Lock()
+	index := a.index
+	a.mutex.Unlock()
+
+	if index == nil || index.Versions[version] == nil {
+		return nil, fmt.Errorf("unknown version: %s", version)
+	}
+	checksum := index.Versions[version].SHA256
+
+	dir := path.Join(a.workdir, archivePrefix+string(version))
+	err := a.checkouts.acquire(dir, func() error {
+		return a.unpack(version, checksum, dir)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("unable to check out %s: %v", version, err)
+	}
+
+	return &Config{
+		Path: dir,
+	}, nil
+}
+
+// Delete releases the checkout specified by the config Path. It's deleted
+// once it's no longer used.
+func (a *Archive) Delete(logger *log.Entry, config *Config) error {
+	return a.checkouts.release(config.Path)
+}
+
+// unpack downloads the archive of the version to a temporary file, verifies
+// its checksum and unpacks it to dir.
+func (a *Archive) unpack(version ConfigVersion, checksum string, dir string) error {
+	body, err := a.store.fetch(a.ctx, string(version)+".tar.gz")
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+
+	err = os.MkdirAll(a.workdir, 0755)
+	if err != nil {
+		return err
+	}
+
+	// the download is removed by the garbage collection if we crash
+	download, err := ioutil.TempFile(a.workdir, archivePrefix+"download_")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(download.Name())
+	defer download.Close()
+
+	hash := sha256.New()
+	_, err = io.Copy(io.MultiWriter(download, hash), body)
+	if err != nil {
+		return err
+	}
+
+	actual := hex.EncodeToString(hash.Sum(nil))
+	if !strings.EqualFold(actual, checksum) {
+		return fmt.Errorf("checksum mismatch, expected %s, got %s", checksum, actual)
+	}
+
+	_, err = download.Seek(0, io.SeekStart)
+	if err != nil {
+		return err
+	}
+
+	err = extractTarball(a.ctx, download, dir)
+	if err != nil {
+		os.RemoveAll(dir)
+		return err
+	}
+	return nil
+}
+
+// extractTarball unpacks a gzip compressed tarball to dir. Entries and
+// symlinks pointing outside of dir are refused, as are tarballs whose files
+// exceed maxExtractedSize in total.
+func extractTarball(ctx context.Context, r io.Reader, dir string) error {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return err
+	}
+	defer gz.Close()
+
+	err = os.MkdirAll(dir, 0755)
+	if err != nil {
+		return err
+	}
+
+	// symlinks are only created once all files are written, so that no file
+	// is written through a symlink
+	var symlinks []*tar.Header
+
+	// the reader of an entry returns exactly the size of its header
+	var extracted int64
+
+	archive := tar.NewReader(gz)
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		header, err := archive.Next()
+		if err == io.EOF {
+			return createSymlinks(dir, symlinks)
+		}
+		if err != nil {
+			return err
+		}
+
+		target := filepath.Join(dir, header.Name)
+		if !within(dir, target) {
+			return fmt.Errorf("invalid file name: %s", header.Name)
+		}
+
+		switch header.Typeflag {
+		case tar.TypeDir:
+			err = os.MkdirAll(target, 0755)
+		case tar.TypeReg, tar.TypeRegA:
+			extracted += header.Size
+			if header.Size < 0 || extracted > maxExtractedSize {
+				return fmt.Errorf("archive exceeds the maximum size of %d bytes", maxExtractedSize)
+			}
+			err = writeFile(archive, target, os.FileMode(header.Mode)&0755|0644)
+		case tar.TypeSymlink:
+			symlinks = append(symlinks, header)
+		default:
+			// e.g. the global headers written by git archive
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// createSymlinks creates the symlinks of a tarball unpacked to dir. Symlinks
+// in symlinked directories and ones resolving to a path outside of dir are
+// refused.
+func createSymlinks(dir string, symlinks []*tar.Header) error {
+	names := make(map[string]bool, len(symlinks))
+	for _, header := range symlinks {
+		names[filepath.Clean(header.Name)] = true
+	}
+
+	for _, header := range symlinks {
+		for parent := filepath.Dir(filepath.Clean(header.Name)); parent != "."; parent = filepath.Dir(parent) {
+			if names[parent] {
+				return fmt.Errorf("invalid symlink %s in symlinked directory %s", header.Name, parent)
+			}
+		}
+
+		target := filepath.Join(dir, header.Name)
+		err := os.MkdirAll(filepath.Dir(target), 0755)
+		if err != nil {
+			return err
+		}
+		err = os.Symlink(header.Linkname, target)
+		if err != nil {
+			return err
+		}
+	}
+
+	realDir, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		return err
+	}
+	for _, header := range symlinks {
+		resolved, err := filepath.EvalSymlinks(filepath.Join(dir, header.Name))
+		if err != nil || !within(realDir, resolved) {
+			return fmt.Errorf("invalid symlink %s to %s", header.Name, header.Linkname)
+		}
+	}
+	return nil
+}
+
+// httpArchiveStore fetches the files from an HTTP server.
+type httpArchiveStore struct {
+	baseURL string
+	client  *http.Client
+}
+
+func (s *httpArchiveStore) fetch(ctx context.Context, name string) (io.ReadCloser, error) {
+	fileURL := s.baseURL + "/" + name
+
+	req, err := http.NewRequest(http.MethodGet, fileURL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := s.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("unable to fetch %s: %s", fileURL, resp.Status)
+	}
+	return resp.Body, nil
+}
+
+// s3ArchiveStore fetches the files from an S3 bucket.
+type s3ArchiveStore struct {
+	client s3iface.S3API
+	bucket string
+	prefix string
+}
+
+func (s *s3ArchiveStore) fetch(ctx context.Context, name string) (io.ReadCloser, error) {
+	key := name
+	if s.prefix != "" {
+		key = s.prefix + "/" + name
+	}
+
+	output, err := s.client.GetObjectWithContext(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("unable to fetch s3://%s/%s: %v", s.bucket, key, err)
+	}
+	return output.Body, nil
+}
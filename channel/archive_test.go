@@ -0,0 +1,430 @@
+package channel
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+)
+
+// testTarball builds a gzip compressed tarball from the headers. The
+// contents of regular files are their names.
+func testTarball(t *testing.T, headers ...*tar.Header) []byte {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	archive := tar.NewWriter(gz)
+
+	for _, header := range headers {
+		if header.Typeflag == tar.TypeReg {
+			header.Size = int64(len(header.Name))
+		}
+		require.NoError(t, archive.WriteHeader(header))
+		if header.Typeflag == tar.TypeReg {
+			_, err := archive.Write([]byte(header.Name))
+			require.NoError(t, err)
+		}
+	}
+
+	require.NoError(t, archive.Close())
+	require.NoError(t, gz.Close())
+	return buf.Bytes()
+}
+
+func checksum(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// testArchiveFiles returns the index and archives of a source with the
+// channels stable and beta.
+func testArchiveFiles(t *testing.T) map[string][]byte {
+	stable := testTarball(t,
+		&tar.Header{Name: "cluster/", Typeflag: tar.TypeDir, Mode: 0755},
+		&tar.Header{Name: "cluster/config.yaml", Typeflag: tar.TypeReg, Mode: 0644},
+		&tar.Header{Name: "cluster/provision.sh", Typeflag: tar.TypeReg, Mode: 0755},
+		&tar.Header{Name: "manifests/defaults.yaml", Typeflag: tar.TypeReg, Mode: 0644},
+		&tar.Header{Name: "config.yaml", Typeflag: tar.TypeSymlink, Linkname: "cluster/config.yaml"},
+	)
+	beta := testTarball(t,
+		&tar.Header{Name: "beta", Typeflag: tar.TypeReg, Mode: 0644},
+	)
+
+	return map[string][]byte{
+		"channels.yaml": []byte(fmt.Sprintf(`
+channels:
+  stable: "1.0.0"
+  beta: "1.1.0"
+versions:
+  "1.0.0":
+    sha256: %s
+  "1.1.0":
+    sha256: %s
+  "0.9.0":
+    sha256: %s
+`, checksum(stable), checksum(beta), strings.Repeat("0", 64))),
+		"1.0.0.tar.gz": stable,
+		"1.1.0.tar.gz": beta,
+		// doesn't match the checksum
+		"0.9.0.tar.gz": beta,
+	}
+}
+
+func archiveServer(files map[string][]byte) *httptest.Server {
+	return httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, ok := files[strings.TrimPrefix(r.URL.Path, "/channels/")]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write(data)
+	}))
+}
+
+func TestArchive(t *testing.T) {
+	logger := log.StandardLogger().WithFields(map[string]interface{}{})
+
+	server := archiveServer(testArchiveFiles(t))
+	defer server.Close()
+
+	workdir := "workdir_archive_test"
+	defer os.RemoveAll(workdir)
+
+	source, err := NewArchive(context.Background(), workdir, server.URL+"/channels/", &ArchiveOptions{HTTPClient: server.Client()})
+	require.NoError(t, err)
+
+	versions, err := source.Update(logger)
+	require.NoError(t, err)
+
+	version, err := versions.Version("stable")
+	require.NoError(t, err)
+	require.EqualValues(t, "1.0.0", version)
+
+	// versions can be pinned
+	version, err = versions.Version("0.9.0")
+	require.NoError(t, err)
+	require.EqualValues(t, "0.9.0", version)
+
+	_, err = versions.Version("unknown")
+	require.Error(t, err)
+
+	stable := checkout(t, logger, source, versions, "stable")
+	requireFile(t, stable, "cluster/config.yaml")
+	requireFile(t, stable, "manifests/defaults.yaml")
+
+	data, err := ioutil.ReadFile(path.Join(stable, "config.yaml"))
+	require.NoError(t, err)
+	require.Equal(t, "cluster/config.yaml", string(data))
+
+	info, err := os.Stat(path.Join(stable, "cluster/provision.sh"))
+	require.NoError(t, err)
+	require.EqualValues(t, 0755, info.Mode().Perm())
+
+	// checkouts are shared
+	config, err := source.Get(logger, "1.0.0")
+	require.NoError(t, err)
+	require.Equal(t, stable, config.Path)
+	require.NoError(t, source.Delete(logger, config))
+	requireFile(t, stable, "cluster/config.yaml")
+	require.NoError(t, source.Delete(logger, config))
+	requireNoFile(t, stable, "cluster/config.yaml")
+
+	beta := checkout(t, logger, source, versions, "beta")
+	requireFile(t, beta, "beta")
+	requireNoFile(t, beta, "cluster/config.yaml")
+
+	// archives not matching the checksum are refused
+	_, err = source.Get(logger, "0.9.0")
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "checksum mismatch")
+
+	_, err = source.Get(logger, "2.0.0")
+	require.Error(t, err)
+
+	// only the checkout of beta is left
+	entries, err := ioutil.ReadDir(workdir)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	require.Equal(t, "archive_1.1.0", entries[0].Name())
+
+	// and removed on the next start
+	_, err = NewArchive(context.Background(), workdir, server.URL+"/channels/", &ArchiveOptions{HTTPClient: server.Client()})
+	require.NoError(t, err)
+	entries, err = ioutil.ReadDir(workdir)
+	require.NoError(t, err)
+	require.Len(t, entries, 0)
+}
+
+func TestArchiveUpdateFailure(t *testing.T) {
+	logger := log.StandardLogger().WithFields(map[string]interface{}{})
+
+	for _, index := range []string{
+		"channels: foo",
+		"channels:\n  stable: 1.0.0\nversions: {}",
+		"versions:\n  ../1.0.0:\n    sha256: " + strings.Repeat("0", 64),
+		"versions:\n  1.0.0:\n    sha256: foo",
+		"versions:\n  1.0.0: {}",
+	} {
+		server := archiveServer(map[string][]byte{"channels.yaml": []byte(index)})
+
+		source, err := NewArchive(context.Background(), "workdir_archive_failure_test", server.URL+"/channels", &ArchiveOptions{HTTPClient: server.Client()})
+		require.NoError(t, err)
+
+		_, err = source.Update(logger)
+		require.Error(t, err, index)
+		server.Close()
+	}
+
+	server := archiveServer(nil)
+	defer server.Close()
+
+	source, err := NewArchive(context.Background(), "workdir_archive_failure_test", server.URL+"/channels", &ArchiveOptions{HTTPClient: server.Client()})
+	require.NoError(t, err)
+
+	_, err = source.Update(logger)
+	require.Error(t, err)
+
+	// indexes exceeding the maximum size are refused
+	defer func(size int64) { maxIndexSize = size }(maxIndexSize)
+	maxIndexSize = 16
+
+	large := archiveServer(map[string][]byte{"channels.yaml": []byte("channels:\n  stable: 1.0.0\n")})
+	defer large.Close()
+
+	source, err = NewArchive(context.Background(), "workdir_archive_failure_test", large.URL+"/channels", &ArchiveOptions{HTTPClient: large.Client()})
+	require.NoError(t, err)
+
+	_, err = source.Update(logger)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "maximum size")
+}
+
+func TestExtractTarballInvalid(t *testing.T) {
+	dir, err := ioutil.TempDir("", "clm-archive")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	for _, tc := range []struct {
+		msg     string
+		headers []*tar.Header
+	}{
+		{
+			msg: "file outside of the directory",
+			headers: []*tar.Header{
+				{Name: "../evil", Typeflag: tar.TypeReg, Mode: 0644},
+			},
+		},
+		{
+			msg: "absolute symlink",
+			headers: []*tar.Header{
+				{Name: "passwd", Typeflag: tar.TypeSymlink, Linkname: "/etc/passwd"},
+			},
+		},
+		{
+			msg: "symlink outside of the directory",
+			headers: []*tar.Header{
+				{Name: "parent", Typeflag: tar.TypeSymlink, Linkname: "../.."},
+			},
+		},
+		{
+			msg: "symlink through a symlink",
+			headers: []*tar.Header{
+				{Name: "self", Typeflag: tar.TypeSymlink, Linkname: "."},
+				{Name: "self/parent", Typeflag: tar.TypeSymlink, Linkname: ".."},
+			},
+		},
+		{
+			msg: "file in a symlinked directory",
+			headers: []*tar.Header{
+				{Name: "link", Typeflag: tar.TypeSymlink, Linkname: "."},
+				{Name: "link/file", Typeflag: tar.TypeReg, Mode: 0644},
+			},
+		},
+	} {
+		t.Run(tc.msg, func(t *testing.T) {
+			target := path.Join(dir, "checkout")
+			defer os.RemoveAll(target)
+
+			err := extractTarball(context.Background(), bytes.NewReader(testTarball(t, tc.headers...)), target)
+			require.Error(t, err)
+		})
+	}
+}
+
+type mockS3API struct {
+	s3iface.S3API
+	bucket string
+	files  map[string][]byte
+}
+
+func (m *mockS3API) GetObjectWithContext(ctx aws.Context, input *s3.GetObjectInput, opts ...request.Option) (*s3.GetObjectOutput, error) {
+	data, ok := m.files[aws.StringValue(input.Key)]
+	if !ok || aws.StringValue(input.Bucket) != m.bucket {
+		return nil, awserr.New(s3.ErrCodeNoSuchKey, "The specified key does not exist.", nil)
+	}
+	return &s3.GetObjectOutput{Body: ioutil.NopCloser(bytes.NewReader(data))}, nil
+}
+
+func TestArchiveS3(t *testing.T) {
+	logger := log.StandardLogger().WithFields(map[string]interface{}{})
+
+	files := make(map[string][]byte)
+	for name, data := range testArchiveFiles(t) {
+		files["prefix/"+name] = data
+	}
+
+	workdir := "workdir_archive_s3_test"
+	defer os.RemoveAll(workdir)
+
+	_, err := NewArchive(context.Background(), workdir, "s3://bucket/prefix", nil)
+	require.Error(t, err)
+
+	source, err := NewArchive(context.Background(), workdir, "s3://bucket/prefix", &ArchiveOptions{
+		S3Client: &mockS3API{bucket: "bucket", files: files},
+	})
+	require.NoError(t, err)
+
+	versions, err := source.Update(logger)
+	require.NoError(t, err)
+
+	stable := checkout(t, logger, source, versions, "stable")
+	requireFile(t, stable, "cluster/config.yaml")
+}
+
+func TestNewArchiveUnknownType(t *testing.T) {
+	_, err := NewArchive(context.Background(), "workdir_archive_test", "ftp://example.org/channels", nil)
+	require.Error(t, err)
+}
+
+func TestNewArchiveUnauthenticated(t *testing.T) {
+	workdir := "workdir_archive_test"
+	defer os.RemoveAll(workdir)
+
+	_, err := NewArchive(context.Background(), workdir, "http://example.org/channels", nil)
+	require.Error(t, err)
+
+	// the index is authenticated by its signature instead
+	_, err = NewArchive(context.Background(), workdir, "http://example.org/channels", &ArchiveOptions{
+		Verification: &SignatureVerification{GPGKeyring: "keyring.asc"},
+	})
+	require.NoError(t, err)
+}
+
+func TestArchiveSignedIndex(t *testing.T) {
+	logger := log.StandardLogger().WithFields(map[string]interface{}{})
+
+	repo := newSigningRepo(t, logger)
+	defer os.RemoveAll(repo.keysDir)
+
+	files := testArchiveFiles(t)
+	index := path.Join(repo.keysDir, "channels.yaml")
+	require.NoError(t, ioutil.WriteFile(index, files["channels.yaml"], 0644))
+
+	repo.gpg("--armor", "--detach-sign", "--output", index+".asc", index)
+	gpgSignature, err := ioutil.ReadFile(index + ".asc")
+	require.NoError(t, err)
+
+	repo.run(exec.Command("ssh-keygen", "-Y", "sign", "-n", "file", "-f", repo.sshKey, index))
+	sshSignature, err := ioutil.ReadFile(index + ".sig")
+	require.NoError(t, err)
+
+	require.NoError(t, os.Remove(index+".sig"))
+	repo.run(exec.Command("ssh-keygen", "-Y", "sign", "-n", "git", "-f", repo.sshKey, index))
+	gitSignature, err := ioutil.ReadFile(index + ".sig")
+	require.NoError(t, err)
+
+	workdir := "workdir_archive_signed_test"
+	defer os.RemoveAll(workdir)
+
+	verification := &SignatureVerification{
+		GPGKeyring:        repo.gpgKeyring(),
+		SSHAllowedSigners: repo.allowedSigners(""),
+	}
+
+	for _, tc := range []struct {
+		msg       string
+		signature []byte
+		index     []byte
+		valid     bool
+	}{
+		{msg: "GPG signature", signature: gpgSignature, valid: true},
+		{msg: "SSH signature", signature: sshSignature, valid: true},
+		{msg: "no signature"},
+		{msg: "SSH signature in another namespace", signature: gitSignature},
+		{msg: "modified index", signature: sshSignature, index: append(files["channels.yaml"], "# modified\n"...)},
+	} {
+		t.Run(tc.msg, func(t *testing.T) {
+			served := make(map[string][]byte)
+			for name, data := range files {
+				served[name] = data
+			}
+			if tc.signature != nil {
+				served["channels.yaml.sig"] = tc.signature
+			}
+			if tc.index != nil {
+				served["channels.yaml"] = tc.index
+			}
+
+			server := archiveServer(served)
+			defer server.Close()
+
+			source, err := NewArchive(context.Background(), workdir, server.URL+"/channels/", &ArchiveOptions{
+				HTTPClient:   server.Client(),
+				Verification: verification,
+			})
+			require.NoError(t, err)
+
+			versions, err := source.Update(logger)
+			if !tc.valid {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+
+			stable := checkout(t, logger, source, versions, "stable")
+			requireFile(t, stable, "cluster/config.yaml")
+		})
+	}
+}
+
+func TestExtractTarballMaxSize(t *testing.T) {
+	defer func(size int64) { maxExtractedSize = size }(maxExtractedSize)
+	maxExtractedSize = int64(len("a") + len("b"))
+
+	dir, err := ioutil.TempDir("", "clm-extract")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	tarball := testTarball(t,
+		&tar.Header{Name: "a", Typeflag: tar.TypeReg, Mode: 0644},
+		&tar.Header{Name: "b", Typeflag: tar.TypeReg, Mode: 0644},
+	)
+	require.NoError(t, extractTarball(context.Background(), bytes.NewReader(tarball), path.Join(dir, "fits")))
+
+	tarball = testTarball(t,
+		&tar.Header{Name: "a", Typeflag: tar.TypeReg, Mode: 0644},
+		&tar.Header{Name: "b", Typeflag: tar.TypeReg, Mode: 0644},
+		&tar.Header{Name: "c", Typeflag: tar.TypeReg, Mode: 0644},
+	)
+	err = extractTarball(context.Background(), bytes.NewReader(tarball), path.Join(dir, "too-large"))
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "maximum size")
+}
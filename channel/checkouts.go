@@ -0,0 +1,137 @@
+package channel
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// checkoutCache keeps track of the checkouts of a config source. Checkouts
+// are named after the version and shared, so that concurrent updates with
+// the same version don't each need their own copy.
+type checkoutCache struct {
+	mutex *sync.Mutex
+	// checkouts in use, by path
+	checkouts map[string]*sharedCheckout
+}
+
+// sharedCheckout is the checkout of a version, shared by everyone using the
+// version and removed once the last one is done with it.
+type sharedCheckout struct {
+	// held while the checkout is created
+	mutex sync.Mutex
+	ready bool
+	refs  int
+}
+
+func newCheckoutCache() *checkoutCache {
+	return &checkoutCache{
+		mutex:     &sync.Mutex{},
+		checkouts: make(map[string]*sharedCheckout),
+	}
+}
+
+// acquire takes a reference to the checkout in dir, calling create to
+// create it if it doesn't exist yet.
+func (c *checkoutCache) acquire(dir string, create func() error) error {
+	c.mutex.Lock()
+	checkout, ok := c.checkouts[dir]
+	if !ok {
+		checkout = &sharedCheckout{}
+		c.checkouts[dir] = checkout
+	}
+	checkout.refs++
+	c.mutex.Unlock()
+
+	checkout.mutex.Lock()
+	defer checkout.mutex.Unlock()
+
+	if !checkout.ready {
+		// remove the leftovers of a failed checkout
+		err := os.RemoveAll(dir)
+		if err == nil {
+			err = create()
+		}
+		if err != nil {
+			c.release(dir)
+			return err
+		}
+		checkout.ready = true
+	}
+	return nil
+}
+
+// release releases a reference to the checkout in dir and removes it once
+// it isn't referenced anymore.
+func (c *checkoutCache) release(dir string) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	checkout, ok := c.checkouts[dir]
+	if ok {
+		checkout.refs--
+		if checkout.refs > 0 {
+			return nil
+		}
+		delete(c.checkouts, dir)
+	}
+	return os.RemoveAll(dir)
+}
+
+// collectGarbage removes the checkouts left behind in workdir by previous
+// runs, e.g. after a crash, which are all the files and directories whose
+// name starts with prefix. Must only be called before any checkouts are
+// made.
+func collectGarbage(workdir, prefix string) error {
+	entries, err := ioutil.ReadDir(workdir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	for _, entry := range entries {
+		if !strings.HasPrefix(entry.Name(), prefix) {
+			continue
+		}
+
+		log.Infof("Removing stale checkout %s", entry.Name())
+		err := os.RemoveAll(path.Join(workdir, entry.Name()))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// within returns true if path is dir or in dir.
+func within(dir, path string) bool {
+	return path == dir || strings.HasPrefix(path, dir+string(filepath.Separator))
+}
+
+// writeFile writes the contents of r to target.
+func writeFile(r io.Reader, target string, mode os.FileMode) error {
+	err := os.MkdirAll(filepath.Dir(target), 0755)
+	if err != nil {
+		return err
+	}
+
+	out, err := os.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_EXCL, mode)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(out, r)
+	if err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
@@ -1,54 +1,172 @@
 package channel
 
 import (
+	"context"
 	"fmt"
 	"os"
-	"os/exec"
 	"path"
+	"path/filepath"
 	"regexp"
+	"sort"
 	"strings"
 	"sync"
-	"time"
-
-	"path/filepath"
 
 	log "github.com/sirupsen/logrus"
-	"github.com/zalando-incubator/cluster-lifecycle-manager/pkg/util/command"
+	"golang.org/x/oauth2"
+	git "gopkg.in/src-d/go-git.v4"
+	"gopkg.in/src-d/go-git.v4/config"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/filemode"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
+	"gopkg.in/src-d/go-git.v4/plumbing/storer"
+	"gopkg.in/src-d/go-git.v4/plumbing/transport"
+	"gopkg.in/src-d/go-git.v4/plumbing/transport/client"
+	githttp "gopkg.in/src-d/go-git.v4/plumbing/transport/http"
+	"gopkg.in/src-d/go-git.v4/plumbing/transport/server"
+	gitssh "gopkg.in/src-d/go-git.v4/plumbing/transport/ssh"
 )
 
+func init() {
+	// serve local repositories in-process instead of running git-upload-pack
+	client.InstallProtocol("file", server.NewClient(localLoader{}))
+}
+
+// localLoader loads local repositories, both bare ones and the .git
+// directory of ones with a working tree.
+type localLoader struct{}
+
+func (localLoader) Load(endpoint *transport.Endpoint) (storer.Storer, error) {
+	result, err := server.DefaultLoader.Load(endpoint)
+	if err != transport.ErrRepositoryNotFound {
+		return result, err
+	}
+
+	dotGit := *endpoint
+	dotGit.Path = path.Join(endpoint.Path, ".git")
+	return server.DefaultLoader.Load(&dotGit)
+}
+
 // Git defines a channel source where the channels are stored in a git
 // repository.
 type Git struct {
-	workdir           string
-	repositoryURL     string
-	repoName          string
-	repoDir           string
-	sshPrivateKeyFile string
-	mutex             *sync.Mutex
+	ctx           context.Context
+	workdir       string
+	repositoryURL string
+	repoName      string
+	repoDir       string
+	auth          *GitAuth
+	mutex         *sync.Mutex
+	checkouts     *checkoutCache
+
+	// verifies the signatures of the checked out versions, disabled if nil
+	verifier *signatureVerifier
 }
 
+// GitVersions are the versions of the channels in a git repository. A
+// channel is either a branch, a tag, a range of tags named after semantic
+// versions (e.g. ~1.12 for the newest 1.12.x tag) or a commit SHA.
 type GitVersions struct {
 	branches map[string]ConfigVersion
+	tags     map[string]ConfigVersion
+
+	// tags named after semantic versions, newest first
+	releases []*release
+}
+
+// release is a tag named after a semantic version.
+type release struct {
+	tag     string
+	version *semanticVersion
+	commit  ConfigVersion
+}
+
+// GitAuth configures the authentication with the remote repository.
+type GitAuth struct {
+	// SSHPrivateKeyFile is the path to the private key used for SSH
+	// repository URLs.
+	SSHPrivateKeyFile string
+	// SSHKnownHostsFile is the path to the known_hosts file used to verify
+	// the host key of the server. Defaults to $SSH_KNOWN_HOSTS,
+	// ~/.ssh/known_hosts and /etc/ssh/ssh_known_hosts.
+	SSHKnownHostsFile string
+	// TokenSource provides the token used for HTTPS repository URLs.
+	TokenSource oauth2.TokenSource
+	// TokenUsername is sent along with the token.
+	TokenUsername string
+}
+
+// mirrorRefSpecs fetch the branches and tags of the remote repository into
+// the local mirror.
+var mirrorRefSpecs = []config.RefSpec{
+	"+refs/heads/*:refs/heads/*",
+	"+refs/tags/*:refs/tags/*",
 }
 
 var gitSha = regexp.MustCompile("^[a-f0-9]{40}$")
 
 func NewGitVersions(branches map[string]ConfigVersion) *GitVersions {
-	return &GitVersions{branches: branches}
+	return NewGitVersionsWithTags(branches, nil)
+}
+
+// NewGitVersionsWithTags initializes the versions of the channels from the
+// commits of the branches and tags of a repository.
+func NewGitVersionsWithTags(branches map[string]ConfigVersion, tags map[string]ConfigVersion) *GitVersions {
+	var releases []*release
+	for tag, commit := range tags {
+		version, err := parseSemanticVersion(tag)
+		if err != nil {
+			continue
+		}
+		releases = append(releases, &release{tag: tag, version: version, commit: commit})
+	}
+	// tags of the same version, e.g. 1.12.3 and v1.12.3, are ordered by
+	// name, so that ranges resolve to the same one every time
+	sort.SliceStable(releases, func(i, j int) bool {
+		if releases[i].version.equal(releases[j].version) {
+			return releases[i].tag < releases[j].tag
+		}
+		return releases[j].version.less(releases[i].version)
+	})
+
+	return &GitVersions{branches: branches, tags: tags, releases: releases}
 }
 
 func (versions *GitVersions) Version(channel string) (ConfigVersion, error) {
 	if version, ok := versions.branches[channel]; ok {
 		return version, nil
 	}
+	if version, ok := versions.tags[channel]; ok {
+		return version, nil
+	}
+	if strings.HasPrefix(channel, "~") || strings.HasPrefix(channel, "^") {
+		return versions.newestRelease(channel)
+	}
 	if gitSha.MatchString(channel) {
 		return ConfigVersion(channel), nil
 	}
 	return "", fmt.Errorf("unknown channel: %s", channel)
 }
 
-// NewGit initializes a new git based ChannelSource.
-func NewGit(workdir, repositoryURL, sshPrivateKeyFile string) (ConfigSource, error) {
+// newestRelease returns the commit of the newest tag in the version range.
+func (versions *GitVersions) newestRelease(versionRange string) (ConfigVersion, error) {
+	r, err := parseVersionRange(versionRange)
+	if err != nil {
+		return "", err
+	}
+
+	for _, release := range versions.releases {
+		if r.contains(release.version) {
+			return release.commit, nil
+		}
+	}
+	return "", fmt.Errorf("no release matching channel: %s", versionRange)
+}
+
+// NewGit initializes a new git based ChannelSource and removes the checkouts
+// left behind in workdir by previous runs. Fetches and checkouts are aborted
+// once ctx is canceled. If verification is enabled, only versions signed by
+// one of the trusted keys can be checked out.
+func NewGit(ctx context.Context, workdir, repositoryURL string, auth *GitAuth, verification *SignatureVerification) (ConfigSource, error) {
 	absWorkdir, err := filepath.Abs(workdir)
 	if err != nil {
 		return nil, err
@@ -60,14 +178,29 @@ func NewGit(workdir, repositoryURL, sshPrivateKeyFile string) (ConfigSource, err
 		return nil, err
 	}
 
-	return &Git{
-		workdir:           absWorkdir,
-		repoName:          repoName,
-		repositoryURL:     repositoryURL,
-		repoDir:           path.Join(absWorkdir, repoName),
-		sshPrivateKeyFile: sshPrivateKeyFile,
-		mutex:             &sync.Mutex{},
-	}, nil
+	if auth == nil {
+		auth = &GitAuth{}
+	}
+
+	result := &Git{
+		ctx:           ctx,
+		workdir:       absWorkdir,
+		repoName:      repoName,
+		repositoryURL: repositoryURL,
+		repoDir:       path.Join(absWorkdir, repoName),
+		auth:          auth,
+		mutex:         &sync.Mutex{},
+		checkouts:     newCheckoutCache(),
+	}
+	if verification.Enabled() {
+		result.verifier = newSignatureVerifier(verification)
+	}
+
+	err = collectGarbage(absWorkdir, repoName+"_")
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
 }
 
 var repoNameRE = regexp.MustCompile(`/?([\w-]+)(.git)?$`)
@@ -81,9 +214,33 @@ func getRepoName(repoURI string) (string, error) {
 	return match[1], nil
 }
 
-// Get checks out the specified version from the git repo.
+// Get checks out the specified version from the git repo. The checkout is
+// shared by everyone getting the same version, so it must not be modified.
+// It returns a VerificationError if signature verification is enabled and
+// the version isn't signed by a trusted key.
 func (g *Git) Get(logger *log.Entry, version ConfigVersion) (*Config, error) {
-	repoDir, err := g.localClone(logger, string(version))
+	// the mirror is opened separately for each checkout, since a repository
+	// can't be shared between goroutines
+	repo, err := git.PlainOpen(g.repoDir)
+	if err != nil {
+		return nil, err
+	}
+
+	commit, err := repo.CommitObject(plumbing.NewHash(string(version)))
+	if err != nil {
+		return nil, fmt.Errorf("unable to find version %s: %v", version, err)
+	}
+
+	if g.verifier != nil {
+		g.mutex.Lock()
+		err := g.verifier.verify(logger, commit)
+		g.mutex.Unlock()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	repoDir, err := g.acquireCheckout(commit)
 	if err != nil {
 		return nil, err
 	}
@@ -93,90 +250,284 @@ func (g *Git) Get(logger *log.Entry, version ConfigVersion) (*Config, error) {
 	}, nil
 }
 
-// Delete deletes the underlying git repository checkout specified by the
-// config Path.
+// Delete releases the checkout specified by the config Path. It's deleted
+// once it's no longer used.
 func (g *Git) Delete(logger *log.Entry, config *Config) error {
-	return os.RemoveAll(config.Path)
+	return g.checkouts.release(config.Path)
 }
 
 func (g *Git) Update(logger *log.Entry) (ConfigVersions, error) {
 	g.mutex.Lock()
 	defer g.mutex.Unlock()
 
-	_, err := os.Stat(g.repoDir)
+	repo, err := g.openMirror()
 	if err != nil {
-		if !os.IsNotExist(err) {
-			return nil, err
-		}
+		return nil, err
+	}
+
+	auth, err := g.authMethod()
+	if err != nil {
+		return nil, err
+	}
+
+	err = repo.FetchContext(g.ctx, &git.FetchOptions{
+		RefSpecs: mirrorRefSpecs,
+		Auth:     auth,
+		Tags:     git.NoTags,
+		Force:    true,
+	})
+	if err != nil && err != git.NoErrAlreadyUpToDate {
+		return nil, fmt.Errorf("unable to fetch %s: %v", g.repositoryURL, err)
+	}
+
+	err = g.prune(repo, auth)
+	if err != nil {
+		return nil, err
+	}
 
-		err = g.cmd(logger, "clone", "--mirror", g.repositoryURL, g.repoDir)
+	if g.verifier != nil {
+		err = g.verifier.prepare(logger)
 		if err != nil {
 			return nil, err
 		}
 	}
 
-	err = g.cmd(logger, "--git-dir", g.repoDir, "remote", "update", "--prune")
+	return g.availableChannels(repo)
+}
+
+// openMirror opens the local mirror of the repository, creating it if it
+// doesn't exist yet.
+func (g *Git) openMirror() (*git.Repository, error) {
+	repo, err := git.PlainOpen(g.repoDir)
+	if err != git.ErrRepositoryNotExists {
+		return repo, err
+	}
+
+	repo, err = git.PlainInit(g.repoDir, true)
 	if err != nil {
 		return nil, err
 	}
 
-	return g.availableChannels(logger)
+	_, err = repo.CreateRemote(&config.RemoteConfig{
+		Name:  git.DefaultRemoteName,
+		URLs:  []string{g.repositoryURL},
+		Fetch: mirrorRefSpecs,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return repo, nil
 }
 
-func (g *Git) availableChannels(logger *log.Entry) (ConfigVersions, error) {
-	cmd := exec.Command("git", "--git-dir", g.repoDir, "show-ref", "--heads")
-	out, err := command.RunSilently(logger, cmd)
+// authMethod returns the authentication for the repository URL. SSH host
+// keys are always verified against the known hosts.
+func (g *Git) authMethod() (transport.AuthMethod, error) {
+	endpoint, err := transport.NewEndpoint(g.repositoryURL)
 	if err != nil {
 		return nil, err
 	}
 
-	result := make(map[string]ConfigVersion)
-	for _, line := range strings.Split(out, "\n") {
-		if line != "" {
-			chunks := strings.Split(line, " ")
-			if len(chunks) != 2 {
-				return nil, fmt.Errorf("availableChannels: invalid line in show-ref output: %s", line)
+	switch endpoint.Protocol {
+	case "ssh":
+		var knownHosts []string
+		if g.auth.SSHKnownHostsFile != "" {
+			knownHosts = append(knownHosts, g.auth.SSHKnownHostsFile)
+		}
+		hostKeyCallback, err := gitssh.NewKnownHostsCallback(knownHosts...)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read known hosts: %v", err)
+		}
+
+		user := endpoint.User
+		if user == "" {
+			user = gitssh.DefaultUsername
+		}
+
+		if g.auth.SSHPrivateKeyFile == "" {
+			auth, err := gitssh.NewSSHAgentAuth(user)
+			if err != nil {
+				return nil, err
 			}
+			auth.HostKeyCallback = hostKeyCallback
+			return auth, nil
+		}
 
-			hash := chunks[0]
-			channel := strings.Replace(chunks[1], "refs/heads/", "", 1)
+		auth, err := gitssh.NewPublicKeysFromFile(user, g.auth.SSHPrivateKeyFile, "")
+		if err != nil {
+			return nil, fmt.Errorf("unable to read SSH private key %s: %v", g.auth.SSHPrivateKeyFile, err)
+		}
+		auth.HostKeyCallback = hostKeyCallback
+		return auth, nil
+	case "http", "https":
+		if g.auth.TokenSource == nil {
+			return nil, nil
+		}
 
-			result[channel] = ConfigVersion(hash)
+		token, err := g.auth.TokenSource.Token()
+		if err != nil {
+			return nil, fmt.Errorf("unable to get git token: %v", err)
 		}
+		return &githttp.BasicAuth{Username: g.auth.TokenUsername, Password: token.AccessToken}, nil
+	default:
+		return nil, nil
 	}
-	return NewGitVersions(result), nil
 }
 
-// localClone duplicates a repo by cloning to temp location with unix time
-// suffix this will be the path that is exposed through the Config. This
-// makes sure that each caller (possibly running concurrently) get it's
-// own version of the checkout, such that they can run concurrently
-// without data races.
-func (g *Git) localClone(logger *log.Entry, channel string) (string, error) {
-	repoDir := path.Join(g.workdir, fmt.Sprintf("%s_%s_%d", g.repoName, channel, time.Now().UTC().UnixNano()))
+// prune removes the branches and tags deleted in the remote repository.
+func (g *Git) prune(repo *git.Repository, auth transport.AuthMethod) error {
+	remote, err := repo.Remote(git.DefaultRemoteName)
+	if err != nil {
+		return err
+	}
 
-	srcRepoUrl := fmt.Sprintf("file://%s", g.repoDir)
-	err := g.cmd(logger, "clone", srcRepoUrl, repoDir)
+	remoteRefs, err := remote.List(&git.ListOptions{Auth: auth})
 	if err != nil {
-		return "", err
+		return fmt.Errorf("unable to list references of %s: %v", g.repositoryURL, err)
+	}
+
+	existing := make(map[plumbing.ReferenceName]bool, len(remoteRefs))
+	for _, ref := range remoteRefs {
+		existing[ref.Name()] = true
 	}
 
-	err = g.cmd(logger, "-C", repoDir, "checkout", channel)
+	refs, err := repo.References()
 	if err != nil {
-		return "", err
+		return err
+	}
+
+	var stale []plumbing.ReferenceName
+	err = refs.ForEach(func(ref *plumbing.Reference) error {
+		if (ref.Name().IsBranch() || ref.Name().IsTag()) && !existing[ref.Name()] {
+			stale = append(stale, ref.Name())
+		}
+		return nil
+	})
+	if err != nil {
+		return err
 	}
 
+	for _, name := range stale {
+		err := repo.Storer.RemoveReference(name)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// availableChannels returns the commits of the branches and tags of the
+// repository. Annotated tags are resolved to the commits they point to.
+func (g *Git) availableChannels(repo *git.Repository) (ConfigVersions, error) {
+	refs, err := repo.References()
+	if err != nil {
+		return nil, err
+	}
+
+	branches := make(map[string]ConfigVersion)
+	tags := make(map[string]ConfigVersion)
+	err = refs.ForEach(func(ref *plumbing.Reference) error {
+		if ref.Type() != plumbing.HashReference {
+			return nil
+		}
+
+		name := ref.Name().String()
+		switch {
+		case ref.Name().IsBranch():
+			branches[strings.TrimPrefix(name, "refs/heads/")] = ConfigVersion(ref.Hash().String())
+		case ref.Name().IsTag():
+			hash := ref.Hash()
+
+			tag, err := repo.TagObject(hash)
+			switch err {
+			case nil:
+				// the commit an annotated tag points to
+				commit, err := tag.Commit()
+				if err != nil {
+					// tags of anything but commits can't be channels
+					return nil
+				}
+				hash = commit.Hash
+			case plumbing.ErrObjectNotFound:
+				// a lightweight tag pointing to the commit
+			default:
+				return err
+			}
+
+			tags[strings.TrimPrefix(name, "refs/tags/")] = ConfigVersion(hash.String())
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return NewGitVersionsWithTags(branches, tags), nil
+}
+
+// acquireCheckout returns the shared checkout of the commit, writing the
+// files of the commit if it isn't checked out yet.
+func (g *Git) acquireCheckout(commit *object.Commit) (string, error) {
+	repoDir := path.Join(g.workdir, fmt.Sprintf("%s_%s", g.repoName, commit.Hash))
+
+	err := g.checkouts.acquire(repoDir, func() error {
+		return checkoutTree(g.ctx, commit, repoDir)
+	})
+	if err != nil {
+		return "", fmt.Errorf("unable to check out %s: %v", commit.Hash, err)
+	}
 	return repoDir, nil
 }
 
-// cmd executes a git command with the correct environment set.
-func (g *Git) cmd(logger *log.Entry, args ...string) error {
-	cmd := exec.Command("git", args...)
-	// set GIT_SSH_COMMAND with private-key file when pulling over ssh.
-	if g.sshPrivateKeyFile != "" {
-		cmd.Env = []string{fmt.Sprintf("GIT_SSH_COMMAND=ssh -i %s -o 'StrictHostKeyChecking no'", g.sshPrivateKeyFile)}
+// checkoutTree writes the files of the commit to dir. Submodules aren't
+// checked out.
+func checkoutTree(ctx context.Context, commit *object.Commit, dir string) error {
+	tree, err := commit.Tree()
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(dir, 0755)
+	if err != nil {
+		return err
+	}
+
+	return tree.Files().ForEach(func(file *object.File) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		target := filepath.Join(dir, file.Name)
+		if target == dir || !within(dir, target) {
+			return fmt.Errorf("invalid file name: %s", file.Name)
+		}
+
+		err := os.MkdirAll(filepath.Dir(target), 0755)
+		if err != nil {
+			return err
+		}
+
+		if file.Mode == filemode.Symlink {
+			link, err := file.Contents()
+			if err != nil {
+				return err
+			}
+			return os.Symlink(link, target)
+		}
+
+		mode := os.FileMode(0644)
+		if file.Mode == filemode.Executable {
+			mode = 0755
+		}
+		return writeBlob(file, target, mode)
+	})
+}
+
+// writeBlob writes the contents of the file to target.
+func writeBlob(file *object.File, target string, mode os.FileMode) error {
+	reader, err := file.Reader()
+	if err != nil {
+		return err
 	}
+	defer reader.Close()
 
-	_, err := command.RunSilently(logger, cmd)
-	return err
+	return writeFile(reader, target, mode)
 }
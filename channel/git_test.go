@@ -1,16 +1,21 @@
 package channel
 
 import (
+	"context"
 	"io/ioutil"
 	"os"
 	"os/exec"
 	"path"
 	"strings"
+	"sync"
 	"testing"
 
 	log "github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/require"
 	"github.com/zalando-incubator/cluster-lifecycle-manager/pkg/util/command"
+	"golang.org/x/oauth2"
+	"gopkg.in/src-d/go-git.v4/plumbing/transport"
+	githttp "gopkg.in/src-d/go-git.v4/plumbing/transport/http"
 )
 
 // helper function to setup a test repository.
@@ -83,7 +88,7 @@ func TestGitGet(t *testing.T) {
 	createGitRepo(t, logger, tmpRepo)
 	defer os.RemoveAll(tmpRepo)
 
-	c, err := NewGit(workdir, tmpRepo, "")
+	c, err := NewGit(context.Background(), workdir, tmpRepo, nil, nil)
 	require.NoError(t, err)
 	defer os.RemoveAll(workdir)
 
@@ -109,6 +114,290 @@ func TestGitGet(t *testing.T) {
 	requireNoFile(t, sha, "different_file")
 }
 
+func TestGitTags(t *testing.T) {
+	logger := log.StandardLogger().WithFields(map[string]interface{}{})
+
+	workdir := "workdir_tags_test"
+	tmpRepo := "tmp_tags_test_repo.git"
+	createGitRepo(t, logger, tmpRepo)
+	defer os.RemoveAll(tmpRepo)
+
+	tag := func(args ...string) {
+		cmd := exec.Command("git", append([]string{"-C", tmpRepo, "tag"}, args...)...)
+		cmd.Env = []string{
+			"GIT_COMMITTER_EMAIL=go-test",
+			"GIT_COMMITTER_NAME=go-test",
+		}
+		_, err := command.RunSilently(logger, cmd)
+		require.NoError(t, err)
+	}
+
+	// master only has init_file, channel2 has different_file as well
+	tag("v1.12.1", "master")
+	tag("-a", "-m", "release", "v1.12.2", "channel2")
+	tag("v1.13.0-rc.1", "channel2")
+
+	c, err := NewGit(context.Background(), workdir, tmpRepo, nil, nil)
+	require.NoError(t, err)
+	defer os.RemoveAll(workdir)
+
+	versions, err := c.Update(logger)
+	require.NoError(t, err)
+
+	tagged := checkout(t, logger, c, versions, "v1.12.1")
+	requireNoFile(t, tagged, "different_file")
+
+	// annotated tags are resolved to the commit
+	annotated, err := versions.Version("v1.12.2")
+	require.NoError(t, err)
+	out, err := exec.Command("git", "-C", tmpRepo, "rev-parse", "channel2").Output()
+	require.NoError(t, err)
+	require.Equal(t, ConfigVersion(strings.TrimSpace(string(out))), annotated)
+
+	// ranges follow the newest matching release, pre-releases are skipped
+	latest, err := versions.Version("~1.12")
+	require.NoError(t, err)
+	require.Equal(t, annotated, latest)
+
+	latest, err = versions.Version("^1")
+	require.NoError(t, err)
+	require.Equal(t, annotated, latest)
+
+	_, err = versions.Version("~1.14")
+	require.Error(t, err)
+}
+
+func TestGitSharedCheckouts(t *testing.T) {
+	logger := log.StandardLogger().WithFields(map[string]interface{}{})
+
+	workdir := "workdir_shared_test"
+	tmpRepo := "tmp_shared_test_repo.git"
+	createGitRepo(t, logger, tmpRepo)
+	defer os.RemoveAll(tmpRepo)
+
+	c, err := NewGit(context.Background(), workdir, tmpRepo, nil, nil)
+	require.NoError(t, err)
+	defer os.RemoveAll(workdir)
+
+	versions, err := c.Update(logger)
+	require.NoError(t, err)
+
+	version, err := versions.Version("channel2")
+	require.NoError(t, err)
+
+	// concurrent users of the same version share the checkout
+	configs := make([]*Config, 5)
+	var wg sync.WaitGroup
+	for i := range configs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			config, err := c.Get(logger, version)
+			require.NoError(t, err)
+			configs[i] = config
+		}(i)
+	}
+	wg.Wait()
+
+	for _, config := range configs {
+		require.Equal(t, configs[0].Path, config.Path)
+	}
+	requireFile(t, configs[0].Path, "different_file")
+
+	// the checkout is removed once the last user is done with it
+	for _, config := range configs[1:] {
+		require.NoError(t, c.Delete(logger, config))
+		requireFile(t, configs[0].Path, "different_file")
+	}
+	require.NoError(t, c.Delete(logger, configs[0]))
+	_, err = os.Stat(configs[0].Path)
+	require.True(t, os.IsNotExist(err), "unexpected error: %v", err)
+
+	// and checked out again when needed
+	config, err := c.Get(logger, version)
+	require.NoError(t, err)
+	requireFile(t, config.Path, "different_file")
+}
+
+func TestGitCollectGarbage(t *testing.T) {
+	logger := log.StandardLogger().WithFields(map[string]interface{}{})
+
+	workdir := "workdir_gc_test"
+	tmpRepo := "tmp_gc_test_repo.git"
+	createGitRepo(t, logger, tmpRepo)
+	defer os.RemoveAll(tmpRepo)
+
+	c, err := NewGit(context.Background(), workdir, tmpRepo, nil, nil)
+	require.NoError(t, err)
+	defer os.RemoveAll(workdir)
+
+	versions, err := c.Update(logger)
+	require.NoError(t, err)
+	checkout(t, logger, c, versions, "master")
+
+	// checkouts left behind by an earlier run
+	stale := path.Join(workdir, "tmp_gc_test_repo_master_1535000000000000000")
+	require.NoError(t, os.MkdirAll(stale, 0755))
+	unrelated := path.Join(workdir, "unrelated")
+	require.NoError(t, os.MkdirAll(unrelated, 0755))
+
+	c, err = NewGit(context.Background(), workdir, tmpRepo, nil, nil)
+	require.NoError(t, err)
+
+	entries, err := ioutil.ReadDir(workdir)
+	require.NoError(t, err)
+
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	require.Equal(t, []string{"tmp_gc_test_repo", "unrelated"}, names)
+
+	// the mirror is kept
+	versions, err = c.Update(logger)
+	require.NoError(t, err)
+	requireFile(t, checkout(t, logger, c, versions, "channel2"), "different_file")
+}
+
+func TestGitUpdatePrune(t *testing.T) {
+	logger := log.StandardLogger().WithFields(map[string]interface{}{})
+
+	workdir := "workdir_prune_test"
+	tmpRepo := "tmp_prune_test_repo.git"
+	createGitRepo(t, logger, tmpRepo)
+	defer os.RemoveAll(tmpRepo)
+
+	c, err := NewGit(context.Background(), workdir, tmpRepo, nil, nil)
+	require.NoError(t, err)
+	defer os.RemoveAll(workdir)
+
+	versions, err := c.Update(logger)
+	require.NoError(t, err)
+	_, err = versions.Version("channel2")
+	require.NoError(t, err)
+
+	err = exec.Command("git", "-C", tmpRepo, "checkout", "-q", "master").Run()
+	require.NoError(t, err)
+	err = exec.Command("git", "-C", tmpRepo, "branch", "-D", "channel2").Run()
+	require.NoError(t, err)
+
+	// deleted branches are removed from the mirror
+	versions, err = c.Update(logger)
+	require.NoError(t, err)
+	_, err = versions.Version("channel2")
+	require.Error(t, err)
+	_, err = versions.Version("master")
+	require.NoError(t, err)
+}
+
+func TestGitCanceled(t *testing.T) {
+	logger := log.StandardLogger().WithFields(map[string]interface{}{})
+
+	workdir := "workdir_canceled_test"
+	tmpRepo := "tmp_canceled_test_repo.git"
+	createGitRepo(t, logger, tmpRepo)
+	defer os.RemoveAll(tmpRepo)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	c, err := NewGit(ctx, workdir, tmpRepo, nil, nil)
+	require.NoError(t, err)
+	defer os.RemoveAll(workdir)
+
+	_, err = c.Update(logger)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), context.Canceled.Error())
+}
+
+func TestGitAuthMethod(t *testing.T) {
+	tokenSource := oauth2.StaticTokenSource(&oauth2.Token{AccessToken: "token"})
+
+	for _, tc := range []struct {
+		msg     string
+		url     string
+		auth    *GitAuth
+		success bool
+		result  transport.AuthMethod
+	}{
+		{
+			msg:     "local repository",
+			url:     "/kubernetes-on-aws.git",
+			auth:    &GitAuth{TokenSource: tokenSource},
+			success: true,
+		},
+		{
+			msg:     "https without token",
+			url:     "https://github.com/zalando-incubator/kubernetes-on-aws.git",
+			auth:    &GitAuth{},
+			success: true,
+		},
+		{
+			msg:     "https with token",
+			url:     "https://github.com/zalando-incubator/kubernetes-on-aws.git",
+			auth:    &GitAuth{TokenSource: tokenSource, TokenUsername: "oauth2"},
+			success: true,
+			result:  &githttp.BasicAuth{Username: "oauth2", Password: "token"},
+		},
+		{
+			msg:  "ssh without known hosts",
+			url:  "git@github.com:zalando-incubator/kubernetes-on-aws.git",
+			auth: &GitAuth{SSHKnownHostsFile: "/non-existing/known_hosts"},
+		},
+	} {
+		t.Run(tc.msg, func(t *testing.T) {
+			source, err := NewGit(context.Background(), "workdir_auth_test", tc.url, tc.auth, nil)
+			require.NoError(t, err)
+
+			auth, err := source.(*Git).authMethod()
+			if !tc.success {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.result, auth)
+		})
+	}
+}
+
+func TestGitVersions(t *testing.T) {
+	versions := NewGitVersionsWithTags(
+		map[string]ConfigVersion{"master": "master-sha", "v1.12.0": "branch-sha"},
+		map[string]ConfigVersion{
+			"v1.12.0":      "v1.12.0-sha",
+			"v1.12.10":     "v1.12.10-sha",
+			"v1.12.9":      "v1.12.9-sha",
+			"1.13.0":       "v1.13.0-sha",
+			"1.14.0-rc.1":  "v1.14.0-rc.1-sha",
+			"not-semantic": "not-semantic-sha",
+		})
+
+	for _, tc := range []struct {
+		channel string
+		version ConfigVersion
+	}{
+		{channel: "master", version: "master-sha"},
+		// branches take precedence over tags with the same name
+		{channel: "v1.12.0", version: "branch-sha"},
+		{channel: "not-semantic", version: "not-semantic-sha"},
+		{channel: "1.14.0-rc.1", version: "v1.14.0-rc.1-sha"},
+		{channel: "~1.12", version: "v1.12.10-sha"},
+		{channel: "^1.12", version: "v1.13.0-sha"},
+		{channel: "0123456789abcdef0123456789abcdef01234567", version: "0123456789abcdef0123456789abcdef01234567"},
+	} {
+		t.Run(tc.channel, func(t *testing.T) {
+			version, err := versions.Version(tc.channel)
+			require.NoError(t, err)
+			require.Equal(t, tc.version, version)
+		})
+	}
+
+	for _, channel := range []string{"unknown", "~2.0", "~foo"} {
+		_, err := versions.Version(channel)
+		require.Error(t, err, channel)
+	}
+}
+
 func TestGetRepoName(t *testing.T) {
 	for _, tc := range []struct {
 		msg     string
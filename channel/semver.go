@@ -0,0 +1,119 @@
+package channel
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// semanticVersion is a version in the format MAJOR.MINOR.PATCH with an
+// optional pre-release suffix, e.g. 1.12.3 or 1.13.0-rc.1.
+type semanticVersion struct {
+	major      uint64
+	minor      uint64
+	patch      uint64
+	preRelease string
+}
+
+// parseSemanticVersion parses a version with an optional v prefix, e.g.
+// v1.12.3. Build metadata is ignored.
+func parseSemanticVersion(value string) (*semanticVersion, error) {
+	version := strings.TrimPrefix(value, "v")
+	if i := strings.Index(version, "+"); i >= 0 {
+		version = version[:i]
+	}
+
+	var result semanticVersion
+	if i := strings.Index(version, "-"); i >= 0 {
+		result.preRelease = version[i+1:]
+		version = version[:i]
+		if result.preRelease == "" {
+			return nil, fmt.Errorf("invalid version: %s", value)
+		}
+	}
+
+	parts := strings.Split(version, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("invalid version: %s", value)
+	}
+
+	numbers := make([]uint64, len(parts))
+	for i, part := range parts {
+		number, err := strconv.ParseUint(part, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid version: %s", value)
+		}
+		numbers[i] = number
+	}
+	result.major, result.minor, result.patch = numbers[0], numbers[1], numbers[2]
+
+	return &result, nil
+}
+
+// less returns true if v has a lower precedence than other. Pre-release
+// versions are compared lexically.
+func (v *semanticVersion) less(other *semanticVersion) bool {
+	if v.major != other.major {
+		return v.major < other.major
+	}
+	if v.minor != other.minor {
+		return v.minor < other.minor
+	}
+	if v.patch != other.patch {
+		return v.patch < other.patch
+	}
+	if v.preRelease == "" || other.preRelease == "" {
+		return v.preRelease != "" && other.preRelease == ""
+	}
+	return v.preRelease < other.preRelease
+}
+
+// equal returns true if v and other have the same precedence.
+func (v *semanticVersion) equal(other *semanticVersion) bool {
+	return !v.less(other) && !other.less(v)
+}
+
+// versionRange is a range of versions >= min and < max.
+type versionRange struct {
+	min *semanticVersion
+	max *semanticVersion
+}
+
+// parseVersionRange parses a version range in the format ~MAJOR.MINOR[.PATCH],
+// matching the patch releases of a minor version, or ^MAJOR[.MINOR[.PATCH]],
+// matching the minor and patch releases of a major version. As minor
+// releases of major version 0 may be incompatible, ^0.MINOR[.PATCH] only
+// matches the patch releases of the minor version.
+func parseVersionRange(value string) (*versionRange, error) {
+	if len(value) < 2 || (value[0] != '~' && value[0] != '^') {
+		return nil, fmt.Errorf("invalid version range: %s", value)
+	}
+
+	parts := strings.Split(strings.TrimPrefix(value[1:], "v"), ".")
+	if len(parts) > 3 || (value[0] == '~' && len(parts) < 2) {
+		return nil, fmt.Errorf("invalid version range: %s", value)
+	}
+
+	numbers := make([]uint64, 3)
+	for i, part := range parts {
+		number, err := strconv.ParseUint(part, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid version range: %s", value)
+		}
+		numbers[i] = number
+	}
+
+	min := &semanticVersion{major: numbers[0], minor: numbers[1], patch: numbers[2]}
+	max := &semanticVersion{major: numbers[0] + 1}
+	if value[0] == '~' || (numbers[0] == 0 && len(parts) > 1) {
+		max = &semanticVersion{major: numbers[0], minor: numbers[1] + 1}
+	}
+
+	return &versionRange{min: min, max: max}, nil
+}
+
+// contains returns true if the version is in the range. Pre-release versions
+// are never contained, so that they're only used when pinned explicitly.
+func (r *versionRange) contains(version *semanticVersion) bool {
+	return version.preRelease == "" && !version.less(r.min) && version.less(r.max)
+}
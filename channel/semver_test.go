@@ -0,0 +1,127 @@
+package channel
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSemanticVersion(t *testing.T) {
+	for _, tc := range []struct {
+		value   string
+		version *semanticVersion
+	}{
+		{value: "1.12.3", version: &semanticVersion{major: 1, minor: 12, patch: 3}},
+		{value: "v1.12.3", version: &semanticVersion{major: 1, minor: 12, patch: 3}},
+		{value: "v1.13.0-rc.1", version: &semanticVersion{major: 1, minor: 13, preRelease: "rc.1"}},
+		{value: "v1.13.0+build.5", version: &semanticVersion{major: 1, minor: 13}},
+		{value: "v1.12"},
+		{value: "v1.12.x"},
+		{value: "v1.12.3-"},
+		{value: "release-1"},
+	} {
+		t.Run(tc.value, func(t *testing.T) {
+			version, err := parseSemanticVersion(tc.value)
+			if tc.version == nil {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.version, version)
+		})
+	}
+}
+
+func TestSemanticVersionLess(t *testing.T) {
+	ordered := []string{"0.9.9", "1.12.0-rc.1", "1.12.0-rc.2", "1.12.0", "1.12.3", "1.13.0", "2.0.0"}
+	for i := 1; i < len(ordered); i++ {
+		lower, err := parseSemanticVersion(ordered[i-1])
+		require.NoError(t, err)
+		higher, err := parseSemanticVersion(ordered[i])
+		require.NoError(t, err)
+
+		require.True(t, lower.less(higher), "%s < %s", ordered[i-1], ordered[i])
+		require.False(t, higher.less(lower), "%s > %s", ordered[i], ordered[i-1])
+	}
+}
+
+func TestVersionRange(t *testing.T) {
+	for _, tc := range []struct {
+		versionRange string
+		contained    []string
+		notContained []string
+	}{
+		{
+			versionRange: "~1.12",
+			contained:    []string{"1.12.0", "v1.12.7"},
+			notContained: []string{"1.11.9", "1.13.0", "1.12.8-rc.1"},
+		},
+		{
+			versionRange: "~v1.12.3",
+			contained:    []string{"1.12.3", "1.12.4"},
+			notContained: []string{"1.12.2", "1.13.0"},
+		},
+		{
+			versionRange: "^1",
+			contained:    []string{"1.0.0", "1.99.0"},
+			notContained: []string{"0.9.0", "2.0.0"},
+		},
+		{
+			versionRange: "^1.12",
+			contained:    []string{"1.12.0", "1.13.1"},
+			notContained: []string{"1.11.0", "2.0.0"},
+		},
+		{
+			versionRange: "^0",
+			contained:    []string{"0.1.0", "0.99.0"},
+			notContained: []string{"1.0.0"},
+		},
+		{
+			versionRange: "^0.12",
+			contained:    []string{"0.12.0", "0.12.7"},
+			notContained: []string{"0.11.9", "0.13.0", "1.0.0"},
+		},
+		{
+			versionRange: "^v0.12.3",
+			contained:    []string{"0.12.3", "0.12.4"},
+			notContained: []string{"0.12.2", "0.13.0"},
+		},
+	} {
+		t.Run(tc.versionRange, func(t *testing.T) {
+			r, err := parseVersionRange(tc.versionRange)
+			require.NoError(t, err)
+
+			for _, value := range tc.contained {
+				version, err := parseSemanticVersion(value)
+				require.NoError(t, err)
+				require.True(t, r.contains(version), value)
+			}
+			for _, value := range tc.notContained {
+				version, err := parseSemanticVersion(value)
+				require.NoError(t, err)
+				require.False(t, r.contains(version), value)
+			}
+		})
+	}
+
+	for _, invalid := range []string{"~1", "~", "1.12", "~1.x", "^1.2.3.4"} {
+		_, err := parseVersionRange(invalid)
+		require.Error(t, err, invalid)
+	}
+}
+
+func TestNewestReleaseTie(t *testing.T) {
+	tags := map[string]ConfigVersion{
+		"v1.12.3":       "v-prefixed",
+		"1.12.3":        "plain",
+		"1.12.3+build1": "build",
+		"1.12.2":        "older",
+	}
+
+	// the tags are collected from a map, the order must not matter
+	for i := 0; i < 20; i++ {
+		version, err := NewGitVersionsWithTags(nil, tags).Version("~1.12")
+		require.NoError(t, err)
+		require.EqualValues(t, "plain", version)
+	}
+}
@@ -0,0 +1,232 @@
+package channel
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"fmt"
+	"hash"
+	"io"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+const (
+	sshSignatureMagic  = "SSHSIG"
+	sshSignatureBegin  = "-----BEGIN SSH SIGNATURE-----"
+	sshSignatureEnd    = "-----END SSH SIGNATURE-----"
+	sshGitNamespace    = "git"
+	sshFileNamespace   = "file"
+	sshSignatureFormat = 1
+)
+
+// allowedSigner is a key of an allowed signers file, see ssh-keygen(1).
+type allowedSigner struct {
+	principals string
+	key        ssh.PublicKey
+	namespaces []string
+
+	// validity interval, zero if unbounded
+	validAfter  time.Time
+	validBefore time.Time
+}
+
+// parseAllowedSigners parses an allowed signers file. Certificate authorities
+// aren't supported and are ignored.
+func parseAllowedSigners(r io.Reader) ([]*allowedSigner, error) {
+	var result []*allowedSigner
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.SplitN(line, " ", 2)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("invalid allowed signers line: %s", line)
+		}
+
+		key, _, options, _, err := ssh.ParseAuthorizedKey([]byte(fields[1]))
+		if err != nil {
+			return nil, fmt.Errorf("invalid allowed signers line: %s: %v", line, err)
+		}
+
+		signer := &allowedSigner{principals: fields[0], key: key}
+		trusted := true
+		for _, option := range options {
+			name, value := option, ""
+			if i := strings.Index(option, "="); i >= 0 {
+				name, value = option[:i], strings.Trim(option[i+1:], `"`)
+			}
+
+			switch strings.ToLower(name) {
+			case "cert-authority":
+				trusted = false
+			case "namespaces":
+				signer.namespaces = strings.Split(value, ",")
+			case "valid-after":
+				signer.validAfter, err = parseSignerTime(value)
+			case "valid-before":
+				signer.validBefore, err = parseSignerTime(value)
+			}
+			if err != nil {
+				return nil, fmt.Errorf("invalid allowed signers line: %s: %v", line, err)
+			}
+		}
+
+		if trusted {
+			result = append(result, signer)
+		}
+	}
+
+	return result, scanner.Err()
+}
+
+// parseSignerTime parses the time of a valid-after or valid-before option,
+// YYYYMMDD[HHMM[SS]] in the local time zone or in UTC with a Z suffix.
+func parseSignerTime(value string) (time.Time, error) {
+	location := time.Local
+	if strings.HasSuffix(value, "Z") || strings.HasSuffix(value, "z") {
+		location = time.UTC
+		value = value[:len(value)-1]
+	}
+
+	var layout string
+	switch len(value) {
+	case 8:
+		layout = "20060102"
+	case 12:
+		layout = "200601021504"
+	case 14:
+		layout = "20060102150405"
+	default:
+		return time.Time{}, fmt.Errorf("invalid time: %s", value)
+	}
+	return time.ParseInLocation(layout, value, location)
+}
+
+// validAt returns true if the time is within the validity interval of the
+// signer.
+func (s *allowedSigner) validAt(now time.Time) bool {
+	if !s.validAfter.IsZero() && now.Before(s.validAfter) {
+		return false
+	}
+	return s.validBefore.IsZero() || now.Before(s.validBefore)
+}
+
+// allows returns true if the signer may sign in the namespace.
+func (s *allowedSigner) allows(namespace string) bool {
+	if len(s.namespaces) == 0 {
+		return true
+	}
+	for _, n := range s.namespaces {
+		if n == namespace {
+			return true
+		}
+	}
+	return false
+}
+
+// sshSignature is the binary encoding of an SSH signature, see
+// PROTOCOL.sshsig in the OpenSSH sources.
+type sshSignature struct {
+	Version       uint32
+	PublicKey     []byte
+	Namespace     string
+	Reserved      string
+	HashAlgorithm string
+	Signature     []byte
+}
+
+// sshSignedData is the data signed by an SSH signature.
+type sshSignedData struct {
+	Namespace     string
+	Reserved      string
+	HashAlgorithm string
+	Hash          []byte
+}
+
+// verifySSHSignature checks that the armored signature of message was made in
+// the namespace by one of the allowed signers valid at the time now and
+// returns the principals of the signer.
+func verifySSHSignature(signers []*allowedSigner, namespace, armored string, message io.Reader, now time.Time) (string, error) {
+	armored = strings.TrimSpace(armored)
+	if !strings.HasPrefix(armored, sshSignatureBegin) || !strings.HasSuffix(armored, sshSignatureEnd) {
+		return "", fmt.Errorf("invalid SSH signature armor")
+	}
+
+	encoded := strings.Join(strings.Fields(armored[len(sshSignatureBegin):len(armored)-len(sshSignatureEnd)]), "")
+	blob, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", fmt.Errorf("invalid SSH signature: %v", err)
+	}
+
+	if !bytes.HasPrefix(blob, []byte(sshSignatureMagic)) {
+		return "", fmt.Errorf("invalid SSH signature: missing preamble")
+	}
+
+	var signature sshSignature
+	err = ssh.Unmarshal(blob[len(sshSignatureMagic):], &signature)
+	if err != nil {
+		return "", fmt.Errorf("invalid SSH signature: %v", err)
+	}
+
+	if signature.Version != sshSignatureFormat {
+		return "", fmt.Errorf("unsupported SSH signature version %d", signature.Version)
+	}
+
+	if signature.Namespace != namespace {
+		return "", fmt.Errorf("SSH signature namespace %s doesn't match %s", signature.Namespace, namespace)
+	}
+
+	var h hash.Hash
+	switch signature.HashAlgorithm {
+	case "sha256":
+		h = sha256.New()
+	case "sha512":
+		h = sha512.New()
+	default:
+		return "", fmt.Errorf("unsupported SSH signature hash algorithm %s", signature.HashAlgorithm)
+	}
+
+	var sig ssh.Signature
+	err = ssh.Unmarshal(signature.Signature, &sig)
+	if err != nil {
+		return "", fmt.Errorf("invalid SSH signature: %v", err)
+	}
+
+	var signer *allowedSigner
+	for _, s := range signers {
+		if bytes.Equal(s.key.Marshal(), signature.PublicKey) && s.allows(namespace) && s.validAt(now) {
+			signer = s
+			break
+		}
+	}
+	if signer == nil {
+		return "", fmt.Errorf("SSH signature key isn't an allowed signer")
+	}
+
+	_, err = io.Copy(h, message)
+	if err != nil {
+		return "", err
+	}
+
+	signedData := ssh.Marshal(sshSignedData{
+		Namespace:     signature.Namespace,
+		Reserved:      signature.Reserved,
+		HashAlgorithm: signature.HashAlgorithm,
+		Hash:          h.Sum(nil),
+	})
+
+	err = signer.key.Verify(append([]byte(sshSignatureMagic), signedData...), &sig)
+	if err != nil {
+		return "", err
+	}
+	return signer.principals, nil
+}
@@ -0,0 +1,64 @@
+package channel
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+const testSSHPublicKey = "ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIMMZkHlBBJc5pafS+E/7b84odI6xHWSo5PdctNweX89p go-test@example.org"
+
+func TestParseAllowedSigners(t *testing.T) {
+	signers, err := parseAllowedSigners(strings.NewReader(`
+# comment
+go-test@example.org ` + testSSHPublicKey + `
+*@example.org namespaces="file,git" ` + testSSHPublicKey + `
+release@example.org namespaces="file" ` + testSSHPublicKey + `
+*@example.org cert-authority ` + testSSHPublicKey + `
+old@example.org valid-after="20200101",valid-before="20210101120000Z" ` + testSSHPublicKey + `
+`))
+	require.NoError(t, err)
+	require.Len(t, signers, 4)
+
+	require.Equal(t, "go-test@example.org", signers[0].principals)
+	require.True(t, signers[0].allows(sshGitNamespace))
+	require.Equal(t, "*@example.org", signers[1].principals)
+	require.True(t, signers[1].allows(sshGitNamespace))
+	require.Equal(t, "release@example.org", signers[2].principals)
+	require.False(t, signers[2].allows(sshGitNamespace))
+
+	require.True(t, signers[0].validAt(time.Now()))
+	require.Equal(t, time.Date(2020, 1, 1, 0, 0, 0, 0, time.Local), signers[3].validAfter)
+	require.Equal(t, time.Date(2021, 1, 1, 12, 0, 0, 0, time.UTC), signers[3].validBefore)
+	require.False(t, signers[3].validAt(time.Date(2019, 12, 31, 0, 0, 0, 0, time.UTC)))
+	require.True(t, signers[3].validAt(time.Date(2020, 6, 1, 0, 0, 0, 0, time.UTC)))
+	require.False(t, signers[3].validAt(time.Date(2021, 1, 1, 12, 0, 0, 0, time.UTC)))
+	require.False(t, signers[3].validAt(time.Now()))
+
+	for _, invalid := range []string{
+		"go-test@example.org",
+		"go-test@example.org ssh-ed25519 invalid",
+		`go-test@example.org valid-after="2020" ` + testSSHPublicKey,
+		`go-test@example.org valid-before="20201301" ` + testSSHPublicKey,
+	} {
+		_, err := parseAllowedSigners(strings.NewReader(invalid))
+		require.Error(t, err, invalid)
+	}
+}
+
+func TestVerifySSHSignatureInvalid(t *testing.T) {
+	signers, err := parseAllowedSigners(strings.NewReader("go-test@example.org " + testSSHPublicKey))
+	require.NoError(t, err)
+
+	for _, signature := range []string{
+		"",
+		"-----BEGIN PGP SIGNATURE-----\nfoo\n-----END PGP SIGNATURE-----",
+		sshSignatureBegin + "\nnot-base64\n" + sshSignatureEnd,
+		sshSignatureBegin + "\nZm9vYmFy\n" + sshSignatureEnd,
+	} {
+		_, err := verifySSHSignature(signers, sshGitNamespace, signature, strings.NewReader("message"), time.Now())
+		require.Error(t, err, signature)
+	}
+}
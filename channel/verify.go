@@ -0,0 +1,235 @@
+package channel
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/crypto/openpgp"
+	"golang.org/x/crypto/openpgp/armor"
+	"golang.org/x/crypto/openpgp/packet"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
+)
+
+// SignatureVerification configures the keys trusted to sign the commits of
+// the channel configuration. Commits are accepted if they're signed by any
+// of the keys. Expired and revoked GPG keys as well as SSH keys outside of
+// their validity interval aren't trusted.
+type SignatureVerification struct {
+	// GPGKeyring is the path to a file with the trusted GPG public keys,
+	// e.g. exported with gpg --export --armor.
+	GPGKeyring string
+	// SSHAllowedSigners is the path to an allowed signers file with the
+	// trusted SSH public keys, see ssh-keygen(1).
+	SSHAllowedSigners string
+}
+
+// Enabled returns true if any trusted keys are configured.
+func (v *SignatureVerification) Enabled() bool {
+	return v != nil && (v.GPGKeyring != "" || v.SSHAllowedSigners != "")
+}
+
+// VerificationError is returned for channel versions which aren't signed by
+// a trusted key.
+type VerificationError struct {
+	Version ConfigVersion
+	Reason  string
+}
+
+func (e *VerificationError) Error() string {
+	return fmt.Sprintf("channel version %s failed signature verification: %s", e.Version, e.Reason)
+}
+
+// signatureVerifier verifies the signatures of the commits in a repository.
+type signatureVerifier struct {
+	config *SignatureVerification
+
+	// trusted keys, loaded by prepare
+	gpgKeyring openpgp.EntityList
+	sshSigners []*allowedSigner
+}
+
+// prepare loads the trusted keys again, so that keys removed from the
+// configured files are no longer trusted. Must not be called concurrently
+// with verify.
+func (v *signatureVerifier) prepare(logger *log.Entry) error {
+	v.gpgKeyring = nil
+	v.sshSigners = nil
+
+	if v.config.GPGKeyring != "" {
+		data, err := ioutil.ReadFile(v.config.GPGKeyring)
+		if err != nil {
+			return err
+		}
+
+		// the keyring is either armored or binary, like gpg --import accepts
+		keyring, err := openpgp.ReadArmoredKeyRing(bytes.NewReader(data))
+		if err != nil {
+			keyring, err = openpgp.ReadKeyRing(bytes.NewReader(data))
+			if err != nil {
+				return fmt.Errorf("unable to read GPG keyring %s: %v", v.config.GPGKeyring, err)
+			}
+		}
+		v.gpgKeyring = keyring
+	}
+
+	if v.config.SSHAllowedSigners != "" {
+		data, err := ioutil.ReadFile(v.config.SSHAllowedSigners)
+		if err != nil {
+			return err
+		}
+
+		signers, err := parseAllowedSigners(bytes.NewReader(data))
+		if err != nil {
+			return fmt.Errorf("unable to read SSH allowed signers %s: %v", v.config.SSHAllowedSigners, err)
+		}
+		v.sshSigners = signers
+	}
+
+	return nil
+}
+
+// verify checks that the commit is signed by a trusted key.
+func (v *signatureVerifier) verify(logger *log.Entry, commit *object.Commit) error {
+	version := ConfigVersion(commit.Hash.String())
+	if commit.PGPSignature == "" {
+		return &VerificationError{Version: version, Reason: "commit is not signed"}
+	}
+
+	// the signature covers the encoded commit without the signature header
+	encoded := &plumbing.MemoryObject{}
+	err := commit.EncodeWithoutSignature(encoded)
+	if err != nil {
+		return err
+	}
+	reader, err := encoded.Reader()
+	if err != nil {
+		return err
+	}
+	payload, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return err
+	}
+
+	signer, err := v.verifySignature(sshGitNamespace, commit.PGPSignature, payload)
+	if err != nil {
+		logger.Debugf("Signature verification of %s failed: %v", version, err)
+		return &VerificationError{Version: version, Reason: "commit is not signed by a trusted key"}
+	}
+
+	logger.Debugf("Commit %s has a valid signature by %s", version, signer)
+	return nil
+}
+
+// verifySignature checks that the armored GPG or SSH signature of payload was
+// made by a trusted key and describes the signer. SSH signatures must be made
+// in the namespace.
+func (v *signatureVerifier) verifySignature(namespace, signature string, payload []byte) (string, error) {
+	if strings.HasPrefix(strings.TrimSpace(signature), sshSignatureBegin) {
+		if len(v.sshSigners) == 0 {
+			return "", fmt.Errorf("no trusted SSH keys")
+		}
+		principals, err := verifySSHSignature(v.sshSigners, namespace, signature, bytes.NewReader(payload), time.Now())
+		if err != nil {
+			return "", err
+		}
+		return "SSH key of " + principals, nil
+	}
+
+	if len(v.gpgKeyring) == 0 {
+		return "", fmt.Errorf("no trusted GPG keys")
+	}
+	err := verifyGPGSignature(v.gpgKeyring, signature, payload, time.Now())
+	if err != nil {
+		return "", err
+	}
+	return "GPG key", nil
+}
+
+// verifyGPGSignature checks that the armored signature of message was made by
+// a key of the keyring which is neither expired nor revoked at the time now.
+func verifyGPGSignature(keyring openpgp.EntityList, armored string, message []byte, now time.Time) error {
+	block, err := armor.Decode(strings.NewReader(armored))
+	if err != nil {
+		return err
+	}
+	p, err := packet.Read(block.Body)
+	if err != nil {
+		return err
+	}
+
+	var issuer uint64
+	switch sig := p.(type) {
+	case *packet.Signature:
+		if sig.IssuerKeyId == nil {
+			return fmt.Errorf("GPG signature has no issuer")
+		}
+		if sig.SigLifetimeSecs != nil && *sig.SigLifetimeSecs != 0 && now.After(sig.CreationTime.Add(time.Duration(*sig.SigLifetimeSecs)*time.Second)) {
+			return fmt.Errorf("GPG signature expired")
+		}
+		issuer = *sig.IssuerKeyId
+	case *packet.SignatureV3:
+		issuer = sig.IssuerKeyId
+	default:
+		return fmt.Errorf("invalid GPG signature")
+	}
+
+	// not every version of the openpgp package checks revocations and none
+	// checks the expiry of keys, so all keys with the ID of the issuer have
+	// to be valid
+	for _, key := range keyring.KeysById(issuer) {
+		err := checkGPGKey(key, now)
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err = openpgp.CheckArmoredDetachedSignature(keyring, bytes.NewReader(message), strings.NewReader(armored))
+	return err
+}
+
+// checkGPGKey returns an error if the key or, for subkeys, its primary key
+// is revoked or expired.
+func checkGPGKey(key openpgp.Key, now time.Time) error {
+	if len(key.Entity.Revocations) > 0 {
+		return fmt.Errorf("GPG key %X is revoked", key.Entity.PrimaryKey.KeyId)
+	}
+	if key.SelfSignature == nil {
+		return fmt.Errorf("GPG key %X has no self-signature", key.PublicKey.KeyId)
+	}
+	if key.SelfSignature.SigType == packet.SigTypeSubkeyRevocation {
+		return fmt.Errorf("GPG subkey %X is revoked", key.PublicKey.KeyId)
+	}
+	if gpgKeyExpired(key.PublicKey, key.SelfSignature, now) {
+		return fmt.Errorf("GPG key %X expired", key.PublicKey.KeyId)
+	}
+
+	if key.PublicKey != key.Entity.PrimaryKey {
+		for _, primary := range (openpgp.EntityList{key.Entity}).KeysById(key.Entity.PrimaryKey.KeyId) {
+			if gpgKeyExpired(primary.PublicKey, primary.SelfSignature, now) {
+				return fmt.Errorf("GPG key %X expired", primary.PublicKey.KeyId)
+			}
+		}
+	}
+	return nil
+}
+
+// gpgKeyExpired returns true if the lifetime of the key set by its
+// self-signature is over. Unlike Signature.KeyExpired, the lifetime is counted
+// from the creation of the key rather than of the self-signature.
+func gpgKeyExpired(key *packet.PublicKey, selfSignature *packet.Signature, now time.Time) bool {
+	if selfSignature == nil || selfSignature.KeyLifetimeSecs == nil || *selfSignature.KeyLifetimeSecs == 0 {
+		return false
+	}
+	return now.After(key.CreationTime.Add(time.Duration(*selfSignature.KeyLifetimeSecs) * time.Second))
+}
+
+func newSignatureVerifier(config *SignatureVerification) *signatureVerifier {
+	return &signatureVerifier{
+		config: config,
+	}
+}
@@ -0,0 +1,221 @@
+package channel
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+	"github.com/zalando-incubator/cluster-lifecycle-manager/pkg/util/command"
+)
+
+// signingRepo is a test repository with a GPG and an SSH signing key.
+type signingRepo struct {
+	t         *testing.T
+	logger    *log.Entry
+	dir       string
+	keysDir   string
+	gnupgHome string
+	sshKey    string
+}
+
+func newSigningRepo(t *testing.T, logger *log.Entry) *signingRepo {
+	keysDir, err := ioutil.TempDir("", "clm-signing-keys")
+	require.NoError(t, err)
+	keysDir, err = filepath.Abs(keysDir)
+	require.NoError(t, err)
+
+	repo := &signingRepo{
+		t:         t,
+		logger:    logger,
+		dir:       path.Join(keysDir, "repo"),
+		keysDir:   keysDir,
+		gnupgHome: path.Join(keysDir, "gnupg"),
+		sshKey:    path.Join(keysDir, "id_ed25519"),
+	}
+
+	require.NoError(t, os.MkdirAll(repo.gnupgHome, 0700))
+	repo.gpg("--quick-gen-key", "go-test <go-test@example.org>", "default", "sign", "never")
+	repo.run(exec.Command("ssh-keygen", "-q", "-t", "ed25519", "-N", "", "-C", "go-test@example.org", "-f", repo.sshKey))
+
+	createGitRepo(t, logger, repo.dir)
+	return repo
+}
+
+func (r *signingRepo) run(cmd *exec.Cmd) string {
+	cmd.Env = append(os.Environ(),
+		"GNUPGHOME="+r.gnupgHome,
+		"GIT_AUTHOR_EMAIL=go-test@example.org",
+		"GIT_AUTHOR_NAME=go-test",
+		"GIT_COMMITTER_EMAIL=go-test@example.org",
+		"GIT_COMMITTER_NAME=go-test",
+	)
+	out, err := command.RunSilently(r.logger, cmd)
+	require.NoError(r.t, err)
+	return strings.TrimSpace(out)
+}
+
+func (r *signingRepo) gpg(args ...string) string {
+	return r.run(exec.Command("gpg", append([]string{"--batch", "--homedir", r.gnupgHome, "--passphrase", ""}, args...)...))
+}
+
+// fakedTimeGPG returns a gpg program for git which runs at the provided time.
+func (r *signingRepo) fakedTimeGPG(timestamp string) string {
+	program := path.Join(r.keysDir, "gpg-"+timestamp)
+	err := ioutil.WriteFile(program, []byte("#!/bin/sh\nexec gpg --faked-system-time "+timestamp+" \"$@\"\n"), 0755)
+	require.NoError(r.t, err)
+	return program
+}
+
+// revokeGPGKey imports the revocation certificate gpg created for the key.
+func (r *signingRepo) revokeGPGKey(uid string) {
+	var fingerprint string
+	for _, line := range strings.Split(r.gpg("--with-colons", "--list-keys", uid), "\n") {
+		if fields := strings.Split(line, ":"); fields[0] == "fpr" && len(fields) > 9 {
+			fingerprint = fields[9]
+			break
+		}
+	}
+	require.NotEmpty(r.t, fingerprint)
+
+	certificate, err := ioutil.ReadFile(path.Join(r.gnupgHome, "openpgp-revocs.d", fingerprint+".rev"))
+	require.NoError(r.t, err)
+
+	// the certificate is disarmed by a leading colon
+	revocation := path.Join(r.keysDir, fingerprint+".rev")
+	err = ioutil.WriteFile(revocation, []byte(strings.Replace(string(certificate), ":-----BEGIN", "-----BEGIN", 1)), 0644)
+	require.NoError(r.t, err)
+	r.gpg("--import", revocation)
+}
+
+// commit creates a commit with the provided git arguments on a new branch
+// and returns its SHA.
+func (r *signingRepo) commit(branch string, args ...string) ConfigVersion {
+	r.run(exec.Command("git", "-C", r.dir, "checkout", "-q", "-b", branch, "master"))
+	err := ioutil.WriteFile(path.Join(r.dir, branch), []byte(branch), 0644)
+	require.NoError(r.t, err)
+	r.run(exec.Command("git", "-C", r.dir, "add", branch))
+
+	gitArgs := append([]string{"-C", r.dir}, args...)
+	gitArgs = append(gitArgs, "commit", "-q", "-m", branch)
+	r.run(exec.Command("git", gitArgs...))
+
+	return ConfigVersion(r.run(exec.Command("git", "-C", r.dir, "rev-parse", "HEAD")))
+}
+
+func (r *signingRepo) gpgKeyring() string {
+	keyring := path.Join(r.keysDir, "keyring.asc")
+	err := ioutil.WriteFile(keyring, []byte(r.gpg("--export", "--armor")), 0644)
+	require.NoError(r.t, err)
+	return keyring
+}
+
+// allowedSigners writes an allowed signers file with the SSH key and the
+// options.
+func (r *signingRepo) allowedSigners(options string) string {
+	publicKey, err := ioutil.ReadFile(r.sshKey + ".pub")
+	require.NoError(r.t, err)
+
+	allowedSigners, err := ioutil.TempFile(r.keysDir, "allowed_signers")
+	require.NoError(r.t, err)
+	defer allowedSigners.Close()
+
+	_, err = allowedSigners.WriteString(strings.TrimSpace("go-test@example.org "+options) + " " + string(publicKey))
+	require.NoError(r.t, err)
+	return allowedSigners.Name()
+}
+
+func TestGitSignatureVerification(t *testing.T) {
+	logger := log.StandardLogger().WithFields(map[string]interface{}{})
+
+	repo := newSigningRepo(t, logger)
+	defer os.RemoveAll(repo.keysDir)
+
+	gpgSigned := repo.commit("gpg-signed", "-c", "commit.gpgsign=true")
+	sshSigned := repo.commit("ssh-signed", "-c", "commit.gpgsign=true", "-c", "gpg.format=ssh", "-c", "user.signingkey="+repo.sshKey)
+	unsigned := repo.commit("unsigned")
+
+	// signed by keys which expired or were revoked since
+	repo.gpg("--faked-system-time", "20200101T000000", "--quick-gen-key", "expired <expired@example.org>", "default", "sign", "1d")
+	expiredGPGSigned := repo.commit("expired-gpg-signed", "-c", "commit.gpgsign=true", "-c", "user.signingkey=expired@example.org", "-c", "gpg.program="+repo.fakedTimeGPG("20200101T000000"))
+	repo.gpg("--quick-gen-key", "revoked <revoked@example.org>", "default", "sign", "never")
+	revokedGPGSigned := repo.commit("revoked-gpg-signed", "-c", "commit.gpgsign=true", "-c", "user.signingkey=revoked@example.org")
+	repo.revokeGPGKey("revoked@example.org")
+
+	for _, tc := range []struct {
+		msg          string
+		verification *SignatureVerification
+		accepted     []ConfigVersion
+		refused      []ConfigVersion
+	}{
+		{
+			msg:      "verification disabled",
+			accepted: []ConfigVersion{gpgSigned, sshSigned, unsigned, expiredGPGSigned, revokedGPGSigned},
+		},
+		{
+			msg:          "GPG keyring",
+			verification: &SignatureVerification{GPGKeyring: repo.gpgKeyring()},
+			accepted:     []ConfigVersion{gpgSigned},
+			refused:      []ConfigVersion{sshSigned, unsigned, expiredGPGSigned, revokedGPGSigned},
+		},
+		{
+			msg:          "SSH allowed signers",
+			verification: &SignatureVerification{SSHAllowedSigners: repo.allowedSigners("")},
+			accepted:     []ConfigVersion{sshSigned},
+			refused:      []ConfigVersion{gpgSigned, unsigned},
+		},
+		{
+			msg:          "SSH signer outside of its validity interval",
+			verification: &SignatureVerification{SSHAllowedSigners: repo.allowedSigners(`valid-before="20000101"`)},
+			refused:      []ConfigVersion{sshSigned},
+		},
+		{
+			msg:          "SSH signer within its validity interval",
+			verification: &SignatureVerification{SSHAllowedSigners: repo.allowedSigners(`valid-after="20000101"`)},
+			accepted:     []ConfigVersion{sshSigned},
+		},
+		{
+			msg:          "GPG and SSH",
+			verification: &SignatureVerification{GPGKeyring: repo.gpgKeyring(), SSHAllowedSigners: repo.allowedSigners("")},
+			accepted:     []ConfigVersion{gpgSigned, sshSigned},
+			refused:      []ConfigVersion{unsigned, expiredGPGSigned, revokedGPGSigned},
+		},
+	} {
+		t.Run(tc.msg, func(t *testing.T) {
+			workdir := path.Join(repo.keysDir, "workdir")
+			defer os.RemoveAll(workdir)
+
+			source, err := NewGit(context.Background(), workdir, repo.dir, nil, tc.verification)
+			require.NoError(t, err)
+
+			_, err = source.Update(logger)
+			require.NoError(t, err)
+
+			for _, version := range tc.accepted {
+				config, err := source.Get(logger, version)
+				require.NoError(t, err)
+				require.NoError(t, source.Delete(logger, config))
+			}
+
+			for _, version := range tc.refused {
+				_, err := source.Get(logger, version)
+				require.Error(t, err)
+
+				verificationErr, ok := err.(*VerificationError)
+				require.True(t, ok, "unexpected error: %v", err)
+				require.Equal(t, version, verificationErr.Version)
+				if version == unsigned {
+					require.Equal(t, "commit is not signed", verificationErr.Reason)
+				} else {
+					require.Equal(t, "commit is not signed by a trusted key", verificationErr.Reason)
+				}
+			}
+		})
+	}
+}
@@ -2,12 +2,18 @@ package main
 
 import (
 	"context"
+	"fmt"
 	"net/http"
 	"os"
 	"os/signal"
 	"sort"
 	"syscall"
+	"text/tabwriter"
+	"time"
 
+	awssdk "github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	log "github.com/sirupsen/logrus"
 	"github.com/zalando-incubator/cluster-lifecycle-manager/api"
 	"golang.org/x/oauth2"
@@ -16,9 +22,14 @@ import (
 	"github.com/zalando-incubator/cluster-lifecycle-manager/channel"
 	"github.com/zalando-incubator/cluster-lifecycle-manager/config"
 	"github.com/zalando-incubator/cluster-lifecycle-manager/controller"
+	"github.com/zalando-incubator/cluster-lifecycle-manager/history"
+	"github.com/zalando-incubator/cluster-lifecycle-manager/notifier"
 	"github.com/zalando-incubator/cluster-lifecycle-manager/pkg/aws"
 	"github.com/zalando-incubator/cluster-lifecycle-manager/pkg/credentials-loader/platformiam"
 	"github.com/zalando-incubator/cluster-lifecycle-manager/pkg/decrypter"
+	"github.com/zalando-incubator/cluster-lifecycle-manager/pkg/leaderelection"
+	"github.com/zalando-incubator/cluster-lifecycle-manager/pkg/sharding"
+	"github.com/zalando-incubator/cluster-lifecycle-manager/pkg/tracing"
 	"github.com/zalando-incubator/cluster-lifecycle-manager/provisioner"
 	"github.com/zalando-incubator/cluster-lifecycle-manager/registry"
 )
@@ -26,7 +37,10 @@ import (
 var (
 	provisionCmd    = kingpin.Command("provision", "Provision a cluster.")
 	decommissionCmd = kingpin.Command("decommission", "Decommission a cluster.")
+	planCmd         = kingpin.Command("plan", "Show what provisioning a cluster would change.")
 	controllerCmd   = kingpin.Command("controller", "Run controller loop.")
+	historyCmd      = kingpin.Command("history", "Show the update history of clusters.")
+	historyCluster  = historyCmd.Arg("cluster", "ID of the cluster to show the history of. Shows all clusters if not set.").String()
 	version         = "unknown"
 )
 
@@ -35,6 +49,16 @@ func main() {
 
 	command := cfg.ParseFlags()
 
+	// the history is read from the local store and doesn't need any of the
+	// other configuration
+	if command == historyCmd.FullCommand() {
+		err := printHistory(cfg.History, *historyCluster)
+		if err != nil {
+			log.Fatalf("Failed to read update history: %v", err)
+		}
+		os.Exit(0)
+	}
+
 	if err := cfg.ValidateFlags(); err != nil {
 		log.Fatalf("Incorrectly configured flag: %v", err)
 	}
@@ -43,6 +67,12 @@ func main() {
 		log.SetLevel(log.DebugLevel)
 	}
 
+	shutdownTracing, err := tracing.Setup(cfg.Tracing)
+	if err != nil {
+		log.Fatalf("Failed to setup tracing: %v", err)
+	}
+	defer shutdownTracing()
+
 	var registryTokenSource, clusterTokenSource oauth2.TokenSource
 
 	if cfg.Token != "" {
@@ -77,13 +107,42 @@ func main() {
 		RemoveVolumes:  cfg.RemoveVolumes,
 	})
 
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
 	var configSource channel.ConfigSource
 
+	verification := &channel.SignatureVerification{
+		GPGKeyring:        cfg.GitGPGKeyring,
+		SSHAllowedSigners: cfg.GitAllowedSigners,
+	}
+
 	if cfg.Directory != "" {
 		configSource = channel.NewDirectory(cfg.Directory)
+	} else if cfg.ArchiveURL != "" {
+		var err error
+		s3Client := s3.New(sess)
+		if cfg.ArchiveS3Endpoint != "" {
+			s3Client = s3.New(sess, &awssdk.Config{
+				Endpoint:         awssdk.String(cfg.ArchiveS3Endpoint),
+				S3ForcePathStyle: awssdk.Bool(true),
+			})
+		}
+		configSource, err = channel.NewArchive(ctx, cfg.Workdir, cfg.ArchiveURL, &channel.ArchiveOptions{S3Client: s3Client, Verification: verification})
+		if err != nil {
+			log.Fatalf("Failed to setup archive channel config source: %v", err)
+		}
 	} else {
 		var err error
-		configSource, err = channel.NewGit(cfg.Workdir, cfg.GitRepositoryURL, cfg.SSHPrivateKeyFile)
+		auth := &channel.GitAuth{
+			SSHPrivateKeyFile: cfg.SSHPrivateKeyFile,
+			SSHKnownHostsFile: cfg.SSHKnownHostsFile,
+			TokenUsername:     cfg.GitTokenUsername,
+		}
+		if cfg.GitTokenName != "" {
+			auth.TokenSource = platformiam.NewTokenSource(cfg.GitTokenName, cfg.CredentialsDir)
+		}
+		configSource, err = channel.NewGit(ctx, cfg.Workdir, cfg.GitRepositoryURL, auth, verification)
 		if err != nil {
 			log.Fatalf("Failed to setup git channel config source: %v", err)
 		}
@@ -92,22 +151,72 @@ func main() {
 	if command == controllerCmd.FullCommand() {
 		log.Info("Running control loop")
 
-		go serveHealthCheck(cfg.Listen)
-
 		opts := &controller.Options{
 			AccountFilter:     cfg.AccountFilter,
 			Interval:          cfg.Interval,
 			DryRun:            cfg.DryRun,
 			ConcurrentUpdates: cfg.ConcurrentUpdates,
 			EnvironmentOrder:  cfg.EnvironmentOrder,
+			AdminToken:        cfg.AdminToken,
+			WebhookSecret:     cfg.WebhookSecret,
+			RefreshDebounce:   cfg.RefreshDebounce,
+			Rollout:           cfg.Rollout,
+			Rollback:          cfg.Rollback,
+			Backoff:           cfg.Backoff,
+
+			UrgentIgnoresMaintenanceWindow: cfg.UrgentIgnoresMaintenanceWindow,
+			AdmissionConstraints:           controller.ConcurrencyLimits(cfg.ConcurrencyLimits),
+		}
+
+		if cfg.History != "" {
+			opts.History, err = history.NewStore(cfg.History)
+			if err != nil {
+				log.Fatalf("Failed to setup update history store: %v", err)
+			}
+		}
+
+		if cfg.Notifications != "" {
+			opts.Notifier, err = notifier.NewFromFile(rootLogger, cfg.Notifications)
+			if err != nil {
+				log.Fatalf("Failed to setup notifications: %v", err)
+			}
+		}
+
+		if cfg.FreezeCalendar != "" {
+			opts.FreezeCalendar, err = controller.NewFreezeCalendarSource(cfg.FreezeCalendar, configSource)
+			if err != nil {
+				log.Fatalf("Failed to setup freeze calendar: %v", err)
+			}
+		}
+
+		if cfg.Sharding.Enabled() {
+			sharder, err := newSharder(rootLogger, cfg.Sharding)
+			if err != nil {
+				log.Fatalf("Failed to setup sharding: %v", err)
+			}
+			go sharder.Run(ctx)
+			opts.Shard = sharder
 		}
 
 		ctrl := controller.New(rootLogger, clusterRegistry, p, configSource, opts)
 
-		ctx, cancel := context.WithCancel(context.Background())
-		go handleSigterm(cancel)
-		ctrl.Run(ctx)
+		go serveHTTP(cfg.Listen, ctrl)
+		go handleSigterm(cancel, ctrl, cfg.ShutdownGracePeriod)
 
+		if cfg.LeaderElection.Enabled() {
+			elector, err := newElector(rootLogger, cfg.LeaderElection)
+			if err != nil {
+				log.Fatalf("Failed to setup leader election: %v", err)
+			}
+			elector.Run(ctx, ctrl.Run)
+		} else {
+			ctrl.Run(ctx)
+		}
+
+		if opts.Notifier != nil {
+			opts.Notifier.Shutdown()
+		}
+		shutdownTracing()
 		os.Exit(0)
 	}
 
@@ -155,6 +264,20 @@ func main() {
 				log.Fatalf("Fail to provision: %v", err)
 			}
 			log.Infof("Provisioning done for cluster %s", cluster.ID)
+		case planCmd.FullCommand():
+			planner, ok := p.(provisioner.Planner)
+			if !ok {
+				log.Fatalf("Provisioner doesn't support planning")
+			}
+			log.Infof("Planning cluster %s", cluster.ID)
+			plan, err := planner.Plan(context.Background(), rootLogger, cluster, config)
+			if err != nil {
+				log.Fatalf("Fail to plan: %v", err)
+			}
+			err = plan.Write(os.Stdout)
+			if err != nil {
+				log.Fatalf("Fail to write plan: %v", err)
+			}
 		case decommissionCmd.FullCommand():
 			log.Infof("Decommissioning cluster %s", cluster.ID)
 			err = p.Decommission(rootLogger, cluster, config)
@@ -185,17 +308,108 @@ func orderByEnvironmentOrder(clusters []*api.Cluster, environmentOrder []string)
 	})
 }
 
-func serveHealthCheck(listen string) {
+// newElector initializes the leader election.
+func newElector(logger *log.Entry, cfg config.LeaderElectionConfig) (*leaderelection.Elector, error) {
+	lock, err := leaderelection.NewLock(cfg.Lock)
+	if err != nil {
+		return nil, err
+	}
+
+	identity, err := instanceIdentity(cfg.Identity)
+	if err != nil {
+		return nil, err
+	}
+
+	return leaderelection.NewElector(logger, lock, identity, cfg.LeaseDuration), nil
+}
+
+// newSharder initializes the sharding of the clusters across several
+// instances.
+func newSharder(logger *log.Entry, cfg config.ShardingConfig) (*sharding.Sharder, error) {
+	membership, err := sharding.NewMembership(cfg.Members)
+	if err != nil {
+		return nil, err
+	}
+
+	identity, err := instanceIdentity(cfg.Identity)
+	if err != nil {
+		return nil, err
+	}
+
+	return sharding.NewSharder(logger, membership, identity, cfg.TTL), nil
+}
+
+// instanceIdentity returns the configured identity of this instance or the
+// hostname, which is the pod name when running in Kubernetes.
+func instanceIdentity(identity string) (string, error) {
+	if identity != "" {
+		return identity, nil
+	}
+	return os.Hostname()
+}
+
+// printHistory prints the update history of a cluster, or of all clusters if
+// clusterID is empty.
+func printHistory(location string, clusterID string) error {
+	if location == "" {
+		return fmt.Errorf("--history must be specified")
+	}
+
+	store, err := history.NewStore(location)
+	if err != nil {
+		return err
+	}
+
+	entries, err := store.List(clusterID)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "STARTED\tDURATION\tCLUSTER\tSTATUS\tFROM\tTO\tWORKER\tOUTCOME\tERROR")
+	for _, entry := range entries {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%d\t%s\t%s\n",
+			entry.Started.Format(time.RFC3339),
+			entry.Finished.Sub(entry.Started).Round(time.Second),
+			entry.ClusterID,
+			entry.LifecycleStatus,
+			entry.FromVersion,
+			entry.ToVersion,
+			entry.Worker,
+			entry.Outcome,
+			entry.Error)
+	}
+	return w.Flush()
+}
+
+// serveHTTP serves the health check, the metrics and the controller status
+// endpoints.
+func serveHTTP(listen string, ctrl *controller.Controller) {
 	http.HandleFunc("/healthz", func(w http.ResponseWriter, _ *http.Request) {
 		w.WriteHeader(http.StatusOK)
 	})
+	http.Handle("/metrics", promhttp.Handler())
+	http.Handle("/", ctrl.Handler())
 	http.ListenAndServe(listen, nil)
 }
 
-func handleSigterm(cancelFunc func()) {
+// handleSigterm stops the controller on termination. Within the grace period
+// the updates in progress may stop at a safe point, afterwards or on a second
+// signal they're canceled.
+func handleSigterm(cancelFunc func(), ctrl *controller.Controller, gracePeriod time.Duration) {
 	signals := make(chan os.Signal, 1)
 	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM, syscall.SIGQUIT)
 	<-signals
 	log.Info("Received Term signal. Terminating...")
+
+	if gracePeriod > 0 {
+		log.Infof("Waiting up to %s for the updates in progress to stop", gracePeriod)
+		ctrl.Stop()
+
+		select {
+		case <-time.After(gracePeriod):
+		case <-signals:
+		}
+	}
 	cancelFunc()
 }
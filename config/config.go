@@ -27,6 +27,15 @@ const (
 	defaultDrainForceEvictInterval          = "5m"
 	defaultDrainPollInterval                = "30s"
 	defaultUpdateStrategy                   = "rolling"
+	defaultNodePoolUpdateTimeout            = "0s"
+	defaultRolloutSoakTime                  = "0s"
+	defaultFailureBackoff                   = "10m"
+	defaultFailureBackoffMax                = "6h"
+	defaultLeaderElectionLeaseDuration      = "15s"
+	defaultShardingMemberTTL                = "30s"
+	defaultRefreshDebounce                  = "10s"
+	defaultShutdownGracePeriod              = "0s"
+	defaultGitTokenUsername                 = "oauth2"
 )
 
 var defaultWorkdir = path.Join(os.TempDir(), "clm-workdir")
@@ -48,7 +57,14 @@ type LifecycleManagerConfig struct {
 	Workdir             string
 	Directory           string
 	GitRepositoryURL    string
+	ArchiveURL          string
+	ArchiveS3Endpoint   string
 	SSHPrivateKeyFile   string
+	SSHKnownHostsFile   string
+	GitTokenName        string
+	GitTokenUsername    string
+	GitGPGKeyring       string
+	GitAllowedSigners   string
 	CredentialsDir      string
 	EnvironmentOrder    []string
 	ApplyOnly           bool
@@ -56,15 +72,162 @@ type LifecycleManagerConfig struct {
 	AwsMaxRetryInterval time.Duration
 	UpdateStrategy      UpdateStrategy
 	RemoveVolumes       bool
+	AdminToken          string
+	WebhookSecret       string
+	RefreshDebounce     time.Duration
+	ShutdownGracePeriod time.Duration
+	History             string
+	Notifications       string
+	Tracing             string
+	FreezeCalendar      string
+	Rollout             RolloutConfig
+	Rollback            RollbackConfig
+	Backoff             BackoffConfig
+	ConcurrencyLimits   ConcurrencyLimits
+	LeaderElection      LeaderElectionConfig
+	Sharding            ShardingConfig
+
+	UrgentIgnoresMaintenanceWindow bool
 }
 
 // UpdateStrategy defines the default update strategy configured for the
-// Cluster Lifecycle Manager. It includes a named strategy and a max evict
-// timeout. The default strategy can be overwritten with a config item per
+// Cluster Lifecycle Manager. It includes a named strategy, the drain settings
+// and a timeout of the update of a node pool. The default strategy can be overwritten with a config item per
 // cluster.
 type UpdateStrategy struct {
 	updatestrategy.DrainConfig
 	Strategy string
+	// Timeout limits the update of a single node pool, no limit if 0.
+	Timeout time.Duration
+}
+
+// RolloutConfig defines how new channel versions are rolled out to the
+// clusters of an environment. The clusters are updated in waves: first the
+// canary clusters, then the remaining clusters in the configured cumulative
+// percentages. The next wave is only started once all clusters of the
+// previous waves are updated, don't report any problems and the soak time
+// has passed.
+type RolloutConfig struct {
+	Waves                  []uint
+	CanaryCriticalityLevel int32
+	SoakTime               time.Duration
+	Environments           []string
+}
+
+// Enabled returns true if clusters are updated in waves.
+func (c RolloutConfig) Enabled() bool {
+	return len(c.Waves) > 0 || c.CanaryCriticalityLevel > 0
+}
+
+// RollbackConfig defines when a cluster is provisioned again at its last
+// known good channel version after an update to a new one failed.
+type RollbackConfig struct {
+	// FailedAttempts is the number of failed update attempts after which
+	// a cluster is rolled back. Disabled if 0.
+	FailedAttempts uint
+	// HealthCheck enables a health check after every update. A cluster
+	// which fails the health check is rolled back immediately.
+	HealthCheck bool
+}
+
+// Enabled returns true if any rollback policy is configured.
+func (c RollbackConfig) Enabled() bool {
+	return c.FailedAttempts > 0 || c.HealthCheck
+}
+
+// BackoffConfig defines how long a cluster whose updates keep failing waits
+// before it's retried.
+type BackoffConfig struct {
+	// Initial is the delay after the first failed attempt. It doubles with
+	// every further failed attempt. Disabled if 0.
+	Initial time.Duration
+	// Max is the maximum delay between two attempts, defaultBackoffMax if
+	// 0.
+	Max time.Duration
+	// MaxRetries is the number of retries after which a cluster is no
+	// longer updated until its next version changes. Unlimited if 0.
+	MaxRetries uint
+}
+
+const (
+	// defaultBackoffMax is the maximum delay if none is configured, the
+	// same as the default of --failure-backoff-max.
+	defaultBackoffMax = 6 * time.Hour
+	// maxBackoffShift limits the doublings of the delay, a duration
+	// doubled more often overflows.
+	maxBackoffShift = 62
+)
+
+// Delay returns the time to wait after the provided number of consecutive
+// failed attempts.
+func (c BackoffConfig) Delay(attempts int) time.Duration {
+	if c.Initial <= 0 {
+		return 0
+	}
+
+	limit := c.Max
+	if limit <= 0 {
+		limit = defaultBackoffMax
+	}
+
+	shift := uint(0)
+	if attempts > 1 {
+		shift = uint(attempts - 1)
+	}
+	if shift > maxBackoffShift {
+		shift = maxBackoffShift
+	}
+
+	// shifting back first avoids overflowing the delay
+	if c.Initial > limit>>shift {
+		return limit
+	}
+	return c.Initial << shift
+}
+
+// Exhausted returns true if a cluster shouldn't be retried anymore after the
+// provided number of consecutive failed attempts.
+func (c BackoffConfig) Exhausted(attempts int) bool {
+	return c.MaxRetries > 0 && attempts > int(c.MaxRetries)
+}
+
+// ConcurrencyLimits limits the number of clusters updated concurrently
+// within the same infrastructure account, region or environment, in addition
+// to the global limit of concurrent updates. Limits set to 0 are disabled.
+type ConcurrencyLimits struct {
+	PerAccount     uint
+	PerRegion      uint
+	PerEnvironment uint
+}
+
+// LeaderElectionConfig defines how several instances of the controller elect
+// the single one updating clusters.
+type LeaderElectionConfig struct {
+	// Lock is the location of the lock, e.g.
+	// kubernetes://<namespace>/<name>. Disabled if empty.
+	Lock          string
+	Identity      string
+	LeaseDuration time.Duration
+}
+
+// Enabled returns true if leader election is configured.
+func (c LeaderElectionConfig) Enabled() bool {
+	return c.Lock != ""
+}
+
+// ShardingConfig defines how the clusters are sharded across several
+// instances of the controller.
+type ShardingConfig struct {
+	// Members is the location where the instances keep track of each
+	// other, e.g. kubernetes://<namespace>/<name>. Disabled if empty.
+	Members  string
+	Identity string
+	TTL      time.Duration
+}
+
+// Enabled returns true if sharding is configured.
+func (c ShardingConfig) Enabled() bool {
+	return c.Members != ""
 }
 
 // New returns the app wide configuration file
@@ -75,8 +238,41 @@ func New(version string) *LifecycleManagerConfig {
 
 // ValidateFlags for custom flag validation, e.g. check for the interval being not too short
 func (cfg *LifecycleManagerConfig) ValidateFlags() error {
-	if cfg.GitRepositoryURL == "" && cfg.Directory == "" {
-		return fmt.Errorf("Either --git-repository-url or --directory must be specified")
+	if cfg.GitRepositoryURL == "" && cfg.Directory == "" && cfg.ArchiveURL == "" {
+		return fmt.Errorf("Either --git-repository-url, --archive-url or --directory must be specified")
+	}
+
+	if cfg.ArchiveURL != "" && (cfg.GitRepositoryURL != "" || cfg.Directory != "") {
+		return fmt.Errorf("--archive-url can't be combined with --git-repository-url or --directory")
+	}
+
+	if cfg.Directory != "" && (cfg.GitGPGKeyring != "" || cfg.GitAllowedSigners != "") {
+		return fmt.Errorf("Signature verification is only supported with --git-repository-url or --archive-url")
+	}
+
+	var lastWave uint
+	for _, wave := range cfg.Rollout.Waves {
+		if wave <= lastWave || wave > 100 {
+			return fmt.Errorf("--rollout-wave must be increasing percentages between 1 and 100")
+		}
+		lastWave = wave
+	}
+
+	if cfg.Rollback.Enabled() && cfg.History == "" {
+		return fmt.Errorf("--rollback-after-failures and --rollback-on-failed-health-check require --history to resume rollbacks after a restart")
+	}
+
+	if cfg.LeaderElection.Enabled() && cfg.LeaderElection.LeaseDuration < 3*time.Second {
+		return fmt.Errorf("--leader-election-lease-duration must be at least 3s")
+	}
+
+	if cfg.Sharding.Enabled() {
+		if cfg.LeaderElection.Enabled() {
+			return fmt.Errorf("--sharding and --leader-election can't be combined")
+		}
+		if cfg.Sharding.TTL < 3*time.Second {
+			return fmt.Errorf("--sharding-member-ttl must be at least 3s")
+		}
 	}
 	return nil
 }
@@ -98,8 +294,18 @@ func (cfg *LifecycleManagerConfig) ParseFlags() string {
 	kingpin.Flag("workdir", "Path to working directory used for storing channel configurations.").Default(defaultWorkdir).StringVar(&cfg.Workdir)
 	kingpin.Flag("directory", "Path of a directory to use as channel config source.").StringVar(&cfg.Directory)
 	kingpin.Flag("git-repository-url", "URL of the git repository to use as channel config source.").StringVar(&cfg.GitRepositoryURL)
+	kingpin.Flag("archive-url", "URL of the channel index and the archives of the channel config versions, either https://<url> or s3://<bucket>/<prefix>. http://<url> requires a signed index.").StringVar(&cfg.ArchiveURL)
+	kingpin.Flag("archive-s3-endpoint", "Endpoint of an S3 compatible storage to use for s3:// archive URLs instead of AWS S3.").StringVar(&cfg.ArchiveS3Endpoint)
 	kingpin.Flag("concurrent-updates", "Number of updates allowed to run in parallel.").Default(defaultConcurrentUpdates).UintVar(&cfg.ConcurrentUpdates)
+	kingpin.Flag("max-concurrent-updates-per-account", "Number of clusters allowed to be updated in parallel in the same infrastructure account. Unlimited if not set.").UintVar(&cfg.ConcurrencyLimits.PerAccount)
+	kingpin.Flag("max-concurrent-updates-per-region", "Number of clusters allowed to be updated in parallel in the same region. Unlimited if not set.").UintVar(&cfg.ConcurrencyLimits.PerRegion)
+	kingpin.Flag("max-concurrent-updates-per-environment", "Number of clusters allowed to be updated in parallel in the same environment. Unlimited if not set.").UintVar(&cfg.ConcurrencyLimits.PerEnvironment)
 	kingpin.Flag("ssh-private-key-path", "Path to SSH private key used when pulling from a private git repository.").Envar("SSH_PRIVATE_KEY_PATH").StringVar(&cfg.SSHPrivateKeyFile)
+	kingpin.Flag("ssh-known-hosts-path", "Path to the known_hosts file used to verify the SSH host key of the git server. Defaults to ~/.ssh/known_hosts and /etc/ssh/ssh_known_hosts.").Envar("SSH_KNOWN_HOSTS_PATH").StringVar(&cfg.SSHKnownHostsFile)
+	kingpin.Flag("git-token-name", "Name of the token used when pulling from a private git repository over HTTPS.").StringVar(&cfg.GitTokenName)
+	kingpin.Flag("git-token-username", "Username sent along with the token when pulling over HTTPS.").Default(defaultGitTokenUsername).StringVar(&cfg.GitTokenUsername)
+	kingpin.Flag("git-gpg-keyring", "Path to a file with the GPG public keys trusted to sign the commits of the channel configuration or the archive index. Unsigned commits and indexes are refused if this or --git-allowed-signers is set.").StringVar(&cfg.GitGPGKeyring)
+	kingpin.Flag("git-allowed-signers", "Path to an SSH allowed signers file with the keys trusted to sign the commits of the channel configuration or the archive index.").StringVar(&cfg.GitAllowedSigners)
 	kingpin.Flag("credentials-dir", "Path to OAuth credentials").Envar("CREDENTIALS_DIR").Default(defaultCredentialsDir).StringVar(&cfg.CredentialsDir)
 	kingpin.Flag("apply-only", "Enable apply only mode which will only apply CloudFormation stacks and manifests, but not do any rolling of nodes.").BoolVar(&cfg.ApplyOnly)
 	kingpin.Flag("aws-max-retries", "Maximum number of retries for AWS SDK requests.").Default(defaultAwsMaxRetries).IntVar(&cfg.AwsMaxRetries)
@@ -111,7 +317,32 @@ func (cfg *LifecycleManagerConfig) ParseFlags() string {
 	kingpin.Flag("drain-force-evict-interval", "Interval between forced terminations of pods on the same node.").Default(defaultDrainForceEvictInterval).DurationVar(&cfg.UpdateStrategy.ForceEvictionInterval)
 	kingpin.Flag("drain-poll-interval", "Interval between drain attempts.").Default(defaultDrainPollInterval).DurationVar(&cfg.UpdateStrategy.PollInterval)
 	kingpin.Flag("update-strategy", "Update strategy to use when updating node pools.").Default(defaultUpdateStrategy).EnumVar(&cfg.UpdateStrategy.Strategy, "rolling")
+	kingpin.Flag("node-pool-update-timeout", "Maximum duration of the update of a single node pool, e.g. because its nodes can't be drained. No limit if 0.").Default(defaultNodePoolUpdateTimeout).DurationVar(&cfg.UpdateStrategy.Timeout)
 	kingpin.Flag("remove-volumes", "Remove EBS volumes when decommissioning.").BoolVar(&cfg.RemoveVolumes)
 	kingpin.Flag("environment-order", "Roll out channel updates to the environments in a specific order.").StringsVar(&cfg.EnvironmentOrder)
+	kingpin.Flag("admin-token", "Bearer token required for the admin API. The admin API is disabled if not set.").Envar("ADMIN_TOKEN").StringVar(&cfg.AdminToken)
+	kingpin.Flag("webhook-secret", "Secret authenticating the webhooks which trigger a refresh of the channels and the cluster list. Webhooks are disabled if not set.").Envar("WEBHOOK_SECRET").StringVar(&cfg.WebhookSecret)
+	kingpin.Flag("refresh-debounce", "Time to wait after a webhook requested a refresh, to combine the requests arriving in the meantime into a single refresh.").Default(defaultRefreshDebounce).DurationVar(&cfg.RefreshDebounce)
+	kingpin.Flag("shutdown-grace-period", "Time to wait on termination for the updates in progress to stop at a safe point before they are canceled. No new updates are started in the meantime.").Default(defaultShutdownGracePeriod).DurationVar(&cfg.ShutdownGracePeriod)
+	kingpin.Flag("history", "Location of the update history store, e.g. file:///var/lib/clm/history. The update history is not recorded if not set.").Envar("HISTORY").StringVar(&cfg.History)
+	kingpin.Flag("notifications", "Path to a YAML file configuring the sinks notified about cluster lifecycle events. Notifications are disabled if not set.").StringVar(&cfg.Notifications)
+	kingpin.Flag("tracing", "Where to export the traces of cluster updates: an OTLP/HTTP endpoint (http(s)://<collector>), file:///<path> or stdout://. Tracing is disabled if not set.").StringVar(&cfg.Tracing)
+	kingpin.Flag("freeze-calendar", "Location of the calendar of fleet-wide change freezes: a local file (file:///<path>) or a file in the channel configuration (channel://<channel>/<path>). No freezes apply if not set.").StringVar(&cfg.FreezeCalendar)
+	kingpin.Flag("rollout-wave", "Cumulative percentage of the clusters of an environment to update in a rollout wave, e.g. --rollout-wave=10 --rollout-wave=50. Clusters not covered by any wave are updated in a final wave.").UintsVar(&cfg.Rollout.Waves)
+	kingpin.Flag("rollout-canary-criticality-level", "Clusters with a criticality level up to this value are updated as canaries before any other cluster of their environment. Disabled if not set.").Int32Var(&cfg.Rollout.CanaryCriticalityLevel)
+	kingpin.Flag("rollout-soak-time", "Time to wait after a rollout wave is complete before starting the next one.").Default(defaultRolloutSoakTime).DurationVar(&cfg.Rollout.SoakTime)
+	kingpin.Flag("rollout-environment", "Environment to roll out channel updates in waves. Applies to all environments if not set.").StringsVar(&cfg.Rollout.Environments)
+	kingpin.Flag("urgent-updates-ignore-maintenance-window", "Decommission clusters and continue interrupted updates even outside of the maintenance windows of a cluster.").BoolVar(&cfg.UrgentIgnoresMaintenanceWindow)
+	kingpin.Flag("failure-backoff", "Time to wait before retrying a cluster after a failed update. Doubles with every consecutive failure. Disabled if 0.").Default(defaultFailureBackoff).DurationVar(&cfg.Backoff.Initial)
+	kingpin.Flag("failure-backoff-max", "Maximum time to wait before retrying a cluster after a failed update.").Default(defaultFailureBackoffMax).DurationVar(&cfg.Backoff.Max)
+	kingpin.Flag("failure-max-retries", "Number of retries after which a failing cluster is no longer updated until its next version changes. Unlimited if not set.").UintVar(&cfg.Backoff.MaxRetries)
+	kingpin.Flag("rollback-after-failures", "Roll back a cluster to its last known good channel version after this many failed update attempts. Disabled if not set.").UintVar(&cfg.Rollback.FailedAttempts)
+	kingpin.Flag("rollback-on-failed-health-check", "Check the health of a cluster after every update and roll it back to its last known good channel version if the check fails.").BoolVar(&cfg.Rollback.HealthCheck)
+	kingpin.Flag("leader-election", "Location of the leader election lock, either a ConfigMap in the cluster CLM is running in (kubernetes://<namespace>/<name>) or a lock file (file:///path/to/lock). Only the leader updates clusters. Disabled if not set.").StringVar(&cfg.LeaderElection.Lock)
+	kingpin.Flag("leader-election-identity", "Identity of this instance in the leader election. Defaults to the hostname.").StringVar(&cfg.LeaderElection.Identity)
+	kingpin.Flag("leader-election-lease-duration", "Time after which another instance may take over if the leader stops renewing its lease.").Default(defaultLeaderElectionLeaseDuration).DurationVar(&cfg.LeaderElection.LeaseDuration)
+	kingpin.Flag("sharding", "Location where several instances keep track of each other to shard the clusters among them, either a ConfigMap in the cluster CLM is running in (kubernetes://<namespace>/<name>) or a directory (file:///path/to/dir). Disabled if not set.").StringVar(&cfg.Sharding.Members)
+	kingpin.Flag("sharding-identity", "Identity of this instance among the shard members. Defaults to the hostname.").StringVar(&cfg.Sharding.Identity)
+	kingpin.Flag("sharding-member-ttl", "Time after which an instance which stopped sending heartbeats is no longer a shard member and its clusters are reassigned.").Default(defaultShardingMemberTTL).DurationVar(&cfg.Sharding.TTL)
 	return kingpin.Parse()
 }
@@ -0,0 +1,94 @@
+package config
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBackoffConfig(t *testing.T) {
+	backoff := BackoffConfig{
+		Initial:    10 * time.Minute,
+		Max:        time.Hour,
+		MaxRetries: 3,
+	}
+
+	for attempts, expected := range map[int]time.Duration{
+		1:   10 * time.Minute,
+		2:   20 * time.Minute,
+		3:   40 * time.Minute,
+		4:   time.Hour,
+		100: time.Hour,
+	} {
+		require.Equal(t, expected, backoff.Delay(attempts), "attempts: %d", attempts)
+	}
+
+	// the delay doesn't overflow
+	backoff.Max = time.Duration(math.MaxInt64)
+	for _, attempts := range []int{62, 63, 64, 1000} {
+		require.True(t, backoff.Delay(attempts) > 0, "attempts: %d", attempts)
+	}
+	require.Equal(t, time.Duration(math.MaxInt64), backoff.Delay(1000))
+
+	// without a maximum the delay is capped at the default
+	backoff.Max = 0
+	require.Equal(t, 20*time.Minute, backoff.Delay(2))
+	require.Equal(t, defaultBackoffMax, backoff.Delay(100))
+
+	require.Zero(t, BackoffConfig{}.Delay(3))
+
+	require.False(t, backoff.Exhausted(3))
+	require.True(t, backoff.Exhausted(4))
+	require.False(t, BackoffConfig{}.Exhausted(100))
+}
+
+func TestValidateFlagsSignatureVerification(t *testing.T) {
+	for _, tc := range []struct {
+		msg   string
+		cfg   LifecycleManagerConfig
+		valid bool
+	}{
+		{
+			msg:   "git repository with a keyring",
+			cfg:   LifecycleManagerConfig{GitRepositoryURL: "https://example.org/repo.git", GitGPGKeyring: "keyring.gpg"},
+			valid: true,
+		},
+		{
+			msg:   "archive with a keyring",
+			cfg:   LifecycleManagerConfig{ArchiveURL: "https://example.org/channels/", GitGPGKeyring: "keyring.gpg"},
+			valid: true,
+		},
+		{
+			msg:   "archive with allowed signers",
+			cfg:   LifecycleManagerConfig{ArchiveURL: "s3://bucket/channels", GitAllowedSigners: "allowed_signers"},
+			valid: true,
+		},
+		{
+			msg:   "directory with a keyring",
+			cfg:   LifecycleManagerConfig{Directory: "channels", GitGPGKeyring: "keyring.gpg"},
+			valid: false,
+		},
+	} {
+		t.Run(tc.msg, func(t *testing.T) {
+			err := tc.cfg.ValidateFlags()
+			if tc.valid {
+				require.NoError(t, err)
+			} else {
+				require.Error(t, err)
+			}
+		})
+	}
+}
+
+func TestValidateFlagsRollback(t *testing.T) {
+	cfg := LifecycleManagerConfig{
+		Directory: "channels",
+		Rollback:  RollbackConfig{FailedAttempts: 3},
+	}
+	require.Error(t, cfg.ValidateFlags())
+
+	cfg.History = "file:///var/lib/clm/history"
+	require.NoError(t, cfg.ValidateFlags())
+}
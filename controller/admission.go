@@ -0,0 +1,80 @@
+package controller
+
+import (
+	"github.com/zalando-incubator/cluster-lifecycle-manager/api"
+	"github.com/zalando-incubator/cluster-lifecycle-manager/config"
+)
+
+// AdmissionConstraint decides whether the update of a cluster may start
+// while other clusters are being updated. Clusters which aren't admitted stay
+// in the queue and are considered again by the next worker looking for a
+// cluster to update.
+type AdmissionConstraint interface {
+	// Admit returns true if cluster may be updated alongside the clusters
+	// currently being updated.
+	Admit(cluster *api.Cluster, processing []*api.Cluster) bool
+}
+
+// maxConcurrent limits the number of concurrent updates of the clusters
+// sharing the same key, e.g. the same infrastructure account.
+type maxConcurrent struct {
+	key   func(cluster *api.Cluster) string
+	limit int
+}
+
+// Admit returns true if fewer than the limit clusters with the same key as
+// cluster are being updated.
+func (m *maxConcurrent) Admit(cluster *api.Cluster, processing []*api.Cluster) bool {
+	key := m.key(cluster)
+
+	running := 0
+	for _, other := range processing {
+		if m.key(other) == key {
+			running++
+		}
+	}
+	return running < m.limit
+}
+
+// MaxConcurrentPerAccount limits the number of clusters updated concurrently
+// in the same infrastructure account.
+func MaxConcurrentPerAccount(limit uint) AdmissionConstraint {
+	return &maxConcurrent{
+		key:   func(cluster *api.Cluster) string { return cluster.InfrastructureAccount },
+		limit: int(limit),
+	}
+}
+
+// MaxConcurrentPerRegion limits the number of clusters updated concurrently
+// in the same region.
+func MaxConcurrentPerRegion(limit uint) AdmissionConstraint {
+	return &maxConcurrent{
+		key:   func(cluster *api.Cluster) string { return cluster.Region },
+		limit: int(limit),
+	}
+}
+
+// MaxConcurrentPerEnvironment limits the number of clusters updated
+// concurrently in the same environment.
+func MaxConcurrentPerEnvironment(limit uint) AdmissionConstraint {
+	return &maxConcurrent{
+		key:   func(cluster *api.Cluster) string { return cluster.Environment },
+		limit: int(limit),
+	}
+}
+
+// ConcurrencyLimits returns the admission constraints for the configured
+// concurrency limits. Limits set to 0 are ignored.
+func ConcurrencyLimits(limits config.ConcurrencyLimits) []AdmissionConstraint {
+	var constraints []AdmissionConstraint
+	if limits.PerAccount > 0 {
+		constraints = append(constraints, MaxConcurrentPerAccount(limits.PerAccount))
+	}
+	if limits.PerRegion > 0 {
+		constraints = append(constraints, MaxConcurrentPerRegion(limits.PerRegion))
+	}
+	if limits.PerEnvironment > 0 {
+		constraints = append(constraints, MaxConcurrentPerEnvironment(limits.PerEnvironment))
+	}
+	return constraints
+}
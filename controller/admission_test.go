@@ -0,0 +1,79 @@
+package controller
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/zalando-incubator/cluster-lifecycle-manager/api"
+	"github.com/zalando-incubator/cluster-lifecycle-manager/config"
+)
+
+func TestMaxConcurrent(t *testing.T) {
+	cluster := &api.Cluster{
+		InfrastructureAccount: "aws:123456789011",
+		Region:                "eu-central-1",
+		Environment:           "production",
+	}
+	sameAccount := &api.Cluster{
+		InfrastructureAccount: "aws:123456789011",
+		Region:                "eu-west-1",
+		Environment:           "test",
+	}
+	sameRegion := &api.Cluster{
+		InfrastructureAccount: "aws:123456789222",
+		Region:                "eu-central-1",
+		Environment:           "test",
+	}
+
+	for _, ti := range []struct {
+		msg        string
+		constraint AdmissionConstraint
+		processing []*api.Cluster
+		admitted   bool
+	}{
+		{
+			msg:        "nothing is being updated",
+			constraint: MaxConcurrentPerAccount(1),
+			admitted:   true,
+		},
+		{
+			msg:        "another cluster of the account is being updated",
+			constraint: MaxConcurrentPerAccount(1),
+			processing: []*api.Cluster{sameAccount},
+			admitted:   false,
+		},
+		{
+			msg:        "only clusters of other accounts are being updated",
+			constraint: MaxConcurrentPerAccount(1),
+			processing: []*api.Cluster{sameRegion},
+			admitted:   true,
+		},
+		{
+			msg:        "below the region limit",
+			constraint: MaxConcurrentPerRegion(2),
+			processing: []*api.Cluster{sameRegion, sameAccount},
+			admitted:   true,
+		},
+		{
+			msg:        "region limit reached",
+			constraint: MaxConcurrentPerRegion(2),
+			processing: []*api.Cluster{sameRegion, sameRegion},
+			admitted:   false,
+		},
+		{
+			msg:        "only clusters of other environments are being updated",
+			constraint: MaxConcurrentPerEnvironment(1),
+			processing: []*api.Cluster{sameAccount, sameRegion},
+			admitted:   true,
+		},
+	} {
+		t.Run(ti.msg, func(t *testing.T) {
+			require.Equal(t, ti.admitted, ti.constraint.Admit(cluster, ti.processing))
+		})
+	}
+}
+
+func TestConcurrencyLimits(t *testing.T) {
+	require.Empty(t, ConcurrencyLimits(config.ConcurrencyLimits{}))
+	require.Len(t, ConcurrencyLimits(config.ConcurrencyLimits{PerAccount: 1, PerEnvironment: 3}), 2)
+}
@@ -2,6 +2,7 @@ package controller
 
 import (
 	"context"
+	"errors"
 	"sort"
 	"sync"
 	"time"
@@ -21,8 +22,12 @@ const (
 	stateIdle = iota
 	stateProcessing
 	stateProcessed
+)
 
-	updateBlockedConfigItem = "cluster_update_block"
+var (
+	errClusterNotFound      = errors.New("cluster not found")
+	errClusterNotProcessing = errors.New("cluster is not being updated")
+	errNoPendingUpdate      = errors.New("cluster has no pending update")
 )
 
 type ClusterInfo struct {
@@ -30,8 +35,27 @@ type ClusterInfo struct {
 	state          int
 	cancelUpdate   context.CancelFunc
 	updatePriority uint32
+	prioritized    bool
 	Cluster        *api.Cluster
 
+	// number of consecutive failed updates to failedVersion, the cluster
+	// isn't updated again before backoffUntil
+	failedAttempts int
+	failedVersion  channel.ConfigVersion
+	backoffUntil   time.Time
+
+	// while the channel points to rollbackFrom, the cluster is kept at
+	// rollbackTo
+	rollbackFrom channel.ConfigVersion
+	rollbackTo   channel.ConfigVersion
+
+	// parsed from the maintenance window config item
+	maintenanceWindows maintenanceWindows
+
+	// parsed from the update block config item, nil if the cluster isn't
+	// blocked
+	updateBlock *UpdateBlock
+
 	CurrentVersion *api.ClusterVersion
 	NextVersion    *api.ClusterVersion
 	NextError      error
@@ -43,24 +67,63 @@ type ClusterList struct {
 	accountFilter config.IncludeExcludeFilter
 	clusters      map[string]*ClusterInfo
 	pendingUpdate []*ClusterInfo
+	paused        bool
 
 	// A map of env1 -> env2. For every channel, all clusters in env2 must be updated to a specific version before
 	// clusters in env1 will be allowed to be updated to it
 	prerequisiteEnvironments map[string]string
+
+	// rollout of new channel versions in waves within an environment
+	rollout *rollout
+
+	// delay between the attempts to update failing clusters
+	backoff config.BackoffConfig
+
+	// decommissions and interrupted updates may start outside of the
+	// maintenance windows of a cluster
+	urgentIgnoresMaintenanceWindow bool
+
+	// the clusters updated by this instance, all if nil
+	shard Shard
+
+	// checked against the clusters being updated before a cluster is
+	// selected
+	admission []AdmissionConstraint
+
+	// fleet-wide change freezes, none if nil
+	freezes *FreezeCalendar
+
+	// rollbacks recorded before a restart, applied once the cluster is
+	// added to the list
+	restoredRollbacks map[string]restoredRollback
+}
+
+// restoredRollback is a rollback recorded in the update history.
+type restoredRollback struct {
+	from, to channel.ConfigVersion
 }
 
-func NewClusterList(accountFilter config.IncludeExcludeFilter, environmentOrder []string) *ClusterList {
+// NewClusterList initializes a new cluster list using the account filter,
+// environment order, rollout, backoff, maintenance window, sharding and
+// admission settings of the controller options.
+func NewClusterList(options *Options) *ClusterList {
 	prerequisiteEnvironments := make(map[string]string)
-	for i, env := range environmentOrder {
+	for i, env := range options.EnvironmentOrder {
 		if i > 0 {
-			prerequisiteEnvironments[env] = environmentOrder[i-1]
+			prerequisiteEnvironments[env] = options.EnvironmentOrder[i-1]
 		}
 	}
 
 	return &ClusterList{
-		accountFilter:            accountFilter,
+		accountFilter:            options.AccountFilter,
 		clusters:                 make(map[string]*ClusterInfo),
 		prerequisiteEnvironments: prerequisiteEnvironments,
+		rollout:                  newRollout(options.Rollout),
+		backoff:                  options.Backoff,
+
+		urgentIgnoresMaintenanceWindow: options.UrgentIgnoresMaintenanceWindow,
+		shard:                          options.Shard,
+		admission:                      options.AdmissionConstraints,
 	}
 }
 
@@ -70,7 +133,8 @@ func (clusterList *ClusterList) UpdateAvailable(channels channel.ConfigVersions,
 	clusterList.Lock()
 	defer clusterList.Unlock()
 
-	clusterList.updateClusters(channels, availableClusters)
+	now := time.Now()
+	clusterList.updateClusters(channels, availableClusters, now)
 
 	// Collect information about used clusterInfo versions
 	usedVersions := newUsedVersions()
@@ -79,6 +143,8 @@ func (clusterList *ClusterList) UpdateAvailable(channels channel.ConfigVersions,
 			usedVersions.addCluster(clusterInfo)
 		}
 	}
+	clusterList.rollout.assignWaves(clusterList.clusters)
+	clusterList.rollout.prune(clusterList.clusters)
 
 	// Find out which clusters need updating
 	var pendingUpdate []*ClusterInfo
@@ -88,12 +154,19 @@ func (clusterList *ClusterList) UpdateAvailable(channels channel.ConfigVersions,
 		}
 
 		// Compute and cache update priority; add the clusterInfo to the list if it needs anything done
-		cluster.updatePriority = clusterList.updatePriority(cluster, usedVersions)
+		cluster.updatePriority = clusterList.updatePriority(cluster, usedVersions, now)
 		if cluster.updatePriority != updatePriorityNone {
 			pendingUpdate = append(pendingUpdate, cluster)
+		} else {
+			cluster.prioritized = false
 		}
 	}
 	sort.Slice(pendingUpdate, func(i, j int) bool {
+		// clusters explicitly prioritized by an operator always go first
+		if pendingUpdate[i].prioritized != pendingUpdate[j].prioritized {
+			return pendingUpdate[i].prioritized
+		}
+
 		pi := pendingUpdate[i].updatePriority
 		pj := pendingUpdate[j].updatePriority
 
@@ -106,14 +179,21 @@ func (clusterList *ClusterList) UpdateAvailable(channels channel.ConfigVersions,
 		}
 	})
 	clusterList.pendingUpdate = pendingUpdate
-}
+	pendingUpdates.Set(float64(len(pendingUpdate)))
 
-func updateBlocked(cluster *api.Cluster) bool {
-	_, ok := cluster.ConfigItems[updateBlockedConfigItem]
-	return ok
+	blocked := 0
+	for id, cluster := range clusterList.clusters {
+		if cluster.updateBlock.active(now) {
+			blocked++
+			clusterUpdateBlocked.WithLabelValues(id).Set(1)
+		} else {
+			clusterUpdateBlocked.WithLabelValues(id).Set(0)
+		}
+	}
+	blockedClusters.Set(float64(blocked))
 }
 
-func (clusterList *ClusterList) updateClusters(channels channel.ConfigVersions, availableClusters []*api.Cluster) {
+func (clusterList *ClusterList) updateClusters(channels channel.ConfigVersions, availableClusters []*api.Cluster, now time.Time) {
 	availableClusterIds := make(map[string]bool)
 
 	for _, cluster := range availableClusters {
@@ -127,26 +207,72 @@ func (clusterList *ClusterList) updateClusters(channels channel.ConfigVersions,
 			continue
 		}
 
+		if clusterList.shard != nil && !clusterList.shard.Owns(cluster.ID) {
+			log.Debugf("Skipping %s cluster, owned by another instance.", cluster.ID)
+			if existing, ok := clusterList.clusters[cluster.ID]; ok && existing.state == stateProcessing {
+				// abort the update, the new owner takes over
+				existing.cancelUpdate()
+			}
+			continue
+		}
+
 		availableClusterIds[cluster.ID] = true
 
 		currentVersion := api.ParseVersion(cluster.Status.CurrentVersion)
 
+		existing, ok := clusterList.clusters[cluster.ID]
+
+		var rollbackFrom, rollbackTo channel.ConfigVersion
+		if ok {
+			rollbackFrom, rollbackTo = existing.rollbackFrom, existing.rollbackTo
+		} else if restored, found := clusterList.restoredRollbacks[cluster.ID]; found {
+			rollbackFrom, rollbackTo = restored.from, restored.to
+			delete(clusterList.restoredRollbacks, cluster.ID)
+		}
+
 		var channelVersion channel.ConfigVersion
 		var nextVersion *api.ClusterVersion
 		var nextError error
 		channelVersion, nextError = channels.Version(cluster.Channel)
 		if nextError == nil {
+			if (!ok || existing.state != stateProcessing) && rollbackFrom != "" {
+				if rollbackFrom == channelVersion {
+					// keep the cluster at the last known good version
+					channelVersion = rollbackTo
+				} else {
+					rollbackFrom = ""
+					rollbackTo = ""
+				}
+			}
 			nextVersion, nextError = cluster.Version(channelVersion)
 		}
 
-		if existing, ok := clusterList.clusters[cluster.ID]; ok {
+		windows, err := parseMaintenanceWindows(cluster.ConfigItems[maintenanceWindowConfigItem])
+		if err != nil && nextError == nil {
+			nextError = err
+		}
+
+		updateBlock := updateBlockOf(cluster.ConfigItems)
+
+		if ok {
+			// the block is reported for clusters being updated as well, only
+			// starting an update depends on the state
+			existing.updateBlock = updateBlock
+
 			if existing.state != stateProcessing {
+				// retry failing clusters immediately once their next version changes
+				if existing.failedAttempts > 0 && nextVersion != nil && nextVersion.ConfigVersion != existing.failedVersion {
+					existing.resetFailures()
+				}
 				existing.state = stateIdle
 				existing.Cluster = cluster
 				existing.CurrentVersion = currentVersion
 				existing.NextVersion = nextVersion
 				existing.NextError = nextError
-			} else if existing.state == stateProcessing && updateBlocked(cluster) {
+				existing.maintenanceWindows = windows
+				existing.rollbackFrom = rollbackFrom
+				existing.rollbackTo = rollbackTo
+			} else if updateBlock.active(now) || clusterList.freezes.frozen(cluster, now) != nil {
 				// abort an update in progress
 				existing.cancelUpdate()
 			}
@@ -159,6 +285,11 @@ func (clusterList *ClusterList) updateClusters(channels channel.ConfigVersions,
 				CurrentVersion: currentVersion,
 				NextVersion:    nextVersion,
 				NextError:      nextError,
+
+				rollbackFrom:       rollbackFrom,
+				rollbackTo:         rollbackTo,
+				maintenanceWindows: windows,
+				updateBlock:        updateBlock,
 			}
 		}
 	}
@@ -172,17 +303,23 @@ func (clusterList *ClusterList) updateClusters(channels channel.ConfigVersions,
 
 		if _, ok := availableClusterIds[id]; !ok {
 			delete(clusterList.clusters, id)
+			clusterUpdateBlocked.DeleteLabelValues(id)
 		}
 	}
 }
 
 // updatePriority returns the update priority of the clusters. Clusters with higher priority will always be selected
 // for update before clusters with lower priority. A special value updatePriorityNone signifies that no update is needed.
-func (clusterList *ClusterList) updatePriority(clusterInfo *ClusterInfo, usedVersions usedVersions) uint32 {
+func (clusterList *ClusterList) updatePriority(clusterInfo *ClusterInfo, usedVersions usedVersions, now time.Time) uint32 {
 	cluster := clusterInfo.Cluster
 
-	// cluster updates are blocked
-	if updateBlocked(clusterInfo.Cluster) {
+	// cluster updates are blocked, expired blocks are ignored
+	if clusterInfo.updateBlock.active(now) {
+		return updatePriorityNone
+	}
+
+	// a change freeze is in effect for the cluster
+	if clusterList.freezes.frozen(cluster, now) != nil {
 		return updatePriorityNone
 	}
 
@@ -197,13 +334,19 @@ func (clusterList *ClusterList) updatePriority(clusterInfo *ClusterInfo, usedVer
 	}
 
 	// if the cluster's environment has another environment marked as a prerequisite, check if all clusters
-	// in that environment use the new version. only allow channel version change it if's true.
-	if clusterInfo.NextVersion.ConfigVersion != clusterInfo.CurrentVersion.ConfigVersion {
+	// in that environment use the new version. only allow channel version change it if's true. rollbacks
+	// to the last known good version are always allowed.
+	if clusterInfo.NextVersion.ConfigVersion != clusterInfo.CurrentVersion.ConfigVersion && clusterInfo.rollbackFrom == "" {
 		if prerequisite, ok := clusterList.prerequisiteEnvironments[cluster.Environment]; ok {
 			if !usedVersions.fullyUpdated(prerequisite, clusterInfo.Cluster.Channel, clusterInfo.NextVersion.ConfigVersion) {
 				return updatePriorityNone
 			}
 		}
+
+		// within the environment, only allow the change once the preceding rollout waves are done
+		if !clusterList.rollout.allowed(clusterInfo, now) {
+			return updatePriorityNone
+		}
 	}
 
 	// cluster is already being updated (CLM restart?)
@@ -221,23 +364,123 @@ func (clusterList *ClusterList) updatePriority(clusterInfo *ClusterInfo, usedVer
 
 // SelectNext returns the next cluster to update, if any, and marks it as being processed. A cluster with higher
 // priority will be selected first, in case of ties it'll select a cluster that hasn't been updated for the longest
-// time.
+// time. Clusters outside of their maintenance windows, frozen since the last refresh or not admitted by the admission
+// constraints are skipped, but stay in the queue.
 func (clusterList *ClusterList) SelectNext(cancelUpdate context.CancelFunc) *ClusterInfo {
 	clusterList.Lock()
 	defer clusterList.Unlock()
 
-	if len(clusterList.pendingUpdate) == 0 {
+	if clusterList.paused {
 		return nil
 	}
 
-	result := clusterList.pendingUpdate[0]
-	result.state = stateProcessing
-	result.cancelUpdate = cancelUpdate
-	clusterList.pendingUpdate = clusterList.pendingUpdate[1:]
+	processing := clusterList.processing()
 
+	now := time.Now()
+	for i, result := range clusterList.pendingUpdate {
+		if !clusterList.inMaintenanceWindow(result, now) || clusterList.inBackoff(result, now) {
+			continue
+		}
+
+		if clusterList.freezes.frozen(result.Cluster, now) != nil {
+			continue
+		}
+
+		if clusterList.shard != nil && !clusterList.shard.Owns(result.Cluster.ID) {
+			continue
+		}
+
+		if !clusterList.admitted(result, processing) {
+			continue
+		}
+
+		result.state = stateProcessing
+		result.cancelUpdate = cancelUpdate
+		result.prioritized = false
+		clusterList.pendingUpdate = append(clusterList.pendingUpdate[:i:i], clusterList.pendingUpdate[i+1:]...)
+		pendingUpdates.Set(float64(len(clusterList.pendingUpdate)))
+
+		return result
+	}
+
+	return nil
+}
+
+// CancelUnowned aborts the updates of the clusters which are no longer owned
+// by this instance, without waiting for the next refresh of the cluster list.
+func (clusterList *ClusterList) CancelUnowned() {
+	clusterList.Lock()
+	defer clusterList.Unlock()
+
+	if clusterList.shard == nil {
+		return
+	}
+
+	for _, cluster := range clusterList.clusters {
+		if cluster.state == stateProcessing && !clusterList.shard.Owns(cluster.Cluster.ID) {
+			cluster.cancelUpdate()
+		}
+	}
+}
+
+// CancelFrozen aborts the updates of the clusters for which a change freeze
+// is in effect, without waiting for the next refresh of the cluster list.
+func (clusterList *ClusterList) CancelFrozen() {
+	clusterList.Lock()
+	defer clusterList.Unlock()
+
+	now := time.Now()
+	for _, cluster := range clusterList.clusters {
+		if cluster.state == stateProcessing && clusterList.freezes.frozen(cluster.Cluster, now) != nil {
+			cluster.cancelUpdate()
+		}
+	}
+}
+
+// NextFreezeStart returns the start of the next change freeze, if any.
+func (clusterList *ClusterList) NextFreezeStart() (time.Time, bool) {
+	clusterList.Lock()
+	defer clusterList.Unlock()
+
+	return clusterList.freezes.nextStart(time.Now())
+}
+
+// processing returns the clusters currently being updated. Must be called
+// with the cluster list lock held.
+func (clusterList *ClusterList) processing() []*api.Cluster {
+	var result []*api.Cluster
+	for _, cluster := range clusterList.clusters {
+		if cluster.state == stateProcessing {
+			result = append(result, cluster.Cluster)
+		}
+	}
 	return result
 }
 
+// admitted returns true if all admission constraints allow the cluster to be
+// updated alongside the clusters currently being updated.
+func (clusterList *ClusterList) admitted(clusterInfo *ClusterInfo, processing []*api.Cluster) bool {
+	for _, constraint := range clusterList.admission {
+		if !constraint.Admit(clusterInfo.Cluster, processing) {
+			log.Debugf("Postponing update of %s, too many concurrent updates.", clusterInfo.Cluster.ID)
+			return false
+		}
+	}
+	return true
+}
+
+// inMaintenanceWindow returns true if the cluster may be updated at the
+// provided time.
+func (clusterList *ClusterList) inMaintenanceWindow(clusterInfo *ClusterInfo, now time.Time) bool {
+	if clusterList.urgentIgnoresMaintenanceWindow {
+		switch clusterInfo.updatePriority {
+		case updatePriorityDecommissionRequested, updatePriorityAlreadyUpdating:
+			return true
+		}
+	}
+	return clusterInfo.maintenanceWindows.contains(now)
+}
+
 // ClusterProcessed marks a cluster as no longer being processed.
 func (clusterList *ClusterList) ClusterProcessed(cluster *ClusterInfo) {
 	clusterList.Lock()
@@ -249,3 +492,172 @@ func (clusterList *ClusterList) ClusterProcessed(cluster *ClusterInfo) {
 		cluster.lastProcessed = time.Now()
 	}
 }
+
+// UpdateFailed records a failed update of a cluster to its next version and
+// puts the cluster into backoff. It returns the number of consecutive failed
+// attempts to update to the next version and the time until the cluster
+// won't be updated again.
+func (clusterList *ClusterList) UpdateFailed(cluster *ClusterInfo) (int, time.Time) {
+	clusterList.Lock()
+	defer clusterList.Unlock()
+
+	version := targetVersion(cluster)
+	if cluster.failedVersion != version {
+		cluster.resetFailures()
+		cluster.failedVersion = version
+	}
+	cluster.failedAttempts++
+
+	if clusterList.backoff.Initial > 0 {
+		cluster.backoffUntil = time.Now().Add(clusterList.backoff.Delay(cluster.failedAttempts))
+	}
+	return cluster.failedAttempts, cluster.backoffUntil
+}
+
+// UpdateSucceeded resets the failed attempts of a cluster.
+func (clusterList *ClusterList) UpdateSucceeded(cluster *ClusterInfo) {
+	clusterList.Lock()
+	defer clusterList.Unlock()
+
+	cluster.resetFailures()
+}
+
+// resetFailures resets the failed attempts and the backoff of a cluster. Must
+// be called with the cluster list lock held.
+func (clusterInfo *ClusterInfo) resetFailures() {
+	clusterInfo.failedVersion = ""
+	clusterInfo.failedAttempts = 0
+	clusterInfo.backoffUntil = time.Time{}
+}
+
+// targetVersion returns the channel version the cluster is updated to, or
+// an empty version if it can't be determined.
+func targetVersion(clusterInfo *ClusterInfo) channel.ConfigVersion {
+	if clusterInfo.NextVersion == nil {
+		return ""
+	}
+	return clusterInfo.NextVersion.ConfigVersion
+}
+
+// inBackoff returns true if the cluster shouldn't be updated yet because its
+// previous updates failed. Must be called with the cluster list lock held.
+func (clusterList *ClusterList) inBackoff(clusterInfo *ClusterInfo, now time.Time) bool {
+	if clusterInfo.failedAttempts == 0 {
+		return false
+	}
+	return now.Before(clusterInfo.backoffUntil) || clusterList.backoff.Exhausted(clusterInfo.failedAttempts)
+}
+
+// RollBack keeps a cluster at the version to instead of the version from as
+// long as its channel points to from. The cluster is updated to to on the
+// next refresh.
+func (clusterList *ClusterList) RollBack(cluster *ClusterInfo, from, to channel.ConfigVersion) {
+	clusterList.Lock()
+	defer clusterList.Unlock()
+
+	cluster.rollbackFrom = from
+	cluster.rollbackTo = to
+	cluster.resetFailures()
+}
+
+// RestoreRollback restores a rollback of a cluster recorded before a restart.
+// It's applied once the cluster is added to the list and ends like any other
+// rollback once the channel of the cluster changes.
+func (clusterList *ClusterList) RestoreRollback(id string, from, to channel.ConfigVersion) {
+	clusterList.Lock()
+	defer clusterList.Unlock()
+
+	if clusterList.restoredRollbacks == nil {
+		clusterList.restoredRollbacks = make(map[string]restoredRollback)
+	}
+	clusterList.restoredRollbacks[id] = restoredRollback{from: from, to: to}
+}
+
+// RollbackVersions returns the versions a cluster is rolled back from and to,
+// or empty versions if the cluster isn't rolled back.
+func (clusterList *ClusterList) RollbackVersions(cluster *ClusterInfo) (from, to channel.ConfigVersion) {
+	clusterList.Lock()
+	defer clusterList.Unlock()
+
+	return cluster.rollbackFrom, cluster.rollbackTo
+}
+
+// CancelUpdate aborts the update of a cluster currently being processed. The
+// cluster will be considered for an update again on the next refresh unless
+// updates are blocked for it.
+func (clusterList *ClusterList) CancelUpdate(id string) error {
+	clusterList.Lock()
+	defer clusterList.Unlock()
+
+	cluster, ok := clusterList.clusters[id]
+	if !ok {
+		return errClusterNotFound
+	}
+
+	if cluster.state != stateProcessing {
+		return errClusterNotProcessing
+	}
+
+	cluster.cancelUpdate()
+	return nil
+}
+
+// Prioritize moves a cluster waiting for an update to the front of the queue
+// and resets its backoff. The cluster stays in front until it's selected for
+// an update, even if the queue is recomputed in the meantime.
+func (clusterList *ClusterList) Prioritize(id string) error {
+	clusterList.Lock()
+	defer clusterList.Unlock()
+
+	if _, ok := clusterList.clusters[id]; !ok {
+		return errClusterNotFound
+	}
+
+	for i, cluster := range clusterList.pendingUpdate {
+		if cluster.Cluster.ID == id {
+			cluster.prioritized = true
+			cluster.resetFailures()
+
+			pendingUpdate := make([]*ClusterInfo, 0, len(clusterList.pendingUpdate))
+			pendingUpdate = append(pendingUpdate, cluster)
+			pendingUpdate = append(pendingUpdate, clusterList.pendingUpdate[:i]...)
+			pendingUpdate = append(pendingUpdate, clusterList.pendingUpdate[i+1:]...)
+			clusterList.pendingUpdate = pendingUpdate
+			return nil
+		}
+	}
+
+	return errNoPendingUpdate
+}
+
+// SetPaused pauses or resumes the selection of clusters for updates. Updates
+// already in progress are not affected.
+func (clusterList *ClusterList) SetPaused(paused bool) {
+	clusterList.Lock()
+	defer clusterList.Unlock()
+
+	clusterList.paused = paused
+	if paused {
+		controllerPaused.Set(1)
+	} else {
+		controllerPaused.Set(0)
+	}
+}
+
+// SetFreezeCalendar replaces the change freezes taken into account when the
+// clusters waiting for an update are computed on the next UpdateAvailable and
+// when the next cluster is selected.
+func (clusterList *ClusterList) SetFreezeCalendar(calendar *FreezeCalendar) {
+	clusterList.Lock()
+	defer clusterList.Unlock()
+
+	clusterList.freezes = calendar
+}
+
+// Paused returns true if the selection of clusters for updates is paused.
+func (clusterList *ClusterList) Paused() bool {
+	clusterList.Lock()
+	defer clusterList.Unlock()
+
+	return clusterList.paused
+}
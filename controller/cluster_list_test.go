@@ -107,7 +107,7 @@ func TestUpdateIgnoresClusters(t *testing.T) {
 			ignored: true,
 		},
 	} {
-		clusterList := NewClusterList(filter, []string{})
+		clusterList := NewClusterList(&Options{AccountFilter: filter})
 		clusterList.UpdateAvailable(defaultChannels, []*api.Cluster{ti.cluster})
 		nextCluster := clusterList.SelectNext(dummyCancelFunc)
 		if ti.ignored {
@@ -151,7 +151,7 @@ func TestUpdateAddsNewClusters(t *testing.T) {
 		Status:                mockStatus,
 	}
 
-	clusterList := NewClusterList(config.DefaultFilter, []string{})
+	clusterList := NewClusterList(defaultOptions)
 
 	// No clusters yet
 	require.Nil(t, clusterList.SelectNext(dummyCancelFunc))
@@ -174,7 +174,7 @@ func TestUpdateUpdatesExistingClusters(t *testing.T) {
 		Status:                mockStatus,
 	}
 
-	clusterList := NewClusterList(config.DefaultFilter, []string{})
+	clusterList := NewClusterList(defaultOptions)
 
 	clusterList.UpdateAvailable(defaultChannels, []*api.Cluster{cluster})
 
@@ -216,7 +216,7 @@ func TestUpdateAbortsProcessingIfBlocked(t *testing.T) {
 		Status:                mockStatus,
 	}
 
-	clusterList := NewClusterList(config.DefaultFilter, []string{})
+	clusterList := NewClusterList(defaultOptions)
 	clusterList.UpdateAvailable(defaultChannels, []*api.Cluster{cluster})
 
 	ctx, cancelFunc := context.WithCancel(context.Background())
@@ -234,6 +234,14 @@ func TestUpdateAbortsProcessingIfBlocked(t *testing.T) {
 	}
 	clusterList.UpdateAvailable(defaultChannels, []*api.Cluster{updated})
 	require.Equal(t, context.Canceled, ctx.Err())
+
+	// the block is reported while the update is still being aborted
+	require.NotNil(t, clusterList.Cluster(updated.ID).UpdateBlock)
+	require.Equal(t, "please don't", clusterList.Cluster(updated.ID).UpdateBlock.Reason)
+
+	// and lifted
+	clusterList.UpdateAvailable(defaultChannels, []*api.Cluster{cluster})
+	require.Nil(t, clusterList.Cluster(cluster.ID).UpdateBlock)
 }
 
 func TestUpdateDeletesUnusedClusters(t *testing.T) {
@@ -252,7 +260,7 @@ func TestUpdateDeletesUnusedClusters(t *testing.T) {
 		Status:                mockStatus,
 	}
 
-	clusterList := NewClusterList(config.DefaultFilter, []string{})
+	clusterList := NewClusterList(defaultOptions)
 
 	clusterList.UpdateAvailable(defaultChannels, []*api.Cluster{cluster1, cluster2})
 	require.Equal(t, []string{cluster1.ID, cluster2.ID}, sortedStrings(allClusterIds(clusterList)))
@@ -302,7 +310,7 @@ func TestClusterPriority(t *testing.T) {
 		{pendingUpdate, normal, decommissionRequested},
 		{pendingUpdate, decommissionRequested, normal},
 	} {
-		clusterList := NewClusterList(config.DefaultFilter, []string{})
+		clusterList := NewClusterList(defaultOptions)
 
 		clusterList.UpdateAvailable(defaultChannels, clusters)
 		assert.Equal(t, []string{pendingUpdate.ID, decommissionRequested.ID, normal.ID}, allClusterIds(clusterList))
@@ -363,7 +371,7 @@ func TestClusterEnvOrder(t *testing.T) {
 	}
 
 	pendingUpdates := func(clusters ...*api.Cluster) []string {
-		clusterList := NewClusterList(config.DefaultFilter, []string{"test", "prod"})
+		clusterList := NewClusterList(&Options{AccountFilter: config.DefaultFilter, EnvironmentOrder: []string{"test", "prod"}})
 		clusterList.UpdateAvailable(channels, clusters)
 		return allClusterIds(clusterList)
 	}
@@ -382,7 +390,7 @@ func TestClusterEnvOrder(t *testing.T) {
 }
 
 func TestClusterLastUpdated(t *testing.T) {
-	clusterList := NewClusterList(config.DefaultFilter, []string{})
+	clusterList := NewClusterList(defaultOptions)
 
 	clusters := []*api.Cluster{
 		{
@@ -443,7 +451,7 @@ func TestProcessingClusterNotDeleted(t *testing.T) {
 		Status:                mockStatus,
 	}
 
-	clusterList := NewClusterList(config.DefaultFilter, []string{})
+	clusterList := NewClusterList(defaultOptions)
 	clusterList.UpdateAvailable(defaultChannels, []*api.Cluster{cluster})
 	next := clusterList.SelectNext(dummyCancelFunc)
 	require.NotNil(t, next)
@@ -479,7 +487,7 @@ func TestProcessingClusterNotUpdated(t *testing.T) {
 		Status:                mockStatus,
 	}
 
-	clusterList := NewClusterList(config.DefaultFilter, []string{})
+	clusterList := NewClusterList(defaultOptions)
 	clusterList.UpdateAvailable(defaultChannels, []*api.Cluster{cluster})
 	next := clusterList.SelectNext(dummyCancelFunc)
 	require.NotNil(t, next)
@@ -505,3 +513,206 @@ func TestProcessingClusterNotUpdated(t *testing.T) {
 	require.NotNil(t, next2)
 	require.Equal(t, updated.LifecycleStatus, next2.Cluster.LifecycleStatus)
 }
+
+func TestCancelUpdate(t *testing.T) {
+	cluster := &api.Cluster{
+		ID:                    "aws:123456789011:eu-central-1:cluster",
+		InfrastructureAccount: "aws:123456789011",
+		LifecycleStatus:       "ready",
+		Channel:               "dev",
+		Status:                mockStatus,
+	}
+
+	clusterList := NewClusterList(defaultOptions)
+	clusterList.UpdateAvailable(defaultChannels, []*api.Cluster{cluster})
+
+	require.Equal(t, errClusterNotFound, clusterList.CancelUpdate("aws:123456789011:eu-central-1:unknown"))
+	require.Equal(t, errClusterNotProcessing, clusterList.CancelUpdate(cluster.ID))
+
+	ctx, cancelFunc := context.WithCancel(context.Background())
+	next := clusterList.SelectNext(cancelFunc)
+	require.NotNil(t, next)
+
+	require.NoError(t, clusterList.CancelUpdate(cluster.ID))
+	require.Equal(t, context.Canceled, ctx.Err())
+}
+
+func TestPrioritize(t *testing.T) {
+	clusters := []*api.Cluster{
+		{
+			ID:                    "aws:123456789011:eu-central-1:cluster1",
+			InfrastructureAccount: "aws:123456789011",
+			LifecycleStatus:       "ready",
+			Channel:               "dev",
+			Status:                mockStatus,
+		},
+		{
+			ID:                    "aws:123456789012:eu-central-1:cluster2",
+			InfrastructureAccount: "aws:123456789012",
+			LifecycleStatus:       "decommission-requested",
+			Channel:               "dev",
+			Status:                mockStatus,
+		},
+		{
+			ID:                    "aws:123456789013:eu-central-1:cluster3",
+			InfrastructureAccount: "aws:123456789013",
+			LifecycleStatus:       "ready",
+			Channel:               "dev",
+			Status:                mockStatus,
+			ConfigItems:           map[string]string{updateBlockedConfigItem: "please don't"},
+		},
+	}
+
+	clusterList := NewClusterList(defaultOptions)
+	clusterList.UpdateAvailable(defaultChannels, clusters)
+
+	require.Equal(t, errClusterNotFound, clusterList.Prioritize("aws:123456789014:eu-central-1:unknown"))
+	require.Equal(t, errNoPendingUpdate, clusterList.Prioritize(clusters[2].ID))
+	require.NoError(t, clusterList.Prioritize(clusters[0].ID))
+
+	// prioritization survives a refresh of the cluster list
+	clusterList.UpdateAvailable(defaultChannels, clusters)
+	require.Equal(t, []string{clusters[0].ID, clusters[1].ID}, allClusterIds(clusterList))
+
+	// but is reset once the cluster was selected
+	clusterList.UpdateAvailable(defaultChannels, clusters)
+	require.Equal(t, []string{clusters[1].ID, clusters[0].ID}, allClusterIds(clusterList))
+}
+
+func TestPaused(t *testing.T) {
+	cluster := &api.Cluster{
+		ID:                    "aws:123456789011:eu-central-1:cluster",
+		InfrastructureAccount: "aws:123456789011",
+		LifecycleStatus:       "ready",
+		Channel:               "dev",
+		Status:                mockStatus,
+	}
+
+	clusterList := NewClusterList(defaultOptions)
+	clusterList.UpdateAvailable(defaultChannels, []*api.Cluster{cluster})
+
+	clusterList.SetPaused(true)
+	require.True(t, clusterList.Paused())
+	require.Nil(t, clusterList.SelectNext(dummyCancelFunc))
+
+	clusterList.SetPaused(false)
+	require.False(t, clusterList.Paused())
+	require.NotNil(t, clusterList.SelectNext(dummyCancelFunc))
+}
+
+type mockShard struct {
+	owned map[string]bool
+}
+
+func (s *mockShard) Owns(clusterID string) bool {
+	return s.owned[clusterID]
+}
+
+func (s *mockShard) Changes() <-chan struct{} {
+	return nil
+}
+
+func TestUpdateSharded(t *testing.T) {
+	cluster1 := &api.Cluster{
+		ID:                    "aws:123456789011:eu-central-1:cluster1",
+		InfrastructureAccount: "aws:123456789011",
+		LifecycleStatus:       "ready",
+		Channel:               "dev",
+		Status:                mockStatus,
+	}
+	cluster2 := &api.Cluster{
+		ID:                    "aws:123456789222:eu-central-1:cluster2",
+		InfrastructureAccount: "aws:123456789222",
+		LifecycleStatus:       "ready",
+		Channel:               "dev",
+		Status:                mockStatus,
+	}
+	cluster3 := &api.Cluster{
+		ID:                    "aws:123456789011:eu-central-1:cluster3",
+		InfrastructureAccount: "aws:123456789011",
+		LifecycleStatus:       "ready",
+		Channel:               "dev",
+		Status:                mockStatus,
+	}
+
+	shard := &mockShard{owned: map[string]bool{cluster1.ID: true, cluster2.ID: true}}
+	clusterList := NewClusterList(&Options{
+		AccountFilter: config.IncludeExcludeFilter{
+			Include: regexp.MustCompile(config.DefaultInclude),
+			Exclude: regexp.MustCompile("^aws:123456789222$"),
+		},
+		Shard: shard,
+	})
+
+	// the account filter still applies to the owned clusters
+	clusterList.UpdateAvailable(defaultChannels, []*api.Cluster{cluster1, cluster2, cluster3})
+	require.Equal(t, []string{cluster1.ID}, allClusterIds(clusterList))
+
+	clusterList.UpdateAvailable(defaultChannels, []*api.Cluster{cluster1, cluster2, cluster3})
+	ctx, cancelFunc := context.WithCancel(context.Background())
+	require.NotNil(t, clusterList.SelectNext(cancelFunc))
+
+	// the update is aborted once the cluster moves to another instance
+	shard.owned = map[string]bool{cluster3.ID: true}
+	clusterList.UpdateAvailable(defaultChannels, []*api.Cluster{cluster1, cluster2, cluster3})
+	require.Equal(t, context.Canceled, ctx.Err())
+	require.Equal(t, []string{cluster3.ID}, allClusterIds(clusterList))
+
+	// no updates are started and the ones in progress are aborted once the
+	// shard is given up, even before the cluster list is refreshed
+	clusterList.UpdateAvailable(defaultChannels, []*api.Cluster{cluster1, cluster2, cluster3})
+	shard.owned = nil
+	require.Nil(t, clusterList.SelectNext(dummyCancelFunc))
+
+	shard.owned = map[string]bool{cluster3.ID: true}
+	ctx, cancelFunc = context.WithCancel(context.Background())
+	require.NotNil(t, clusterList.SelectNext(cancelFunc))
+
+	shard.owned = nil
+	clusterList.CancelUnowned()
+	require.Equal(t, context.Canceled, ctx.Err())
+}
+
+func TestSelectNextAdmission(t *testing.T) {
+	newCluster := func(id, account string) *api.Cluster {
+		return &api.Cluster{
+			ID:                    account + ":eu-central-1:" + id,
+			InfrastructureAccount: account,
+			Region:                "eu-central-1",
+			LifecycleStatus:       "ready",
+			Channel:               "dev",
+			Status:                mockStatus,
+		}
+	}
+	cluster1 := newCluster("cluster1", "aws:123456789011")
+	cluster2 := newCluster("cluster2", "aws:123456789011")
+	cluster3 := newCluster("cluster3", "aws:123456789222")
+
+	clusterList := NewClusterList(&Options{
+		AccountFilter: config.DefaultFilter,
+		AdmissionConstraints: ConcurrencyLimits(config.ConcurrencyLimits{
+			PerAccount: 1,
+			PerRegion:  2,
+		}),
+	})
+	clusterList.UpdateAvailable(defaultChannels, []*api.Cluster{cluster1, cluster2, cluster3})
+
+	// one cluster per account, two per region
+	first := clusterList.SelectNext(dummyCancelFunc)
+	require.NotNil(t, first)
+	second := clusterList.SelectNext(dummyCancelFunc)
+	require.NotNil(t, second)
+	require.NotEqual(t, first.Cluster.InfrastructureAccount, second.Cluster.InfrastructureAccount)
+	require.Nil(t, clusterList.SelectNext(dummyCancelFunc))
+
+	// the postponed cluster stays in the queue until an update finishes
+	require.Len(t, clusterList.PendingUpdates(), 1)
+	if first.Cluster.InfrastructureAccount == "aws:123456789011" {
+		clusterList.ClusterProcessed(first)
+	} else {
+		clusterList.ClusterProcessed(second)
+	}
+	third := clusterList.SelectNext(dummyCancelFunc)
+	require.NotNil(t, third)
+	require.Equal(t, "aws:123456789011", third.Cluster.InfrastructureAccount)
+}
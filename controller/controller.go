@@ -3,13 +3,22 @@ package controller
 import (
 	"context"
 	"fmt"
+	"strconv"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/pkg/errors"
 	log "github.com/sirupsen/logrus"
 
 	"github.com/zalando-incubator/cluster-lifecycle-manager/api"
 	"github.com/zalando-incubator/cluster-lifecycle-manager/channel"
 	"github.com/zalando-incubator/cluster-lifecycle-manager/config"
+	"github.com/zalando-incubator/cluster-lifecycle-manager/history"
+	"github.com/zalando-incubator/cluster-lifecycle-manager/notifier"
+	"github.com/zalando-incubator/cluster-lifecycle-manager/pkg/tracing"
+	"github.com/zalando-incubator/cluster-lifecycle-manager/pkg/updatestrategy"
+	"github.com/zalando-incubator/cluster-lifecycle-manager/pkg/util/interrupt"
 	"github.com/zalando-incubator/cluster-lifecycle-manager/provisioner"
 	"github.com/zalando-incubator/cluster-lifecycle-manager/registry"
 )
@@ -17,6 +26,11 @@ import (
 const (
 	errTypeGeneral           = "https://cluster-lifecycle-manager.zalando.org/problems/general-error"
 	errTypeCoalescedProblems = "https://cluster-lifecycle-manager.zalando.org/problems/too-many-problems"
+	errTypeHealthCheck       = "https://cluster-lifecycle-manager.zalando.org/problems/health-check-failed"
+	errTypeRollback          = "https://cluster-lifecycle-manager.zalando.org/problems/rollback"
+	errTypeBackoff           = "https://cluster-lifecycle-manager.zalando.org/problems/backoff"
+	errTypeInterrupted       = "https://cluster-lifecycle-manager.zalando.org/problems/interrupted"
+	errTypeUnverifiedVersion = "https://cluster-lifecycle-manager.zalando.org/problems/unverified-channel-version"
 	errorLimit               = 25
 )
 
@@ -35,6 +49,49 @@ type Options struct {
 	DryRun            bool
 	ConcurrentUpdates uint
 	EnvironmentOrder  []string
+	AdminToken        string
+	History           history.Store
+	Rollout           config.RolloutConfig
+	Rollback          config.RollbackConfig
+	Backoff           config.BackoffConfig
+
+	// WebhookSecret authenticates the webhooks requesting a refresh.
+	// Webhooks are disabled if not set.
+	WebhookSecret string
+	// RefreshDebounce is the time to wait after a requested refresh, so
+	// that the requests arriving in the meantime are combined into a
+	// single refresh.
+	RefreshDebounce time.Duration
+
+	// UrgentIgnoresMaintenanceWindow allows decommissions and interrupted
+	// updates to start outside of the maintenance windows of a cluster.
+	UrgentIgnoresMaintenanceWindow bool
+
+	// Shard restricts the controller to the clusters owned by this
+	// instance. All clusters are updated if not set.
+	Shard Shard
+
+	// AdmissionConstraints must all admit a cluster before its update
+	// starts, in addition to the limit of ConcurrentUpdates.
+	AdmissionConstraints []AdmissionConstraint
+
+	// Notifier sends notifications about the lifecycle events of the
+	// clusters. Notifications are disabled if not set.
+	Notifier *notifier.Notifier
+
+	// FreezeCalendar provides the fleet-wide change freezes. No freezes
+	// apply if not set.
+	FreezeCalendar FreezeCalendarSource
+}
+
+// Shard is the subset of the clusters updated by an instance of the
+// controller when the clusters are sharded across several instances.
+type Shard interface {
+	// Owns returns true if the cluster is updated by this instance.
+	Owns(clusterID string) bool
+	// Changes returns a channel which receives a value whenever the
+	// owned clusters changed.
+	Changes() <-chan struct{}
 }
 
 // Controller defines the main control loop for the cluster-lifecycle-manager.
@@ -47,6 +104,23 @@ type Controller struct {
 	dryRun               bool
 	clusterList          *ClusterList
 	concurrentUpdates    uint
+	adminToken           string
+	history              history.Store
+	notifier             *notifier.Notifier
+	freezeCalendar       FreezeCalendarSource
+	rollback             config.RollbackConfig
+	rollbacksRestored    bool
+	backoff              config.BackoffConfig
+	shard                Shard
+	webhookSecret        string
+	refreshDebounce      time.Duration
+	refreshRequests      chan struct{}
+	stopping             chan struct{}
+	stopOnce             sync.Once
+
+	// set while Run is running, i.e. while this instance is the leader if
+	// leader election is enabled
+	running int32
 }
 
 // New initializes a new controller.
@@ -58,31 +132,116 @@ func New(logger *log.Entry, registry registry.Registry, provisioner provisioner.
 		channelConfigSourcer: channelConfigSourcer,
 		interval:             options.Interval,
 		dryRun:               options.DryRun,
-		clusterList:          NewClusterList(options.AccountFilter, options.EnvironmentOrder),
+		clusterList:          NewClusterList(options),
 		concurrentUpdates:    options.ConcurrentUpdates,
+		adminToken:           options.AdminToken,
+		history:              options.History,
+		notifier:             options.Notifier,
+		freezeCalendar:       options.FreezeCalendar,
+		rollback:             options.Rollback,
+		backoff:              options.Backoff,
+		shard:                options.Shard,
+		webhookSecret:        options.WebhookSecret,
+		refreshDebounce:      options.RefreshDebounce,
+		refreshRequests:      make(chan struct{}, 1),
+		stopping:             make(chan struct{}),
 	}
 }
 
-// Run the main controller loop.
+// Run the main controller loop. When ctx is canceled, the updates in
+// progress are canceled as well and Run returns once all workers stopped. See
+// Stop for stopping the controller gracefully.
 func (c *Controller) Run(ctx context.Context) {
 	log.Info("Starting main control loop.")
 
+	atomic.StoreInt32(&c.running, 1)
+	defer atomic.StoreInt32(&c.running, 0)
+
+	var workers sync.WaitGroup
+	defer workers.Wait()
+
 	// Start the update workers
 	for i := uint(0); i < c.concurrentUpdates; i++ {
-		go c.processWorkerLoop(ctx, i+1)
+		workers.Add(1)
+		go func(workerNum uint) {
+			defer workers.Done()
+			c.processWorkerLoop(ctx, workerNum)
+		}(i + 1)
+	}
+
+	// the first refresh happens right away. The periodic refreshes are
+	// kept as a fallback and aren't delayed by the requested ones.
+	interval := time.NewTimer(0)
+	defer interval.Stop()
+
+	// refresh right away when the owned clusters change, so that clusters
+	// moved to another instance are no longer updated here
+	var shardChanges <-chan struct{}
+	if c.shard != nil {
+		shardChanges = c.shard.Changes()
 	}
 
-	var interval time.Duration
+	// requested refreshes are delayed by the debounce period
+	var debounce <-chan time.Time
+
+	// abort the updates of frozen clusters as soon as a freeze starts. The
+	// timer is only armed again when the start of the next freeze changes,
+	// i.e. after it fired or after the calendar changed.
+	freeze := time.NewTimer(time.Hour)
+	freeze.Stop()
+	defer freeze.Stop()
+
+	var freezeStart <-chan time.Time
+	var nextFreeze time.Time
+	armFreeze := func() {
+		start, ok := c.clusterList.NextFreezeStart()
+		if freezeStart != nil && ok && start.Equal(nextFreeze) {
+			return
+		}
+
+		if freezeStart != nil {
+			stopTimer(freeze)
+		}
+		freezeStart = nil
+		nextFreeze = time.Time{}
+		if ok {
+			freeze.Reset(time.Until(start))
+			freezeStart = freeze.C
+			nextFreeze = start
+		}
+	}
+
+	refresh := func() {
+		err := c.refresh()
+		if err != nil {
+			log.Errorf("Failed to refresh cluster list: %s", err)
+		}
+		armFreeze()
+	}
 
 	// Start the refresh loop
 	for {
 		select {
-		case <-time.After(interval):
-			interval = c.interval
-			err := c.refresh()
-			if err != nil {
-				log.Errorf("Failed to refresh cluster list: %s", err)
+		case <-interval.C:
+			interval.Reset(c.interval)
+			refresh()
+		case <-c.refreshRequests:
+			if debounce == nil {
+				debounce = time.After(c.refreshDebounce)
 			}
+		case <-debounce:
+			debounce = nil
+			refresh()
+		case <-freezeStart:
+			freezeStart = nil
+			c.clusterList.CancelFrozen()
+			armFreeze()
+		case <-shardChanges:
+			c.clusterList.CancelUnowned()
+			refresh()
+		case <-c.stopping:
+			log.Info("Stopping main controller loop, waiting for the updates in progress.")
+			return
 		case <-ctx.Done():
 			log.Info("Terminating main controller loop.")
 			return
@@ -90,15 +249,52 @@ func (c *Controller) Run(ctx context.Context) {
 	}
 }
 
+// stopTimer stops a timer whose channel wasn't received from and drains the
+// channel if the timer already fired, so that it can be reset.
+func stopTimer(timer *time.Timer) {
+	if !timer.Stop() {
+		<-timer.C
+	}
+}
+
+// Stop stops the controller gracefully: no further updates are started and
+// the updates in progress are interrupted at their next safe point, so that
+// they can be resumed later. Run returns once all of them stopped.
+func (c *Controller) Stop() {
+	c.stopOnce.Do(func() {
+		close(c.stopping)
+	})
+}
+
+// stopped returns true if the controller is being stopped.
+func (c *Controller) stopped() bool {
+	select {
+	case <-c.stopping:
+		return true
+	default:
+		return false
+	}
+}
+
 func (c *Controller) processWorkerLoop(ctx context.Context, workerNum uint) {
+	busy := workerBusy.WithLabelValues(strconv.FormatUint(uint64(workerNum), 10))
+	busy.Set(0)
+
 	for {
 		select {
 		case <-time.After(c.interval):
-			updateCtx, cancelFunc := context.WithCancel(ctx)
+			if c.stopped() {
+				return
+			}
+			updateCtx, cancelFunc := context.WithCancel(interrupt.WithSignal(ctx, c.stopping))
 			nextCluster := c.clusterList.SelectNext(cancelFunc)
 			if nextCluster != nil {
+				busy.Set(1)
 				c.processCluster(updateCtx, workerNum, nextCluster)
+				busy.Set(0)
 			}
+		case <-c.stopping:
+			return
 		case <-ctx.Done():
 			return
 		}
@@ -107,11 +303,34 @@ func (c *Controller) processWorkerLoop(ctx context.Context, workerNum uint) {
 
 // refresh refreshes the channel configuration and the cluster list
 func (c *Controller) refresh() error {
+	start := time.Now()
 	channels, err := c.channelConfigSourcer.Update(c.logger)
+	channelUpdateDuration.WithLabelValues(resultLabel(err)).Observe(time.Since(start).Seconds())
 	if err != nil {
 		return err
 	}
 
+	// keep the last valid calendar if it can't be loaded, e.g. because of a
+	// broken change, instead of stopping all updates
+	if c.freezeCalendar != nil {
+		calendar, err := c.freezeCalendar.Load(c.logger, channels)
+		if err != nil {
+			c.logger.Errorf("Failed to load freeze calendar, keeping the previous one: %v", err)
+		} else {
+			c.clusterList.SetFreezeCalendar(calendar)
+		}
+	}
+
+	// the clusters aren't updated before the rollbacks are restored, they
+	// would be updated to the failing versions again otherwise
+	if !c.rollbacksRestored && c.rollback.Enabled() && c.history != nil {
+		err = c.restoreRollbacks()
+		if err != nil {
+			return fmt.Errorf("unable to restore rollbacks from the update history: %v", err)
+		}
+		c.rollbacksRestored = true
+	}
+
 	clusters, err := c.registry.ListClusters(registry.Filter{})
 	if err != nil {
 		return err
@@ -121,6 +340,29 @@ func (c *Controller) refresh() error {
 	return nil
 }
 
+// restoreRollbacks restores the rollbacks in effect after the last recorded
+// update of every cluster.
+func (c *Controller) restoreRollbacks() error {
+	entries, err := c.history.List("")
+	if err != nil {
+		return err
+	}
+
+	latest := make(map[string]*history.Entry)
+	for _, entry := range entries {
+		latest[entry.ClusterID] = entry
+	}
+
+	for id, entry := range latest {
+		if entry.RollbackFrom == "" {
+			continue
+		}
+		c.logger.Infof("Resuming rollback of cluster %s from channel version %s to %s", id, entry.RollbackFrom, entry.RollbackTo)
+		c.clusterList.RestoreRollback(id, channel.ConfigVersion(entry.RollbackFrom), channel.ConfigVersion(entry.RollbackTo))
+	}
+	return nil
+}
+
 // dropUnsupported removes clusters not supported by the current provisioner
 func (c *Controller) dropUnsupported(clusters []*api.Cluster) []*api.Cluster {
 	result := make([]*api.Cluster, 0, len(clusters))
@@ -173,6 +415,13 @@ func (c *Controller) doProcessCluster(logger *log.Entry, updateCtx context.Conte
 		cluster.Status.CurrentVersion = cluster.Status.NextVersion
 		cluster.Status.NextVersion = ""
 		cluster.Status.Problems = []*api.Problem{}
+
+		if c.rollback.HealthCheck {
+			err = c.healthCheck(updateCtx, logger, cluster, config)
+			if err != nil {
+				return err
+			}
+		}
 	case statusDecommissionRequested:
 		err = c.provisioner.Decommission(logger, cluster, config)
 		if err != nil {
@@ -200,10 +449,61 @@ func (c *Controller) processCluster(updateCtx context.Context, workerNum uint, c
 
 	clusterLog.Infof("Processing cluster (%s)", cluster.LifecycleStatus)
 
-	err := c.doProcessCluster(clusterLog, updateCtx, clusterInfo)
+	rollbackFrom, rollbackTo := c.clusterList.RollbackVersions(clusterInfo)
+	lifecycleStatus := cluster.LifecycleStatus
+
+	entry := &history.Entry{
+		ClusterID:       cluster.ID,
+		LifecycleStatus: cluster.LifecycleStatus,
+		ToVersion:       clusterInfo.NextVersion.String(),
+		Worker:          workerNum,
+		Rollback:        rollbackFrom != "",
+	}
+	if cluster.Status != nil {
+		entry.FromVersion = cluster.Status.CurrentVersion
+	}
+	// record the node pools actually rolled by the update, the handler is
+	// called synchronously by the provisioner
+	updateCtx = updatestrategy.WithNodePoolRolledHandler(updateCtx, func(nodePool string) {
+		entry.NodePools = append(entry.NodePools, nodePool)
+	})
+
+	c.notify(newEvent(notifier.EventUpdateStarted, entry, cluster, nil))
+	if c.notifier != nil && c.notifier.DrainStuckAfter() > 0 {
+		updateCtx = updatestrategy.WithDrainStuckHandler(updateCtx, c.notifier.DrainStuckAfter(), func(node string, drainStart time.Time) {
+			clusterLog.WithField("node", node).Warnf("Draining node since %s", drainStart.UTC().Format(time.RFC3339))
+
+			event := newEvent(notifier.EventDrainStuck, entry, cluster, nil)
+			event.Node = node
+			event.Detail = "draining since " + drainStart.UTC().Format(time.RFC3339)
+			c.notify(event)
+		})
+	}
+
+	traceCtx, span := tracing.Start(updateCtx, "update-cluster",
+		"cluster", cluster.ID,
+		"alias", cluster.Alias,
+		"lifecycle_status", cluster.LifecycleStatus,
+		"from_version", entry.FromVersion,
+		"to_version", entry.ToVersion)
+
+	start := time.Now()
+	err := c.doProcessCluster(clusterLog, traceCtx, clusterInfo)
+	span.End(err)
+	clusterUpdateDuration.WithLabelValues(cluster.ID).Observe(time.Since(start).Seconds())
+
+	entry.Started = start
+	entry.Finished = time.Now()
+	// the outcome is recorded in the history together with the rollback
+	// state below
+	updateErr := err
+
+	interrupted := err != nil && errors.Cause(err) == interrupt.ErrInterrupted
 
 	// log the error and resolve the special error cases
-	if err != nil {
+	if interrupted {
+		clusterLog.Warn("Interrupted processing cluster, it will be resumed on the next attempt")
+	} else if err != nil {
 		clusterLog.Errorf("Failed to process cluster: %s", err)
 
 		// treat "provider not supported" as no error
@@ -213,17 +513,67 @@ func (c *Controller) processCluster(updateCtx context.Context, workerNum uint, c
 	} else {
 		clusterLog.Infof("Finished processing cluster")
 	}
+	clusterUpdates.WithLabelValues(cluster.ID, resultLabel(err)).Inc()
+
+	switch {
+	case err == nil && lifecycleStatus == statusDecommissionRequested:
+		c.notify(newEvent(notifier.EventDecommissioned, entry, cluster, nil))
+	case err == nil:
+		c.notify(newEvent(notifier.EventUpdateSucceeded, entry, cluster, nil))
+	case interrupted:
+		event := newEvent(notifier.EventUpdateCanceled, entry, cluster, nil)
+		event.Detail = "interrupted by a controller shutdown, it will be resumed"
+		c.notify(event)
+	case updateCtx.Err() != nil:
+		c.notify(newEvent(notifier.EventUpdateCanceled, entry, cluster, err))
+	default:
+		c.notify(newEvent(notifier.EventUpdateFailed, entry, cluster, err))
+	}
+
+	// problems describing the rollback and backoff state of the cluster
+	var stateProblems []*api.Problem
+
+	switch {
+	case err == nil:
+		c.clusterList.UpdateSucceeded(clusterInfo)
+		if rollbackFrom != "" {
+			stateProblems = append(stateProblems, &api.Problem{
+				Type:  errTypeRollback,
+				Title: fmt.Sprintf("rolled back from channel version %s to %s", rollbackFrom, rollbackTo),
+			})
+		}
+	case interrupted:
+		// interrupted updates are resumed and don't count as failed
+		// attempts
+	case updateCtx.Err() == nil:
+		// canceled updates don't count as failed attempts
+		attempts, backoffUntil := c.clusterList.UpdateFailed(clusterInfo)
+
+		if rollbackFrom == "" && lifecycleStatus != statusDecommissionRequested && clusterInfo.NextError == nil {
+			if rollbackProblem := c.checkRollback(clusterLog, clusterInfo, attempts, err); rollbackProblem != nil {
+				stateProblems = append(stateProblems, rollbackProblem)
+				break
+			}
+		}
+
+		if backoffProblem := c.backoffProblem(attempts, backoffUntil); backoffProblem != nil {
+			clusterLog.Warn(backoffProblem.Title)
+			stateProblems = append(stateProblems, backoffProblem)
+		}
+	}
+
+	// the rollback is recorded in the history, so that it's resumed after
+	// a restart
+	rollbackFrom, rollbackTo = c.clusterList.RollbackVersions(clusterInfo)
+	entry.RollbackFrom = string(rollbackFrom)
+	entry.RollbackTo = string(rollbackTo)
+	c.recordHistory(clusterLog, entry, updateCtx, updateErr)
 
 	// update the cluster state in the registry
 	if !c.dryRun {
 		if err != nil {
-			if cluster.Status.Problems == nil {
-				cluster.Status.Problems = make([]*api.Problem, 0, 1)
-			}
-			cluster.Status.Problems = append(cluster.Status.Problems, &api.Problem{
-				Title: err.Error(),
-				Type:  errTypeGeneral,
-			})
+			cluster.Status.Problems = append(withoutBackoffProblems(cluster.Status.Problems), problem(err))
+			cluster.Status.Problems = append(cluster.Status.Problems, stateProblems...)
 
 			if len(cluster.Status.Problems) > errorLimit {
 				cluster.Status.Problems = cluster.Status.Problems[len(cluster.Status.Problems)-errorLimit:]
@@ -233,7 +583,7 @@ func (c *Controller) processCluster(updateCtx context.Context, workerNum uint, c
 				}
 			}
 		} else {
-			cluster.Status.Problems = []*api.Problem{}
+			cluster.Status.Problems = append([]*api.Problem{}, stateProblems...)
 		}
 		err = c.registry.UpdateCluster(cluster)
 		if err != nil {
@@ -241,3 +591,187 @@ func (c *Controller) processCluster(updateCtx context.Context, workerNum uint, c
 		}
 	}
 }
+
+// newEvent returns a lifecycle event of the cluster whose update is
+// described by entry.
+func newEvent(eventType string, entry *history.Entry, cluster *api.Cluster, err error) *notifier.Event {
+	event := notifier.NewEvent(eventType, cluster)
+	event.LifecycleStatus = entry.LifecycleStatus
+	event.FromVersion = entry.FromVersion
+	event.ToVersion = entry.ToVersion
+	if err != nil {
+		event.Error = err.Error()
+	}
+	return event
+}
+
+// notify sends a lifecycle event, if notifications are configured.
+func (c *Controller) notify(event *notifier.Event) {
+	if c.notifier != nil {
+		c.notifier.Notify(event)
+	}
+}
+
+// healthCheckError is returned if a cluster fails the health check after an
+// update.
+type healthCheckError struct {
+	err error
+}
+
+func (e *healthCheckError) Error() string {
+	return fmt.Sprintf("health check failed: %v", e.err)
+}
+
+// healthCheck checks the health of a cluster after an update, if supported by
+// the provisioner.
+func (c *Controller) healthCheck(ctx context.Context, logger *log.Entry, cluster *api.Cluster, channelConfig *channel.Config) error {
+	checker, ok := c.provisioner.(provisioner.HealthChecker)
+	if !ok {
+		return nil
+	}
+
+	err := checker.HealthCheck(ctx, logger, cluster, channelConfig)
+	if err != nil {
+		return &healthCheckError{err: err}
+	}
+	return nil
+}
+
+// checkRollback decides whether a cluster should be rolled back to its last
+// known good version after a failed update. This is the case if the update
+// failed too many times or if the cluster failed the health check. It returns
+// a problem describing the rollback, if any.
+func (c *Controller) checkRollback(logger *log.Entry, clusterInfo *ClusterInfo, attempts int, err error) *api.Problem {
+	if !c.rollback.Enabled() {
+		return nil
+	}
+
+	_, healthCheckFailed := err.(*healthCheckError)
+	if !healthCheckFailed && (c.rollback.FailedAttempts == 0 || uint(attempts) < c.rollback.FailedAttempts) {
+		return nil
+	}
+
+	failedVersion := clusterInfo.NextVersion.ConfigVersion
+	target, ok := lastKnownGood(clusterInfo.Cluster, failedVersion)
+	if !ok {
+		logger.Warnf("Unable to roll back from channel version %s, no known good version", failedVersion)
+		return nil
+	}
+
+	logger.Warnf("Rolling back from channel version %s to %s after %d failed attempts", failedVersion, target, attempts)
+	c.clusterList.RollBack(clusterInfo, failedVersion, target)
+	return &api.Problem{
+		Type:  errTypeRollback,
+		Title: fmt.Sprintf("rolling back from channel version %s to %s", failedVersion, target),
+	}
+}
+
+// backoffProblem returns a problem describing when a failing cluster is
+// retried, or nil if it's retried right away.
+func (c *Controller) backoffProblem(attempts int, backoffUntil time.Time) *api.Problem {
+	if c.backoff.Exhausted(attempts) {
+		return &api.Problem{
+			Type:  errTypeBackoff,
+			Title: fmt.Sprintf("not retrying after %d failed attempts until the next version changes", attempts),
+		}
+	}
+
+	if backoffUntil.IsZero() {
+		return nil
+	}
+
+	return &api.Problem{
+		Type:  errTypeBackoff,
+		Title: fmt.Sprintf("retrying at %s after %d failed attempts", backoffUntil.UTC().Format(time.RFC3339), attempts),
+	}
+}
+
+// withoutBackoffProblems removes outdated backoff problems, only the latest
+// one is relevant.
+func withoutBackoffProblems(problems []*api.Problem) []*api.Problem {
+	result := make([]*api.Problem, 0, len(problems)+2)
+	for _, problem := range problems {
+		if problem.Type != errTypeBackoff {
+			result = append(result, problem)
+		}
+	}
+	return result
+}
+
+// lastKnownGood returns the last channel version a cluster was successfully
+// updated to, other than the failed one. If the update failed, that's the
+// current version of the cluster, if the update succeeded but the cluster
+// failed the health check it's the version before.
+func lastKnownGood(cluster *api.Cluster, failed channel.ConfigVersion) (channel.ConfigVersion, bool) {
+	if cluster.Status == nil {
+		return "", false
+	}
+
+	for _, version := range []string{cluster.Status.CurrentVersion, cluster.Status.LastVersion} {
+		configVersion := api.ParseVersion(version).ConfigVersion
+		if configVersion != "" && configVersion != failed {
+			return configVersion, true
+		}
+	}
+	return "", false
+}
+
+// recordHistory completes a history entry with the outcome of an update and
+// stores it in the history store, if one is configured.
+func (c *Controller) recordHistory(logger *log.Entry, entry *history.Entry, updateCtx context.Context, err error) {
+	if c.history == nil {
+		return
+	}
+
+	switch {
+	case err == nil:
+		entry.Outcome = history.OutcomeSuccess
+	case errors.Cause(err) == interrupt.ErrInterrupted:
+		entry.Outcome = history.OutcomeInterrupted
+	case updateCtx.Err() != nil:
+		entry.Outcome = history.OutcomeCanceled
+		entry.Error = err.Error()
+	default:
+		entry.Outcome = history.OutcomeFailure
+		entry.Error = err.Error()
+	}
+
+	err = c.history.Record(entry)
+	if err != nil {
+		logger.Errorf("Unable to record update history: %s", err)
+	}
+}
+
+// problem converts an error into an api.Problem. Errors of a known category
+// are reported with their own problem type, everything else is reported as a
+// general error.
+func problem(err error) *api.Problem {
+	if errors.Cause(err) == interrupt.ErrInterrupted {
+		return &api.Problem{
+			Type:  errTypeInterrupted,
+			Title: "update interrupted by a controller shutdown, it will be resumed",
+		}
+	}
+	if verificationErr, ok := errors.Cause(err).(*channel.VerificationError); ok {
+		return &api.Problem{
+			Type:     errTypeUnverifiedVersion,
+			Title:    "channel version failed signature verification",
+			Detail:   verificationErr.Reason,
+			Instance: string(verificationErr.Version),
+		}
+	}
+	if healthErr, ok := err.(*healthCheckError); ok {
+		return &api.Problem{
+			Type:   errTypeHealthCheck,
+			Title:  "health check failed",
+			Detail: healthErr.err.Error(),
+		}
+	}
+	if result := provisioner.Problem(err); result != nil {
+		return result
+	}
+	return &api.Problem{
+		Title: err.Error(),
+		Type:  errTypeGeneral,
+	}
+}
@@ -2,15 +2,27 @@ package controller
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"io/ioutil"
 	"math"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
 	"testing"
+	"time"
 
+	dto "github.com/prometheus/client_model/go"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"github.com/zalando-incubator/cluster-lifecycle-manager/api"
 	"github.com/zalando-incubator/cluster-lifecycle-manager/channel"
 	"github.com/zalando-incubator/cluster-lifecycle-manager/config"
+	"github.com/zalando-incubator/cluster-lifecycle-manager/history"
+	"github.com/zalando-incubator/cluster-lifecycle-manager/notifier"
+	"github.com/zalando-incubator/cluster-lifecycle-manager/pkg/updatestrategy"
+	"github.com/zalando-incubator/cluster-lifecycle-manager/pkg/util/interrupt"
 	"github.com/zalando-incubator/cluster-lifecycle-manager/provisioner"
 	"github.com/zalando-incubator/cluster-lifecycle-manager/registry"
 
@@ -238,3 +250,470 @@ func TestCoalesceFailures(t *testing.T) {
 		require.EqualValues(t, math.Min(errorLimit, float64(i+1)), len(registry.theCluster.Status.Problems))
 	}
 }
+
+func counterValue(t *testing.T, counter interface{ Write(*dto.Metric) error }) float64 {
+	var metric dto.Metric
+	require.NoError(t, counter.Write(&metric))
+	return metric.GetCounter().GetValue()
+}
+
+func TestProcessClusterMetrics(t *testing.T) {
+	registry := MockRegistry("ready", nil)
+	controller := New(defaultLogger, registry, &mockErrProvisioner{}, MockChannelSource(defaultVersions, false), defaultOptions)
+
+	err := controller.refresh()
+	require.NoError(t, err)
+
+	failures := clusterUpdates.WithLabelValues(registry.theCluster.ID, resultFailure)
+	before := counterValue(t, failures)
+
+	next := controller.clusterList.SelectNext(func() {})
+	require.NotNil(t, next)
+	controller.processCluster(context.Background(), 0, next)
+
+	require.EqualValues(t, before+1, counterValue(t, failures))
+}
+
+// mockRollingProvisioner rolls only the node pool named rolled.
+type mockRollingProvisioner struct {
+	*mockProvisioner
+	rolled string
+}
+
+func (p *mockRollingProvisioner) Provision(ctx context.Context, logger *log.Entry, cluster *api.Cluster, config *channel.Config) error {
+	updatestrategy.ReportNodePoolRolled(ctx, p.rolled)
+	return nil
+}
+
+func TestProcessClusterHistory(t *testing.T) {
+	dir, err := ioutil.TempDir("", "clm-history")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	store, err := history.NewFileStore(dir)
+	require.NoError(t, err)
+
+	registry := MockRegistry("ready", &api.ClusterStatus{CurrentVersion: "old#123"})
+	registry.theCluster.NodePools = []*api.NodePool{{Name: "master-default"}, {Name: "default-worker"}}
+	options := &Options{
+		AccountFilter: config.DefaultFilter,
+		History:       store,
+	}
+
+	for _, ti := range []struct {
+		provisioner provisioner.Provisioner
+		outcome     string
+		err         string
+		nodePools   []string
+	}{
+		{provisioner: &mockErrProvisioner{}, outcome: history.OutcomeFailure, err: "failed to provision"},
+		{provisioner: &mockRollingProvisioner{rolled: "default-worker"}, outcome: history.OutcomeSuccess, nodePools: []string{"default-worker"}},
+	} {
+		controller := New(defaultLogger, registry, ti.provisioner, MockChannelSource(defaultVersions, false), options)
+		require.NoError(t, controller.refresh())
+
+		next := controller.clusterList.SelectNext(func() {})
+		require.NotNil(t, next)
+		controller.processCluster(context.Background(), 2, next)
+
+		entries, err := store.List(registry.theCluster.ID)
+		require.NoError(t, err)
+		entry := entries[len(entries)-1]
+		require.Equal(t, ti.outcome, entry.Outcome)
+		require.Equal(t, ti.err, entry.Error)
+		require.Equal(t, "old#123", entry.FromVersion)
+		require.Equal(t, next.NextVersion.String(), entry.ToVersion)
+		require.EqualValues(t, 2, entry.Worker)
+		require.Equal(t, ti.nodePools, entry.NodePools)
+		require.False(t, entry.Finished.Before(entry.Started))
+	}
+}
+
+func TestProcessClusterNotifications(t *testing.T) {
+	var events []*notifier.Event
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var event notifier.Event
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&event))
+		events = append(events, &event)
+	}))
+	defer server.Close()
+
+	for _, ti := range []struct {
+		lifecycleStatus string
+		provisioner     provisioner.Provisioner
+		events          []string
+		err             string
+	}{
+		{
+			lifecycleStatus: "ready",
+			provisioner:     &mockErrProvisioner{},
+			events:          []string{notifier.EventUpdateStarted, notifier.EventUpdateFailed},
+			err:             "failed to provision",
+		},
+		{
+			lifecycleStatus: "ready",
+			provisioner:     &mockProvisioner{},
+			events:          []string{notifier.EventUpdateStarted, notifier.EventUpdateSucceeded},
+		},
+		{
+			lifecycleStatus: "decommission-requested",
+			provisioner:     &mockProvisioner{},
+			events:          []string{notifier.EventUpdateStarted, notifier.EventDecommissioned},
+		},
+	} {
+		t.Run(ti.lifecycleStatus, func(t *testing.T) {
+			events = nil
+
+			notify, err := notifier.New(defaultLogger, &notifier.Config{
+				Sinks: []*notifier.SinkConfig{{Type: "webhook", URL: server.URL}},
+			})
+			require.NoError(t, err)
+
+			registry := MockRegistry(ti.lifecycleStatus, &api.ClusterStatus{CurrentVersion: "old#123"})
+			controller := New(defaultLogger, registry, ti.provisioner, MockChannelSource(defaultVersions, false), &Options{
+				AccountFilter: config.DefaultFilter,
+				Notifier:      notify,
+			})
+			require.NoError(t, controller.refresh())
+
+			next := controller.clusterList.SelectNext(func() {})
+			require.NotNil(t, next)
+			controller.processCluster(context.Background(), 1, next)
+			notify.Shutdown()
+
+			var types []string
+			for _, event := range events {
+				types = append(types, event.Type)
+				require.Equal(t, registry.theCluster.ID, event.ClusterID)
+				require.Equal(t, ti.lifecycleStatus, event.LifecycleStatus)
+				require.Equal(t, "old#123", event.FromVersion)
+			}
+			require.Equal(t, ti.events, types)
+			require.Equal(t, ti.err, events[len(events)-1].Error)
+		})
+	}
+}
+
+type mockTypedErrProvisioner struct{ *mockProvisioner }
+
+func (p *mockTypedErrProvisioner) Supports(cluster *api.Cluster) bool {
+	return true
+}
+
+func (p *mockTypedErrProvisioner) Provision(ctx context.Context, logger *log.Entry, cluster *api.Cluster, config *channel.Config) error {
+	return &provisioner.Error{
+		Type:     provisioner.ErrTypeStackFailed,
+		Title:    "CloudFormation stack failed",
+		Instance: "etcd-cluster-etcd",
+		Err:      fmt.Errorf("wait for stack failed with ROLLBACK_COMPLETE"),
+	}
+}
+
+func TestProcessClusterProblems(t *testing.T) {
+	for _, ti := range []struct {
+		provisioner provisioner.Provisioner
+		expected    *api.Problem
+	}{
+		{
+			provisioner: &mockErrProvisioner{},
+			expected: &api.Problem{
+				Type:  errTypeGeneral,
+				Title: "failed to provision",
+			},
+		},
+		{
+			provisioner: &mockTypedErrProvisioner{},
+			expected: &api.Problem{
+				Type:     provisioner.ErrTypeStackFailed,
+				Title:    "CloudFormation stack failed",
+				Detail:   "wait for stack failed with ROLLBACK_COMPLETE",
+				Instance: "etcd-cluster-etcd",
+			},
+		},
+	} {
+		registry := MockRegistry("ready", nil)
+		controller := New(defaultLogger, registry, ti.provisioner, MockChannelSource(defaultVersions, false), defaultOptions)
+		require.NoError(t, controller.refresh())
+
+		next := controller.clusterList.SelectNext(func() {})
+		require.NotNil(t, next)
+		controller.processCluster(context.Background(), 0, next)
+
+		require.Equal(t, []*api.Problem{ti.expected}, registry.lastUpdate.Status.Problems)
+	}
+}
+
+type mockUnverifiedChannelSource struct {
+	*mockChannelSource
+}
+
+func (r *mockUnverifiedChannelSource) Get(logger *log.Entry, version channel.ConfigVersion) (*channel.Config, error) {
+	return nil, &channel.VerificationError{Version: version, Reason: "commit is not signed"}
+}
+
+func TestProcessClusterUnverifiedVersion(t *testing.T) {
+	registry := MockRegistry("ready", nil)
+	channelSource := &mockUnverifiedChannelSource{MockChannelSource(defaultVersions, false).(*mockChannelSource)}
+	controller := New(defaultLogger, registry, &mockProvisioner{}, channelSource, defaultOptions)
+	require.NoError(t, controller.refresh())
+
+	next := controller.clusterList.SelectNext(func() {})
+	require.NotNil(t, next)
+	controller.processCluster(context.Background(), 0, next)
+
+	require.Equal(t, []*api.Problem{
+		{
+			Type:     errTypeUnverifiedVersion,
+			Title:    "channel version failed signature verification",
+			Detail:   "commit is not signed",
+			Instance: "<alpha-sha>",
+		},
+	}, registry.lastUpdate.Status.Problems)
+}
+
+type mockVersionErrProvisioner struct {
+	*mockProvisioner
+	failVersion string
+	unhealthy   bool
+}
+
+func (p *mockVersionErrProvisioner) Supports(cluster *api.Cluster) bool {
+	return true
+}
+
+func (p *mockVersionErrProvisioner) Provision(ctx context.Context, logger *log.Entry, cluster *api.Cluster, config *channel.Config) error {
+	if !p.unhealthy && strings.HasPrefix(cluster.Status.NextVersion, p.failVersion+"#") {
+		return fmt.Errorf("failed to provision")
+	}
+	return nil
+}
+
+func (p *mockVersionErrProvisioner) HealthCheck(ctx context.Context, logger *log.Entry, cluster *api.Cluster, config *channel.Config) error {
+	if p.unhealthy && strings.HasPrefix(cluster.Status.CurrentVersion, p.failVersion+"#") {
+		return fmt.Errorf("API server unreachable")
+	}
+	return nil
+}
+
+func TestRollback(t *testing.T) {
+	for _, ti := range []struct {
+		msg      string
+		rollback config.RollbackConfig
+		attempts int
+		status   *api.ClusterStatus
+	}{
+		{
+			msg:      "after failed attempts",
+			rollback: config.RollbackConfig{FailedAttempts: 2},
+			attempts: 2,
+			status:   &api.ClusterStatus{CurrentVersion: "good#123"},
+		},
+		{
+			msg:      "on failed health check",
+			rollback: config.RollbackConfig{HealthCheck: true},
+			attempts: 1,
+			status:   &api.ClusterStatus{CurrentVersion: "good#123", LastVersion: "older#123"},
+		},
+	} {
+		t.Run(ti.msg, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "clm-history")
+			require.NoError(t, err)
+			defer os.RemoveAll(dir)
+
+			store, err := history.NewFileStore(dir)
+			require.NoError(t, err)
+
+			registry := MockRegistry("ready", ti.status)
+			provisioner := &mockVersionErrProvisioner{failVersion: "<alpha-sha>", unhealthy: ti.rollback.HealthCheck}
+			options := &Options{
+				AccountFilter: config.DefaultFilter,
+				History:       store,
+				Rollback:      ti.rollback,
+			}
+			controller := New(defaultLogger, registry, provisioner, MockChannelSource(defaultVersions, false), options)
+
+			for i := 1; i <= ti.attempts; i++ {
+				require.NoError(t, controller.refresh())
+				next := controller.clusterList.SelectNext(func() {})
+				require.NotNil(t, next)
+				require.EqualValues(t, "<alpha-sha>", next.NextVersion.ConfigVersion)
+				controller.processCluster(context.Background(), 0, next)
+
+				problems := registry.lastUpdate.Status.Problems
+				if i < ti.attempts {
+					require.Len(t, problems, i)
+				} else {
+					require.Equal(t, errTypeRollback, problems[len(problems)-1].Type)
+				}
+			}
+
+			// the rollback is recorded in the history and resumed by a
+			// new instance of the controller
+			entries, err := store.List(registry.theCluster.ID)
+			require.NoError(t, err)
+			require.Equal(t, "<alpha-sha>", entries[len(entries)-1].RollbackFrom)
+			require.Equal(t, "good", entries[len(entries)-1].RollbackTo)
+			controller = New(defaultLogger, registry, provisioner, MockChannelSource(defaultVersions, false), options)
+
+			// the cluster is provisioned at the last known good version
+			require.NoError(t, controller.refresh())
+			next := controller.clusterList.SelectNext(func() {})
+			require.NotNil(t, next)
+			require.EqualValues(t, "good", next.NextVersion.ConfigVersion)
+			controller.processCluster(context.Background(), 0, next)
+
+			require.Equal(t, next.NextVersion.String(), registry.lastUpdate.Status.CurrentVersion)
+			require.Len(t, registry.lastUpdate.Status.Problems, 1)
+			require.Equal(t, errTypeRollback, registry.lastUpdate.Status.Problems[0].Type)
+
+			entries, err = store.List(registry.theCluster.ID)
+			require.NoError(t, err)
+			require.Len(t, entries, ti.attempts+1)
+			require.True(t, entries[ti.attempts].Rollback)
+			require.Equal(t, history.OutcomeSuccess, entries[ti.attempts].Outcome)
+
+			// the cluster stays at the good version while the channel doesn't change
+			require.NoError(t, controller.refresh())
+			require.Nil(t, controller.clusterList.SelectNext(func() {}))
+
+			controller = New(defaultLogger, registry, provisioner, MockChannelSource(defaultVersions, false), options)
+			require.NoError(t, controller.refresh())
+			require.Nil(t, controller.clusterList.SelectNext(func() {}))
+
+			// the rollback ends once the channel changes
+			controller = New(defaultLogger, registry, provisioner, MockChannelSource(map[string]channel.ConfigVersion{"alpha": "<beta-sha>"}, false), options)
+			require.NoError(t, controller.refresh())
+			next = controller.clusterList.SelectNext(func() {})
+			require.NotNil(t, next)
+			require.EqualValues(t, "<beta-sha>", next.NextVersion.ConfigVersion)
+			controller.processCluster(context.Background(), 0, next)
+
+			entries, err = store.List(registry.theCluster.ID)
+			require.NoError(t, err)
+			require.Empty(t, entries[len(entries)-1].RollbackFrom)
+			require.Empty(t, entries[len(entries)-1].RollbackTo)
+
+			controller = New(defaultLogger, registry, provisioner, MockChannelSource(defaultVersions, false), options)
+			require.NoError(t, controller.refresh())
+			next = controller.clusterList.SelectNext(func() {})
+			require.NotNil(t, next)
+			require.EqualValues(t, "<alpha-sha>", next.NextVersion.ConfigVersion)
+		})
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	registry := MockRegistry("ready", nil)
+	options := &Options{
+		AccountFilter: config.DefaultFilter,
+		Backoff: config.BackoffConfig{
+			Initial:    time.Hour,
+			Max:        90 * time.Minute,
+			MaxRetries: 2,
+		},
+	}
+	controller := New(defaultLogger, registry, &mockErrProvisioner{}, MockChannelSource(defaultVersions, false), options)
+
+	backoffProblems := func() []*api.Problem {
+		var result []*api.Problem
+		for _, problem := range registry.lastUpdate.Status.Problems {
+			if problem.Type == errTypeBackoff {
+				result = append(result, problem)
+			}
+		}
+		return result
+	}
+
+	for i, delay := range []time.Duration{time.Hour, 90 * time.Minute, 90 * time.Minute} {
+		require.NoError(t, controller.refresh())
+		next := controller.clusterList.SelectNext(func() {})
+		require.NotNil(t, next, "attempt %d", i+1)
+
+		start := time.Now()
+		controller.processCluster(context.Background(), 0, next)
+		require.Len(t, backoffProblems(), 1)
+
+		// the cluster stays in the queue, but isn't selected
+		require.NoError(t, controller.refresh())
+		require.Nil(t, controller.clusterList.SelectNext(func() {}))
+		require.Len(t, controller.clusterList.PendingUpdates(), 1)
+
+		state := controller.clusterList.Cluster(registry.theCluster.ID)
+		require.Equal(t, i+1, state.FailedAttempts)
+		require.NotNil(t, state.BackoffUntil)
+		require.WithinDuration(t, start.Add(delay), *state.BackoffUntil, time.Minute)
+
+		// pretend the backoff has passed
+		next.backoffUntil = time.Now().Add(-time.Second)
+	}
+
+	// retries are exhausted
+	require.Contains(t, backoffProblems()[0].Title, "not retrying")
+	require.NoError(t, controller.refresh())
+	require.Nil(t, controller.clusterList.SelectNext(func() {}))
+
+	// prioritizing the cluster resets the backoff
+	require.NoError(t, controller.clusterList.Prioritize(registry.theCluster.ID))
+	require.NotNil(t, controller.clusterList.SelectNext(func() {}))
+}
+
+// mockInterruptibleProvisioner provisions a cluster until it's interrupted.
+type mockInterruptibleProvisioner struct {
+	mockProvisioner
+	started chan struct{}
+}
+
+func (p *mockInterruptibleProvisioner) Provision(ctx context.Context, logger *log.Entry, cluster *api.Cluster, config *channel.Config) error {
+	close(p.started)
+	for {
+		if err := interrupt.Check(ctx); err != nil {
+			return err
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestStop(t *testing.T) {
+	dir, err := ioutil.TempDir("", "clm-history")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	store, err := history.NewFileStore(dir)
+	require.NoError(t, err)
+
+	registry := MockRegistry("ready", nil)
+	provisioner := &mockInterruptibleProvisioner{started: make(chan struct{})}
+	controller := New(defaultLogger, registry, provisioner, MockChannelSource(defaultVersions, false), &Options{
+		AccountFilter:     config.DefaultFilter,
+		Interval:          10 * time.Millisecond,
+		ConcurrentUpdates: 1,
+		History:           store,
+	})
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		controller.Run(context.Background())
+	}()
+
+	<-provisioner.started
+	controller.Stop()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("controller didn't stop")
+	}
+
+	// the update is resumed after a restart
+	require.NotEmpty(t, registry.lastUpdate.Status.NextVersion)
+	require.Len(t, registry.lastUpdate.Status.Problems, 1)
+	require.Equal(t, errTypeInterrupted, registry.lastUpdate.Status.Problems[0].Type)
+	entries, err := store.List(registry.theCluster.ID)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	require.Equal(t, history.OutcomeInterrupted, entries[0].Outcome)
+
+	state := controller.clusterList.Cluster(registry.theCluster.ID)
+	require.Equal(t, 0, state.FailedAttempts)
+}
@@ -0,0 +1,206 @@
+package controller
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/zalando-incubator/cluster-lifecycle-manager/api"
+	"github.com/zalando-incubator/cluster-lifecycle-manager/channel"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// Freeze stops the updates of all clusters matching its selectors between
+// Start and End, e.g. during a peak sale event. Empty selectors match all
+// clusters.
+type Freeze struct {
+	Name   string `yaml:"name"`
+	Reason string `yaml:"reason"`
+	// Start and End are RFC 3339 timestamps.
+	Start string `yaml:"start"`
+	End   string `yaml:"end"`
+
+	// Environments selects the clusters by environment.
+	Environments []string `yaml:"environments"`
+	// MinCriticalityLevel selects the clusters with at least this
+	// criticality level.
+	MinCriticalityLevel int32 `yaml:"min_criticality_level"`
+
+	// AllowDecommission allows clusters to be decommissioned during the
+	// freeze.
+	AllowDecommission bool `yaml:"allow_decommission"`
+	// Overrides are the IDs of the clusters which may still be updated,
+	// e.g. to roll out an emergency fix.
+	Overrides []string `yaml:"overrides"`
+
+	start time.Time
+	end   time.Time
+}
+
+// FreezeCalendar is the list of fleet-wide change freezes.
+type FreezeCalendar struct {
+	Freezes []*Freeze `yaml:"freezes"`
+}
+
+// parseFreezeCalendar parses a YAML encoded freeze calendar.
+func parseFreezeCalendar(data []byte) (*FreezeCalendar, error) {
+	var calendar FreezeCalendar
+	err := yaml.Unmarshal(data, &calendar)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, freeze := range calendar.Freezes {
+		freeze.start, err = time.Parse(time.RFC3339, freeze.Start)
+		if err != nil {
+			return nil, fmt.Errorf("invalid start of freeze %s: %v", freeze.Name, err)
+		}
+		freeze.end, err = time.Parse(time.RFC3339, freeze.End)
+		if err != nil {
+			return nil, fmt.Errorf("invalid end of freeze %s: %v", freeze.Name, err)
+		}
+		if !freeze.end.After(freeze.start) {
+			return nil, fmt.Errorf("freeze %s ends before it starts", freeze.Name)
+		}
+	}
+
+	return &calendar, nil
+}
+
+// matches returns true if the freeze stops the update of the cluster at the
+// provided time.
+func (f *Freeze) matches(cluster *api.Cluster, now time.Time) bool {
+	if now.Before(f.start) || !now.Before(f.end) {
+		return false
+	}
+
+	if len(f.Environments) > 0 && !containsString(f.Environments, cluster.Environment) {
+		return false
+	}
+
+	if cluster.CriticalityLevel < f.MinCriticalityLevel {
+		return false
+	}
+
+	if f.AllowDecommission && cluster.LifecycleStatus == statusDecommissionRequested {
+		return false
+	}
+
+	return !containsString(f.Overrides, cluster.ID)
+}
+
+// frozen returns the freeze stopping the update of the cluster at the
+// provided time, or nil if there's none. All methods of a nil calendar return
+// nil.
+func (c *FreezeCalendar) frozen(cluster *api.Cluster, now time.Time) *Freeze {
+	if c == nil {
+		return nil
+	}
+
+	for _, freeze := range c.Freezes {
+		if freeze.matches(cluster, now) {
+			return freeze
+		}
+	}
+	return nil
+}
+
+// nextStart returns the start of the next freeze after the provided time.
+// It returns false if no freeze starts later.
+func (c *FreezeCalendar) nextStart(now time.Time) (time.Time, bool) {
+	if c == nil {
+		return time.Time{}, false
+	}
+
+	var next time.Time
+	for _, freeze := range c.Freezes {
+		if freeze.start.After(now) && (next.IsZero() || freeze.start.Before(next)) {
+			next = freeze.start
+		}
+	}
+	return next, !next.IsZero()
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// FreezeCalendarSource loads the freeze calendar. It's loaded again on every
+// refresh of the cluster list, so that changes don't require a restart. If it
+// can't be loaded, the last valid calendar stays in effect.
+type FreezeCalendarSource interface {
+	Load(logger *log.Entry, channels channel.ConfigVersions) (*FreezeCalendar, error)
+}
+
+// fileFreezeCalendar reads the freeze calendar from a local file.
+type fileFreezeCalendar struct {
+	path string
+}
+
+func (s *fileFreezeCalendar) Load(logger *log.Entry, channels channel.ConfigVersions) (*FreezeCalendar, error) {
+	data, err := ioutil.ReadFile(s.path)
+	if err != nil {
+		return nil, err
+	}
+	return parseFreezeCalendar(data)
+}
+
+// channelFreezeCalendar reads the freeze calendar from a file in the current
+// version of a channel.
+type channelFreezeCalendar struct {
+	configSource channel.ConfigSource
+	channel      string
+	path         string
+}
+
+func (s *channelFreezeCalendar) Load(logger *log.Entry, channels channel.ConfigVersions) (*FreezeCalendar, error) {
+	version, err := channels.Version(s.channel)
+	if err != nil {
+		return nil, err
+	}
+
+	config, err := s.configSource.Get(logger, version)
+	if err != nil {
+		return nil, err
+	}
+	defer s.configSource.Delete(logger, config)
+
+	data, err := ioutil.ReadFile(path.Join(config.Path, s.path))
+	if err != nil {
+		return nil, err
+	}
+	return parseFreezeCalendar(data)
+}
+
+// NewFreezeCalendarSource initializes a source of the freeze calendar based
+// on the uri. Supported are file:///<path>, a local file, and
+// channel://<channel>/<path>, a file in the channel configuration, e.g.
+// channel://master/freezes.yaml.
+func NewFreezeCalendarSource(uri string, configSource channel.ConfigSource) (FreezeCalendarSource, error) {
+	url, err := url.Parse(uri)
+	if err != nil {
+		return nil, err
+	}
+
+	switch url.Scheme {
+	case "file", "":
+		return &fileFreezeCalendar{path: url.Host + url.Path}, nil
+	case "channel":
+		filePath := strings.TrimPrefix(url.Path, "/")
+		if url.Host == "" || filePath == "" {
+			return nil, fmt.Errorf("invalid freeze calendar location %s, expected channel://<channel>/<path>", uri)
+		}
+		return &channelFreezeCalendar{configSource: configSource, channel: url.Host, path: filePath}, nil
+	default:
+		return nil, fmt.Errorf("unknown freeze calendar type: %v", url.Scheme)
+	}
+}
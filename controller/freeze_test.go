@@ -0,0 +1,328 @@
+package controller
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/zalando-incubator/cluster-lifecycle-manager/api"
+	"github.com/zalando-incubator/cluster-lifecycle-manager/channel"
+	"github.com/zalando-incubator/cluster-lifecycle-manager/config"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const testFreezeCalendar = `
+freezes:
+- name: cyber-week
+  reason: Peak sale event
+  start: 2018-11-19T00:00:00+01:00
+  end: 2018-11-27T00:00:00+01:00
+  environments: [production]
+  min_criticality_level: 2
+  allow_decommission: true
+  overrides: [aws:123456789011:eu-central-1:hotfix]
+`
+
+func TestParseFreezeCalendar(t *testing.T) {
+	calendar, err := parseFreezeCalendar([]byte(testFreezeCalendar))
+	require.NoError(t, err)
+	require.Len(t, calendar.Freezes, 1)
+
+	freeze := calendar.Freezes[0]
+	require.Equal(t, "cyber-week", freeze.Name)
+	require.Equal(t, []string{"production"}, freeze.Environments)
+	require.EqualValues(t, 2, freeze.MinCriticalityLevel)
+	require.True(t, freeze.AllowDecommission)
+	require.Equal(t, time.Date(2018, time.November, 18, 23, 0, 0, 0, time.UTC), freeze.start.UTC())
+	require.Equal(t, time.Date(2018, time.November, 26, 23, 0, 0, 0, time.UTC), freeze.end.UTC())
+
+	for _, invalid := range []string{
+		"freezes: foo",
+		"freezes:\n- name: foo\n  start: tomorrow\n  end: 2018-11-27T00:00:00Z",
+		"freezes:\n- name: foo\n  start: 2018-11-27T00:00:00Z",
+		"freezes:\n- name: foo\n  start: 2018-11-27T00:00:00Z\n  end: 2018-11-19T00:00:00Z",
+	} {
+		_, err := parseFreezeCalendar([]byte(invalid))
+		require.Error(t, err, invalid)
+	}
+}
+
+func TestFreezeCalendarFrozen(t *testing.T) {
+	calendar, err := parseFreezeCalendar([]byte(testFreezeCalendar))
+	require.NoError(t, err)
+
+	during := time.Date(2018, time.November, 20, 12, 0, 0, 0, time.UTC)
+	after := time.Date(2018, time.November, 27, 12, 0, 0, 0, time.UTC)
+
+	cluster := func(id, environment string, criticality int32, lifecycleStatus string) *api.Cluster {
+		return &api.Cluster{
+			ID:               "aws:123456789011:eu-central-1:" + id,
+			Environment:      environment,
+			CriticalityLevel: criticality,
+			LifecycleStatus:  lifecycleStatus,
+		}
+	}
+
+	for _, ti := range []struct {
+		msg     string
+		cluster *api.Cluster
+		now     time.Time
+		frozen  bool
+	}{
+		{
+			msg:     "matching cluster",
+			cluster: cluster("prod", "production", 2, "ready"),
+			now:     during,
+			frozen:  true,
+		},
+		{
+			msg:     "after the freeze",
+			cluster: cluster("prod", "production", 2, "ready"),
+			now:     after,
+		},
+		{
+			msg:     "other environment",
+			cluster: cluster("test", "test", 2, "ready"),
+			now:     during,
+		},
+		{
+			msg:     "lower criticality",
+			cluster: cluster("prod", "production", 1, "ready"),
+			now:     during,
+		},
+		{
+			msg:     "decommission allowed",
+			cluster: cluster("prod", "production", 2, "decommission-requested"),
+			now:     during,
+		},
+		{
+			msg:     "override",
+			cluster: cluster("hotfix", "production", 3, "ready"),
+			now:     during,
+		},
+	} {
+		t.Run(ti.msg, func(t *testing.T) {
+			freeze := calendar.frozen(ti.cluster, ti.now)
+			if ti.frozen {
+				require.NotNil(t, freeze)
+				require.Equal(t, "cyber-week", freeze.Name)
+			} else {
+				require.Nil(t, freeze)
+			}
+		})
+	}
+
+	var none *FreezeCalendar
+	require.Nil(t, none.frozen(cluster("prod", "production", 2, "ready"), during))
+}
+
+func TestFreezeCalendarSource(t *testing.T) {
+	dir, err := ioutil.TempDir("", "clm-freeze")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	err = ioutil.WriteFile(path.Join(dir, "freezes.yaml"), []byte(testFreezeCalendar), 0644)
+	require.NoError(t, err)
+
+	configSource := channel.NewDirectory(dir)
+	channels, err := configSource.Update(nil)
+	require.NoError(t, err)
+
+	for _, uri := range []string{"file://" + path.Join(dir, "freezes.yaml"), "channel://master/freezes.yaml"} {
+		source, err := NewFreezeCalendarSource(uri, configSource)
+		require.NoError(t, err)
+
+		calendar, err := source.Load(nil, channels)
+		require.NoError(t, err)
+		require.Len(t, calendar.Freezes, 1)
+	}
+
+	for _, uri := range []string{"channel://master", "channel:///freezes.yaml", "http://example.org/freezes.yaml"} {
+		_, err := NewFreezeCalendarSource(uri, configSource)
+		require.Error(t, err, uri)
+	}
+}
+
+func TestSelectNextFreeze(t *testing.T) {
+	now := time.Now().UTC()
+	calendar, err := parseFreezeCalendar([]byte(`
+freezes:
+- name: now
+  start: ` + now.Add(-time.Hour).Format(time.RFC3339) + `
+  end: ` + now.Add(time.Hour).Format(time.RFC3339) + `
+  environments: [production]
+`))
+	require.NoError(t, err)
+
+	production := &api.Cluster{
+		ID:                    "aws:123456789011:eu-central-1:production",
+		InfrastructureAccount: "aws:123456789011",
+		Environment:           "production",
+		LifecycleStatus:       "ready",
+		Channel:               "dev",
+		Status:                mockStatus,
+	}
+	test := &api.Cluster{
+		ID:                    "aws:123456789012:eu-central-1:test",
+		InfrastructureAccount: "aws:123456789012",
+		Environment:           "test",
+		LifecycleStatus:       "ready",
+		Channel:               "dev",
+		Status:                mockStatus,
+	}
+
+	clusterList := NewClusterList(defaultOptions)
+	clusterList.SetFreezeCalendar(calendar)
+	clusterList.UpdateAvailable(defaultChannels, []*api.Cluster{production, test})
+	require.Equal(t, []string{test.ID}, allClusterIds(clusterList))
+	require.Equal(t, "now", clusterList.Cluster(production.ID).Freeze)
+	require.Empty(t, clusterList.Cluster(test.ID).Freeze)
+
+	clusterList.SetFreezeCalendar(nil)
+	clusterList.UpdateAvailable(defaultChannels, []*api.Cluster{production, test})
+	require.Contains(t, allClusterIds(clusterList), production.ID)
+}
+
+func TestSelectNextFreezeStarted(t *testing.T) {
+	now := time.Now().UTC()
+	calendar, err := parseFreezeCalendar([]byte(`
+freezes:
+- name: now
+  start: ` + now.Add(-time.Hour).Format(time.RFC3339) + `
+  end: ` + now.Add(time.Hour).Format(time.RFC3339) + `
+`))
+	require.NoError(t, err)
+
+	cluster := &api.Cluster{
+		ID:                    "aws:123456789011:eu-central-1:production",
+		InfrastructureAccount: "aws:123456789011",
+		Environment:           "production",
+		LifecycleStatus:       "ready",
+		Channel:               "dev",
+		Status:                mockStatus,
+	}
+
+	// the freeze starts after the last refresh
+	clusterList := NewClusterList(defaultOptions)
+	clusterList.UpdateAvailable(defaultChannels, []*api.Cluster{cluster})
+	clusterList.SetFreezeCalendar(calendar)
+	require.Nil(t, clusterList.SelectNext(dummyCancelFunc))
+
+	// the cluster stays in the queue
+	clusterList.SetFreezeCalendar(nil)
+	require.NotNil(t, clusterList.SelectNext(dummyCancelFunc))
+}
+
+func TestCancelFrozen(t *testing.T) {
+	now := time.Now().UTC()
+	calendar, err := parseFreezeCalendar([]byte(`
+freezes:
+- name: now
+  start: ` + now.Add(-time.Hour).Format(time.RFC3339) + `
+  end: ` + now.Add(time.Hour).Format(time.RFC3339) + `
+  environments: [production]
+`))
+	require.NoError(t, err)
+
+	production := &api.Cluster{
+		ID:                    "aws:123456789011:eu-central-1:production",
+		InfrastructureAccount: "aws:123456789011",
+		Environment:           "production",
+		LifecycleStatus:       "ready",
+		Channel:               "dev",
+		Status:                mockStatus,
+	}
+	test := &api.Cluster{
+		ID:                    "aws:123456789012:eu-central-1:test",
+		InfrastructureAccount: "aws:123456789012",
+		Environment:           "test",
+		LifecycleStatus:       "ready",
+		Channel:               "dev",
+		Status:                mockStatus,
+	}
+
+	clusterList := NewClusterList(defaultOptions)
+	clusterList.UpdateAvailable(defaultChannels, []*api.Cluster{production, test})
+
+	canceled := make(map[string]bool)
+	for i := 0; i < 2; i++ {
+		var next *ClusterInfo
+		next = clusterList.SelectNext(func() { canceled[next.Cluster.ID] = true })
+		require.NotNil(t, next)
+	}
+
+	clusterList.SetFreezeCalendar(calendar)
+	clusterList.CancelFrozen()
+	require.Equal(t, map[string]bool{production.ID: true}, canceled)
+}
+
+func TestFreezeCalendarNextStart(t *testing.T) {
+	calendar, err := parseFreezeCalendar([]byte(`
+freezes:
+- name: later
+  start: 2018-12-20T00:00:00Z
+  end: 2019-01-02T00:00:00Z
+- name: next
+  start: 2018-11-19T00:00:00Z
+  end: 2018-11-27T00:00:00Z
+`))
+	require.NoError(t, err)
+
+	start, ok := calendar.nextStart(time.Date(2018, time.November, 1, 0, 0, 0, 0, time.UTC))
+	require.True(t, ok)
+	require.Equal(t, time.Date(2018, time.November, 19, 0, 0, 0, 0, time.UTC), start)
+
+	start, ok = calendar.nextStart(time.Date(2018, time.November, 20, 0, 0, 0, 0, time.UTC))
+	require.True(t, ok)
+	require.Equal(t, time.Date(2018, time.December, 20, 0, 0, 0, 0, time.UTC), start)
+
+	_, ok = calendar.nextStart(time.Date(2018, time.December, 21, 0, 0, 0, 0, time.UTC))
+	require.False(t, ok)
+
+	var none *FreezeCalendar
+	_, ok = none.nextStart(time.Now())
+	require.False(t, ok)
+}
+
+type mockFreezeCalendarSource struct {
+	calendar *FreezeCalendar
+	err      error
+}
+
+func (s *mockFreezeCalendarSource) Load(logger *log.Entry, channels channel.ConfigVersions) (*FreezeCalendar, error) {
+	return s.calendar, s.err
+}
+
+func TestRefreshKeepsFreezeCalendar(t *testing.T) {
+	now := time.Now().UTC()
+	calendar, err := parseFreezeCalendar([]byte(`
+freezes:
+- name: now
+  start: ` + now.Add(-time.Hour).Format(time.RFC3339) + `
+  end: ` + now.Add(time.Hour).Format(time.RFC3339) + `
+`))
+	require.NoError(t, err)
+
+	source := &mockFreezeCalendarSource{calendar: calendar}
+	registry := MockRegistry("ready", &api.ClusterStatus{CurrentVersion: "old#123"})
+	controller := New(defaultLogger, registry, &mockProvisioner{}, MockChannelSource(defaultVersions, false), &Options{
+		AccountFilter:  config.DefaultFilter,
+		FreezeCalendar: source,
+	})
+	require.NoError(t, controller.refresh())
+	require.Nil(t, controller.clusterList.SelectNext(dummyCancelFunc))
+
+	// a broken calendar doesn't fail the refresh or lift the freeze
+	source.calendar, source.err = nil, errors.New("invalid start of freeze now")
+	require.NoError(t, controller.refresh())
+	require.Nil(t, controller.clusterList.SelectNext(dummyCancelFunc))
+
+	source.calendar, source.err = &FreezeCalendar{}, nil
+	require.NoError(t, controller.refresh())
+	require.NotNil(t, controller.clusterList.SelectNext(dummyCancelFunc))
+}
@@ -0,0 +1,198 @@
+package controller
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+const (
+	clustersPath = "/clusters"
+	queuePath    = "/queue"
+	pausePath    = "/pause"
+	resumePath   = "/resume"
+
+	actionCancel     = "cancel"
+	actionPrioritize = "prioritize"
+	actionHistory    = "history"
+)
+
+// Handler returns an http.Handler exposing the state of the controller as
+// JSON. It's meant to be mounted at the root of the --listen server.
+//
+// If an admin token is configured the handler additionally exposes
+// endpoints for cancelling updates, pausing the controller and prioritizing
+// clusters. Requests to those endpoints must be authenticated with the token
+// passed as a bearer token.
+//
+// If a webhook secret is configured the handler additionally exposes
+// webhooks requesting an immediate refresh of the channels and the cluster
+// list.
+func (c *Controller) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc(clustersPath, c.handleClusters)
+	mux.HandleFunc(clustersPath+"/", c.handleCluster)
+	mux.HandleFunc(queuePath, c.handleQueue)
+	mux.HandleFunc(pausePath, c.admin(c.handlePause(true)))
+	mux.HandleFunc(resumePath, c.admin(c.handlePause(false)))
+	mux.HandleFunc(webhooksPath, c.handleWebhook)
+	return mux
+}
+
+// handleClusters lists the state of all clusters.
+func (c *Controller) handleClusters(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	writeJSON(w, http.StatusOK, c.clusterList.Clusters())
+}
+
+// handleCluster returns the state of the cluster identified by the path or
+// dispatches to one of the cluster actions.
+func (c *Controller) handleCluster(w http.ResponseWriter, r *http.Request) {
+	id := strings.TrimPrefix(r.URL.Path, clustersPath+"/")
+
+	// cluster IDs never contain slashes, anything after the last one
+	// is an action
+	if i := strings.LastIndex(id, "/"); i != -1 {
+		if id[i+1:] == actionHistory {
+			c.handleHistory(w, r, id[:i])
+			return
+		}
+		c.admin(c.handleClusterAction(id[:i], id[i+1:]))(w, r)
+		return
+	}
+
+	if r.Method != http.MethodGet {
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	cluster := c.clusterList.Cluster(id)
+	if cluster == nil {
+		http.Error(w, "cluster not found: "+id, http.StatusNotFound)
+		return
+	}
+	writeJSON(w, http.StatusOK, cluster)
+}
+
+// handleQueue lists the clusters waiting for an update in the order they
+// will be processed.
+func (c *Controller) handleQueue(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	writeJSON(w, http.StatusOK, c.clusterList.PendingUpdates())
+}
+
+// handleHistory lists the recorded update attempts of a cluster.
+func (c *Controller) handleHistory(w http.ResponseWriter, r *http.Request, id string) {
+	if r.Method != http.MethodGet {
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	if c.history == nil {
+		http.Error(w, "update history is disabled", http.StatusNotFound)
+		return
+	}
+
+	entries, err := c.history.List(id)
+	if err != nil {
+		c.logger.Errorf("Failed to read update history: %s", err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, entries)
+}
+
+// handleClusterAction returns a handler performing an admin action on a
+// single cluster.
+func (c *Controller) handleClusterAction(id, action string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var err error
+		switch action {
+		case actionCancel:
+			err = c.clusterList.CancelUpdate(id)
+		case actionPrioritize:
+			err = c.clusterList.Prioritize(id)
+		default:
+			http.NotFound(w, r)
+			return
+		}
+
+		switch err {
+		case nil:
+		case errClusterNotFound:
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		default:
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+
+		c.logger.WithField("cluster", id).Infof("Admin API: %s", action)
+		writeJSON(w, http.StatusOK, c.clusterList.Cluster(id))
+	}
+}
+
+// handlePause returns a handler pausing or resuming the controller.
+func (c *Controller) handlePause(paused bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		c.clusterList.SetPaused(paused)
+		if paused {
+			c.logger.Info("Admin API: pausing cluster updates")
+		} else {
+			c.logger.Info("Admin API: resuming cluster updates")
+		}
+		writeJSON(w, http.StatusOK, map[string]bool{"paused": paused})
+	}
+}
+
+// admin wraps a handler for an admin endpoint. Admin endpoints only accept
+// POST requests authenticated with the configured admin token and are
+// disabled if no token is configured.
+func (c *Controller) admin(handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if c.adminToken == "" {
+			http.Error(w, "admin API is disabled", http.StatusForbidden)
+			return
+		}
+
+		token, ok := bearerToken(r)
+		if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(c.adminToken)) != 1 {
+			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			return
+		}
+
+		if r.Method != http.MethodPost {
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+
+		handler(w, r)
+	}
+}
+
+// bearerToken returns the token of a request authorized with the Bearer
+// scheme. It returns false if the Authorization header uses another scheme or
+// none at all.
+func bearerToken(r *http.Request) (string, bool) {
+	const prefix = "Bearer "
+
+	header := r.Header.Get("Authorization")
+	if !strings.HasPrefix(header, prefix) {
+		return "", false
+	}
+	return header[len(prefix):], true
+}
+
+// writeJSON writes value as the JSON encoded response body.
+func writeJSON(w http.ResponseWriter, status int, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(value)
+}
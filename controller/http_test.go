@@ -0,0 +1,235 @@
+package controller
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/zalando-incubator/cluster-lifecycle-manager/config"
+	"github.com/zalando-incubator/cluster-lifecycle-manager/history"
+)
+
+func TestHandler(t *testing.T) {
+	registry := MockRegistry(statusReady, nil)
+	controller := New(defaultLogger, registry, &mockProvisioner{}, MockChannelSource(defaultVersions, false), defaultOptions)
+	require.NoError(t, controller.refresh())
+
+	handler := controller.Handler()
+
+	for _, tc := range []struct {
+		msg    string
+		method string
+		path   string
+		status int
+	}{
+		{
+			msg:    "list clusters",
+			method: http.MethodGet,
+			path:   "/clusters",
+			status: http.StatusOK,
+		},
+		{
+			msg:    "get a single cluster",
+			method: http.MethodGet,
+			path:   "/clusters/" + registry.theCluster.ID,
+			status: http.StatusOK,
+		},
+		{
+			msg:    "get an unknown cluster",
+			method: http.MethodGet,
+			path:   "/clusters/aws:123456789012:eu-central-1:unknown",
+			status: http.StatusNotFound,
+		},
+		{
+			msg:    "list pending updates",
+			method: http.MethodGet,
+			path:   "/queue",
+			status: http.StatusOK,
+		},
+		{
+			msg:    "the status API is read-only",
+			method: http.MethodPost,
+			path:   "/clusters",
+			status: http.StatusMethodNotAllowed,
+		},
+	} {
+		t.Run(tc.msg, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			handler.ServeHTTP(recorder, httptest.NewRequest(tc.method, tc.path, nil))
+			require.Equal(t, tc.status, recorder.Code)
+		})
+	}
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/queue", nil))
+
+	var queue []*ClusterState
+	require.NoError(t, json.NewDecoder(recorder.Body).Decode(&queue))
+	require.Len(t, queue, 1)
+	require.Equal(t, registry.theCluster.ID, queue[0].ID)
+	require.Equal(t, "normal", queue[0].UpdatePriority)
+}
+
+func TestAdminHandler(t *testing.T) {
+	const token = "secret"
+
+	registry := MockRegistry(statusReady, nil)
+	options := &Options{
+		AccountFilter: config.DefaultFilter,
+		AdminToken:    token,
+	}
+	controller := New(defaultLogger, registry, &mockProvisioner{}, MockChannelSource(defaultVersions, false), options)
+	require.NoError(t, controller.refresh())
+
+	handler := controller.Handler()
+	clusterPath := "/clusters/" + registry.theCluster.ID
+
+	for _, tc := range []struct {
+		msg    string
+		method string
+		path   string
+		token  string
+		status int
+	}{
+		{
+			msg:    "missing token",
+			method: http.MethodPost,
+			path:   "/pause",
+			status: http.StatusUnauthorized,
+		},
+		{
+			msg:    "invalid token",
+			method: http.MethodPost,
+			path:   "/pause",
+			token:  "invalid",
+			status: http.StatusUnauthorized,
+		},
+		{
+			msg:    "admin endpoints only accept POST",
+			method: http.MethodGet,
+			path:   "/pause",
+			token:  token,
+			status: http.StatusMethodNotAllowed,
+		},
+		{
+			msg:    "cancel a cluster that isn't being updated",
+			method: http.MethodPost,
+			path:   clusterPath + "/cancel",
+			token:  token,
+			status: http.StatusConflict,
+		},
+		{
+			msg:    "prioritize an unknown cluster",
+			method: http.MethodPost,
+			path:   "/clusters/aws:123456789012:eu-central-1:unknown/prioritize",
+			token:  token,
+			status: http.StatusNotFound,
+		},
+		{
+			msg:    "unknown action",
+			method: http.MethodPost,
+			path:   clusterPath + "/unknown",
+			token:  token,
+			status: http.StatusNotFound,
+		},
+		{
+			msg:    "prioritize a cluster",
+			method: http.MethodPost,
+			path:   clusterPath + "/prioritize",
+			token:  token,
+			status: http.StatusOK,
+		},
+		{
+			msg:    "pause",
+			method: http.MethodPost,
+			path:   "/pause",
+			token:  token,
+			status: http.StatusOK,
+		},
+	} {
+		t.Run(tc.msg, func(t *testing.T) {
+			request := httptest.NewRequest(tc.method, tc.path, nil)
+			if tc.token != "" {
+				request.Header.Set("Authorization", "Bearer "+tc.token)
+			}
+			recorder := httptest.NewRecorder()
+			handler.ServeHTTP(recorder, request)
+			require.Equal(t, tc.status, recorder.Code)
+		})
+	}
+
+	require.True(t, controller.clusterList.Paused())
+	require.Nil(t, controller.clusterList.SelectNext(dummyCancelFunc))
+
+	cluster := controller.clusterList.Cluster(registry.theCluster.ID)
+	require.NotNil(t, cluster)
+	require.True(t, cluster.Prioritized)
+}
+
+func TestAdminHandlerDisabled(t *testing.T) {
+	controller := New(defaultLogger, MockRegistry(statusReady, nil), &mockProvisioner{}, MockChannelSource(defaultVersions, false), defaultOptions)
+
+	request := httptest.NewRequest(http.MethodPost, "/pause", nil)
+	request.Header.Set("Authorization", "Bearer ")
+	recorder := httptest.NewRecorder()
+	controller.Handler().ServeHTTP(recorder, request)
+	require.Equal(t, http.StatusForbidden, recorder.Code)
+	require.False(t, controller.clusterList.Paused())
+}
+
+func TestHistoryHandler(t *testing.T) {
+	dir, err := ioutil.TempDir("", "clm-history")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	store, err := history.NewFileStore(dir)
+	require.NoError(t, err)
+
+	registry := MockRegistry(statusReady, nil)
+	require.NoError(t, store.Record(&history.Entry{
+		ClusterID: registry.theCluster.ID,
+		Outcome:   history.OutcomeSuccess,
+	}))
+
+	options := &Options{
+		AccountFilter: config.DefaultFilter,
+		History:       store,
+	}
+	handler := New(defaultLogger, registry, &mockProvisioner{}, MockChannelSource(defaultVersions, false), options).Handler()
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/clusters/"+registry.theCluster.ID+"/history", nil))
+	require.Equal(t, http.StatusOK, recorder.Code)
+
+	var entries []*history.Entry
+	require.NoError(t, json.NewDecoder(recorder.Body).Decode(&entries))
+	require.Len(t, entries, 1)
+	require.Equal(t, history.OutcomeSuccess, entries[0].Outcome)
+
+	// the history is not available if no store is configured
+	recorder = httptest.NewRecorder()
+	disabled := New(defaultLogger, registry, &mockProvisioner{}, MockChannelSource(defaultVersions, false), defaultOptions).Handler()
+	disabled.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/clusters/"+registry.theCluster.ID+"/history", nil))
+	require.Equal(t, http.StatusNotFound, recorder.Code)
+}
+
+func TestAdminHandlerRequiresBearerScheme(t *testing.T) {
+	options := &Options{
+		AccountFilter: config.DefaultFilter,
+		AdminToken:    "secret",
+	}
+	controller := New(defaultLogger, MockRegistry(statusReady, nil), &mockProvisioner{}, MockChannelSource(defaultVersions, false), options)
+
+	for _, header := range []string{"secret", "Basic secret", "bearer secret"} {
+		request := httptest.NewRequest(http.MethodPost, "/pause", nil)
+		request.Header.Set("Authorization", header)
+		recorder := httptest.NewRecorder()
+		controller.Handler().ServeHTTP(recorder, request)
+		require.Equal(t, http.StatusUnauthorized, recorder.Code, header)
+	}
+	require.False(t, controller.clusterList.Paused())
+}
@@ -0,0 +1,190 @@
+package controller
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// maintenanceWindowConfigItem restricts the updates of a cluster to one or
+// more time windows, separated by semicolons. Each window has the format
+// "[<days>] <start>-<end> [<timezone>]", e.g. "Mon-Fri 09:00-16:00
+// Europe/Berlin". Days are a comma-separated list of days or day ranges and
+// default to every day, the timezone defaults to UTC. Windows ending before
+// they start cross midnight.
+const maintenanceWindowConfigItem = "maintenance_window"
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+// timeWindow is a daily time range on some days of the week.
+type timeWindow struct {
+	days     [7]bool
+	start    time.Duration
+	end      time.Duration
+	location *time.Location
+}
+
+// contains returns true if t is inside the window.
+func (w *timeWindow) contains(t time.Time) bool {
+	local := t.In(w.location)
+	offset := time.Duration(local.Hour())*time.Hour + time.Duration(local.Minute())*time.Minute + time.Duration(local.Second())*time.Second
+
+	if w.start < w.end {
+		return w.days[local.Weekday()] && offset >= w.start && offset < w.end
+	}
+
+	// the window crosses midnight, it belongs to the day it started on
+	if offset >= w.start {
+		return w.days[local.Weekday()]
+	}
+	if offset < w.end {
+		return w.days[(local.Weekday()+6)%7]
+	}
+	return false
+}
+
+// maintenanceWindows are the time windows in which a cluster may be updated.
+// A cluster without maintenance windows may be updated at any time.
+type maintenanceWindows []*timeWindow
+
+// contains returns true if t is inside any of the windows.
+func (m maintenanceWindows) contains(t time.Time) bool {
+	if len(m) == 0 {
+		return true
+	}
+
+	for _, window := range m {
+		if window.contains(t) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseMaintenanceWindows parses the value of the maintenance window config
+// item.
+func parseMaintenanceWindows(value string) (maintenanceWindows, error) {
+	var result maintenanceWindows
+	for _, spec := range strings.Split(value, ";") {
+		if strings.TrimSpace(spec) == "" {
+			continue
+		}
+
+		window, err := parseTimeWindow(spec)
+		if err != nil {
+			return nil, fmt.Errorf("invalid maintenance window %q: %v", strings.TrimSpace(spec), err)
+		}
+		result = append(result, window)
+	}
+	return result, nil
+}
+
+func parseTimeWindow(spec string) (*timeWindow, error) {
+	fields := strings.Fields(spec)
+
+	window := &timeWindow{location: time.UTC}
+
+	// the days are optional and always come first
+	if len(fields) > 0 && !strings.Contains(fields[0], ":") {
+		err := parseDays(fields[0], &window.days)
+		if err != nil {
+			return nil, err
+		}
+		fields = fields[1:]
+	} else {
+		for day := range window.days {
+			window.days[day] = true
+		}
+	}
+
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("missing time range")
+	}
+
+	times := strings.Split(fields[0], "-")
+	if len(times) != 2 {
+		return nil, fmt.Errorf("invalid time range: %s", fields[0])
+	}
+
+	var err error
+	window.start, err = parseTimeOfDay(times[0])
+	if err != nil {
+		return nil, err
+	}
+	window.end, err = parseTimeOfDay(times[1])
+	if err != nil {
+		return nil, err
+	}
+	if window.start == window.end {
+		return nil, fmt.Errorf("empty time range: %s", fields[0])
+	}
+	fields = fields[1:]
+
+	if len(fields) > 0 {
+		window.location, err = time.LoadLocation(fields[0])
+		if err != nil {
+			return nil, err
+		}
+		fields = fields[1:]
+	}
+
+	if len(fields) > 0 {
+		return nil, fmt.Errorf("unexpected %s", strings.Join(fields, " "))
+	}
+
+	return window, nil
+}
+
+// parseDays parses a comma-separated list of days or day ranges, e.g.
+// "Mon-Wed,Fri".
+func parseDays(spec string, days *[7]bool) error {
+	for _, item := range strings.Split(spec, ",") {
+		bounds := strings.Split(item, "-")
+		if len(bounds) > 2 {
+			return fmt.Errorf("invalid day range: %s", item)
+		}
+
+		first, ok := weekdays[strings.ToLower(bounds[0])]
+		if !ok {
+			return fmt.Errorf("invalid day: %s", bounds[0])
+		}
+
+		last := first
+		if len(bounds) == 2 {
+			last, ok = weekdays[strings.ToLower(bounds[1])]
+			if !ok {
+				return fmt.Errorf("invalid day: %s", bounds[1])
+			}
+		}
+
+		for day := first; ; day = (day + 1) % 7 {
+			days[day] = true
+			if day == last {
+				break
+			}
+		}
+	}
+	return nil
+}
+
+// parseTimeOfDay parses a time of day in the format HH:MM and returns it as
+// the offset since midnight. 24:00 can be used for the end of the day.
+func parseTimeOfDay(value string) (time.Duration, error) {
+	if value == "24:00" {
+		return 24 * time.Hour, nil
+	}
+
+	t, err := time.Parse("15:04", value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time: %s", value)
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
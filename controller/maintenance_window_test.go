@@ -0,0 +1,129 @@
+package controller
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zalando-incubator/cluster-lifecycle-manager/api"
+	"github.com/zalando-incubator/cluster-lifecycle-manager/config"
+)
+
+func TestParseMaintenanceWindows(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	require.NoError(t, err)
+
+	// 2018-05-14 is a Monday
+	at := func(day int, hour int, minute int, location *time.Location) time.Time {
+		return time.Date(2018, time.May, 14+day, hour, minute, 0, 0, location)
+	}
+
+	for _, ti := range []struct {
+		msg     string
+		value   string
+		inside  []time.Time
+		outside []time.Time
+	}{
+		{
+			msg:    "no window",
+			value:  "",
+			inside: []time.Time{at(0, 0, 0, time.UTC), at(6, 23, 59, time.UTC)},
+		},
+		{
+			msg:     "weekdays with timezone",
+			value:   "Mon-Fri 09:00-16:00 Europe/Berlin",
+			inside:  []time.Time{at(0, 9, 0, berlin), at(4, 15, 59, berlin), at(2, 7, 0, time.UTC)},
+			outside: []time.Time{at(0, 8, 59, berlin), at(0, 16, 0, berlin), at(5, 12, 0, berlin), at(2, 15, 0, time.UTC)},
+		},
+		{
+			msg:     "every day in UTC",
+			value:   "02:00-04:00",
+			inside:  []time.Time{at(0, 2, 0, time.UTC), at(6, 3, 30, time.UTC)},
+			outside: []time.Time{at(0, 4, 0, time.UTC), at(3, 1, 59, time.UTC)},
+		},
+		{
+			msg:     "across midnight",
+			value:   "Fri,Sat 22:00-02:00",
+			inside:  []time.Time{at(4, 22, 0, time.UTC), at(5, 1, 0, time.UTC), at(6, 1, 59, time.UTC)},
+			outside: []time.Time{at(4, 1, 0, time.UTC), at(6, 22, 0, time.UTC), at(5, 2, 0, time.UTC)},
+		},
+		{
+			msg:     "multiple windows",
+			value:   "Sat-Mon 00:00-24:00; Wed 12:00-13:00",
+			inside:  []time.Time{at(5, 0, 0, time.UTC), at(0, 23, 59, time.UTC), at(2, 12, 30, time.UTC)},
+			outside: []time.Time{at(1, 12, 30, time.UTC), at(2, 13, 0, time.UTC)},
+		},
+	} {
+		t.Run(ti.msg, func(t *testing.T) {
+			windows, err := parseMaintenanceWindows(ti.value)
+			require.NoError(t, err)
+
+			for _, inside := range ti.inside {
+				assert.True(t, windows.contains(inside), "should be inside: %s", inside)
+			}
+			for _, outside := range ti.outside {
+				assert.False(t, windows.contains(outside), "should be outside: %s", outside)
+			}
+		})
+	}
+}
+
+func TestParseMaintenanceWindowsInvalid(t *testing.T) {
+	for _, value := range []string{
+		"Mon-Fri",
+		"Someday 09:00-16:00",
+		"Mon-Fri 09:00",
+		"Mon-Fri 9-16",
+		"Mon-Fri 09:00-09:00",
+		"Mon-Fri 09:00-16:00 Europe/Nowhere",
+		"Mon-Fri 09:00-16:00 UTC weekly",
+	} {
+		_, err := parseMaintenanceWindows(value)
+		assert.Error(t, err, value)
+	}
+}
+
+func TestSelectNextMaintenanceWindow(t *testing.T) {
+	now := time.Now().UTC()
+	// a window which closed an hour ago and opens again in an hour
+	closed := now.Add(time.Hour).Format("15:04") + "-" + now.Add(-time.Hour).Format("15:04")
+
+	outside := &api.Cluster{
+		ID:                    "aws:123456789011:eu-central-1:outside",
+		InfrastructureAccount: "aws:123456789011",
+		LifecycleStatus:       "ready",
+		Channel:               "dev",
+		Status:                mockStatus,
+		ConfigItems:           map[string]string{maintenanceWindowConfigItem: closed},
+	}
+	decommission := &api.Cluster{
+		ID:                    "aws:123456789011:eu-central-1:decommission",
+		InfrastructureAccount: "aws:123456789011",
+		LifecycleStatus:       "decommission-requested",
+		Channel:               "dev",
+		Status:                mockStatus,
+		ConfigItems:           map[string]string{maintenanceWindowConfigItem: closed},
+	}
+	invalid := &api.Cluster{
+		ID:                    "aws:123456789011:eu-central-1:invalid",
+		InfrastructureAccount: "aws:123456789011",
+		LifecycleStatus:       "ready",
+		Channel:               "dev",
+		Status:                mockStatus,
+		ConfigItems:           map[string]string{maintenanceWindowConfigItem: "whenever"},
+	}
+
+	clusterList := NewClusterList(defaultOptions)
+	clusterList.UpdateAvailable(defaultChannels, []*api.Cluster{outside, decommission, invalid})
+
+	// clusters with an invalid window are processed to report the error
+	assert.Equal(t, []string{invalid.ID}, allClusterIds(clusterList))
+	assert.Len(t, clusterList.PendingUpdates(), 2)
+	assert.Equal(t, closed, clusterList.Cluster(outside.ID).MaintenanceWindow)
+	assert.Contains(t, clusterList.Cluster(invalid.ID).NextError, "invalid maintenance window")
+
+	clusterList = NewClusterList(&Options{AccountFilter: config.DefaultFilter, UrgentIgnoresMaintenanceWindow: true})
+	clusterList.UpdateAvailable(defaultChannels, []*api.Cluster{outside, decommission})
+	assert.Equal(t, []string{decommission.ID}, allClusterIds(clusterList))
+}
@@ -0,0 +1,108 @@
+package controller
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	metricsNamespace = "clm"
+
+	resultSuccess = "success"
+	resultFailure = "failure"
+)
+
+var (
+	clusterUpdateDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: metricsNamespace,
+			Name:      "cluster_update_duration_seconds",
+			Help:      "Time it took to process a cluster update or decommission.",
+			Buckets:   prometheus.ExponentialBuckets(60, 2, 10),
+		},
+		[]string{"cluster"},
+	)
+	clusterUpdates = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "cluster_updates_total",
+			Help:      "Number of processed cluster updates partitioned by result.",
+		},
+		[]string{"cluster", "result"},
+	)
+	pendingUpdates = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Name:      "pending_updates",
+			Help:      "Number of clusters waiting to be picked up by a worker.",
+		},
+	)
+	workerBusy = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Name:      "worker_busy",
+			Help:      "Whether a worker is currently processing a cluster (1) or idle (0).",
+		},
+		[]string{"worker"},
+	)
+	controllerPaused = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Name:      "paused",
+			Help:      "Whether the selection of clusters for updates is paused (1) or not (0).",
+		},
+	)
+	channelUpdateDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: metricsNamespace,
+			Name:      "channel_update_duration_seconds",
+			Help:      "Time it took to refresh the channel configuration source.",
+			Buckets:   prometheus.ExponentialBuckets(0.1, 2, 12),
+		},
+		[]string{"result"},
+	)
+	clusterUpdateBlocked = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Name:      "cluster_update_blocked",
+			Help:      "Whether the updates of a cluster are blocked by an active update block (1) or not (0).",
+		},
+		[]string{"cluster"},
+	)
+	blockedClusters = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Name:      "blocked_clusters",
+			Help:      "Number of clusters with an active update block.",
+		},
+	)
+	refreshRequests = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "refresh_requests_total",
+			Help:      "Number of refreshes requested through webhooks, partitioned by source.",
+		},
+		[]string{"source"},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(
+		clusterUpdateDuration,
+		clusterUpdates,
+		pendingUpdates,
+		workerBusy,
+		controllerPaused,
+		channelUpdateDuration,
+		clusterUpdateBlocked,
+		blockedClusters,
+		refreshRequests,
+	)
+}
+
+// resultLabel returns the value of the result label for the provided error.
+func resultLabel(err error) string {
+	if err != nil {
+		return resultFailure
+	}
+	return resultSuccess
+}
@@ -0,0 +1,197 @@
+package controller
+
+import (
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/zalando-incubator/cluster-lifecycle-manager/api"
+	"github.com/zalando-incubator/cluster-lifecycle-manager/channel"
+	"github.com/zalando-incubator/cluster-lifecycle-manager/config"
+)
+
+const (
+	// canaryConfigItem explicitly marks a cluster as a canary ("true") or
+	// excludes it from the canaries ("false"), regardless of its
+	// criticality level.
+	canaryConfigItem = "rollout_canary"
+
+	// canaryWave is the wave of the canary clusters, the remaining
+	// clusters are assigned to the waves following it.
+	canaryWave = 0
+)
+
+type waveKey struct {
+	versionKey
+	version channel.ConfigVersion
+	wave    int
+}
+
+// rollout assigns the clusters of an environment to rollout waves and decides
+// whether the clusters of a wave are allowed to be updated to a new channel
+// version.
+type rollout struct {
+	config       config.RolloutConfig
+	environments map[string]bool
+
+	// time at which each wave was first seen fully updated to a version
+	completed map[waveKey]time.Time
+
+	// computed by assignWaves
+	waves   map[string]int
+	members map[versionKey][][]*ClusterInfo
+}
+
+func newRollout(rolloutConfig config.RolloutConfig) *rollout {
+	environments := make(map[string]bool, len(rolloutConfig.Environments))
+	for _, env := range rolloutConfig.Environments {
+		environments[env] = true
+	}
+
+	return &rollout{
+		config:       rolloutConfig,
+		environments: environments,
+		completed:    make(map[waveKey]time.Time),
+	}
+}
+
+// enabled returns true if the clusters of the environment are updated in
+// waves.
+func (r *rollout) enabled(environment string) bool {
+	if !r.config.Enabled() {
+		return false
+	}
+	return len(r.environments) == 0 || r.environments[environment]
+}
+
+// isCanary returns true if the cluster belongs to the canary wave.
+func (r *rollout) isCanary(cluster *api.Cluster) bool {
+	if value, ok := cluster.ConfigItems[canaryConfigItem]; ok {
+		canary, err := strconv.ParseBool(value)
+		if err == nil {
+			return canary
+		}
+	}
+	return r.config.CanaryCriticalityLevel > 0 && cluster.CriticalityLevel <= r.config.CanaryCriticalityLevel
+}
+
+// assignWaves assigns every cluster to a rollout wave. Canaries are always in
+// the first wave, the remaining clusters are ordered by criticality level and
+// split according to the configured percentages. Must be called with the
+// cluster list lock held.
+func (r *rollout) assignWaves(clusters map[string]*ClusterInfo) {
+	r.waves = make(map[string]int)
+	r.members = make(map[versionKey][][]*ClusterInfo)
+
+	groups := make(map[versionKey][]*ClusterInfo)
+	for _, clusterInfo := range clusters {
+		cluster := clusterInfo.Cluster
+		if cluster.LifecycleStatus == statusDecommissionRequested || !r.enabled(cluster.Environment) {
+			continue
+		}
+		key := versionKey{environment: cluster.Environment, channel: cluster.Channel}
+		groups[key] = append(groups[key], clusterInfo)
+	}
+
+	for key, group := range groups {
+		sort.Slice(group, func(i, j int) bool {
+			ci, cj := group[i].Cluster, group[j].Cluster
+			if ci.CriticalityLevel != cj.CriticalityLevel {
+				return ci.CriticalityLevel < cj.CriticalityLevel
+			}
+			return ci.ID < cj.ID
+		})
+
+		waves := make([][]*ClusterInfo, len(r.config.Waves)+2)
+
+		var rest []*ClusterInfo
+		for _, clusterInfo := range group {
+			if r.isCanary(clusterInfo.Cluster) {
+				waves[canaryWave] = append(waves[canaryWave], clusterInfo)
+			} else {
+				rest = append(rest, clusterInfo)
+			}
+		}
+
+		wave := canaryWave + 1
+		for i, clusterInfo := range rest {
+			for wave <= len(r.config.Waves) && uint(i)*100 >= r.config.Waves[wave-1]*uint(len(rest)) {
+				wave++
+			}
+			waves[wave] = append(waves[wave], clusterInfo)
+		}
+
+		for wave, members := range waves {
+			for _, clusterInfo := range members {
+				r.waves[clusterInfo.Cluster.ID] = wave
+			}
+		}
+		r.members[key] = waves
+	}
+}
+
+// allowed returns true if the cluster may be updated to its next channel
+// version, i.e. if all clusters in the preceding waves are running that
+// version without problems and the soak time has passed since the last of
+// them was updated. Must be called after assignWaves.
+func (r *rollout) allowed(clusterInfo *ClusterInfo, now time.Time) bool {
+	cluster := clusterInfo.Cluster
+	if !r.enabled(cluster.Environment) {
+		return true
+	}
+
+	key := versionKey{environment: cluster.Environment, channel: cluster.Channel}
+	version := clusterInfo.NextVersion.ConfigVersion
+
+	for wave, members := range r.members[key][:r.waves[cluster.ID]] {
+		if len(members) == 0 {
+			continue
+		}
+
+		for _, member := range members {
+			if member.CurrentVersion.ConfigVersion != version || !healthy(member.Cluster) {
+				return false
+			}
+		}
+
+		completedKey := waveKey{versionKey: key, version: version, wave: wave}
+		completed, ok := r.completed[completedKey]
+		if !ok {
+			completed = now
+			r.completed[completedKey] = completed
+		}
+
+		if now.Sub(completed) < r.config.SoakTime {
+			return false
+		}
+	}
+
+	return true
+}
+
+// prune forgets the completion times of waves for versions which no cluster
+// is updated to anymore.
+func (r *rollout) prune(clusters map[string]*ClusterInfo) {
+	targets := make(map[waveKey]bool)
+	for _, clusterInfo := range clusters {
+		if clusterInfo.NextVersion == nil {
+			continue
+		}
+		key := waveKey{
+			versionKey: versionKey{environment: clusterInfo.Cluster.Environment, channel: clusterInfo.Cluster.Channel},
+			version:    clusterInfo.NextVersion.ConfigVersion,
+		}
+		targets[key] = true
+	}
+
+	for key := range r.completed {
+		if !targets[waveKey{versionKey: key.versionKey, version: key.version}] {
+			delete(r.completed, key)
+		}
+	}
+}
+
+// healthy returns true if the cluster doesn't report any problems.
+func healthy(cluster *api.Cluster) bool {
+	return cluster.Status == nil || len(cluster.Status.Problems) == 0
+}
@@ -0,0 +1,129 @@
+package controller
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/zalando-incubator/cluster-lifecycle-manager/api"
+	"github.com/zalando-incubator/cluster-lifecycle-manager/channel"
+	"github.com/zalando-incubator/cluster-lifecycle-manager/config"
+)
+
+func rolloutCluster(id int, environment string, criticalityLevel int32, version string) *api.Cluster {
+	return &api.Cluster{
+		ID:                    fmt.Sprintf("aws:123456789012:eu-central-1:kube-%d", id),
+		InfrastructureAccount: "aws:123456789012",
+		LifecycleStatus:       "ready",
+		Channel:               "stable",
+		Environment:           environment,
+		CriticalityLevel:      criticalityLevel,
+		ConfigItems:           map[string]string{},
+		Status: &api.ClusterStatus{
+			CurrentVersion: version,
+		},
+	}
+}
+
+func TestAssignWaves(t *testing.T) {
+	var clusters []*api.Cluster
+	for i := 0; i < 10; i++ {
+		clusters = append(clusters, rolloutCluster(i, "production", 2, "old#abc"))
+	}
+	clusters[7].CriticalityLevel = 1
+	clusters[3].ConfigItems[canaryConfigItem] = "true"
+	clusters[7].ConfigItems[canaryConfigItem] = "false"
+	clusters[9].CriticalityLevel = 3
+
+	clusterList := NewClusterList(&Options{
+		AccountFilter: config.DefaultFilter,
+		Rollout: config.RolloutConfig{
+			Waves:                  []uint{25, 50},
+			CanaryCriticalityLevel: 1,
+		},
+	})
+	clusterList.UpdateAvailable(channel.NewGitVersions(map[string]channel.ConfigVersion{"stable": "new"}), clusters)
+
+	waves := make(map[int][]string)
+	for id, wave := range clusterList.rollout.waves {
+		waves[wave] = append(waves[wave], id)
+	}
+
+	assert.ElementsMatch(t, []string{clusters[3].ID}, waves[0])
+	// clusters are ordered by criticality level, the least critical ones are updated first
+	assert.ElementsMatch(t, []string{clusters[7].ID, clusters[0].ID, clusters[1].ID}, waves[1])
+	assert.ElementsMatch(t, []string{clusters[2].ID, clusters[4].ID}, waves[2])
+	assert.ElementsMatch(t, []string{clusters[5].ID, clusters[6].ID, clusters[8].ID, clusters[9].ID}, waves[3])
+}
+
+func TestRolloutWaves(t *testing.T) {
+	channels := channel.NewGitVersions(map[string]channel.ConfigVersion{"stable": "new"})
+
+	canary := rolloutCluster(1, "production", 1, "old#abc")
+	first := rolloutCluster(2, "production", 2, "old#abc")
+	second := rolloutCluster(3, "production", 2, "old#abc")
+	test := rolloutCluster(4, "test", 2, "old#abc")
+
+	rolloutConfig := config.RolloutConfig{
+		Waves:                  []uint{50},
+		CanaryCriticalityLevel: 1,
+		Environments:           []string{"production"},
+	}
+
+	pendingUpdates := func(clusterList *ClusterList) []string {
+		clusterList.UpdateAvailable(channels, []*api.Cluster{canary, first, second, test})
+		return allClusterIds(clusterList)
+	}
+
+	clusterList := NewClusterList(&Options{AccountFilter: config.DefaultFilter, Rollout: rolloutConfig})
+
+	// only the canary and clusters in environments without waves are updated
+	assert.ElementsMatch(t, []string{canary.ID, test.ID}, pendingUpdates(clusterList))
+
+	// the canary is updated, but reports problems
+	canary.Status.CurrentVersion = "new#abc"
+	canary.Status.Problems = []*api.Problem{{Title: "failed"}}
+	assert.NotContains(t, pendingUpdates(clusterList), first.ID)
+
+	// the canary is healthy, continue with the next wave
+	canary.Status.Problems = nil
+	updates := pendingUpdates(clusterList)
+	assert.Contains(t, updates, first.ID)
+	assert.NotContains(t, updates, second.ID)
+
+	first.Status.CurrentVersion = "new#abc"
+	assert.Contains(t, pendingUpdates(clusterList), second.ID)
+}
+
+func TestRolloutSoakTime(t *testing.T) {
+	channels := channel.NewGitVersions(map[string]channel.ConfigVersion{"stable": "new"})
+
+	canary := rolloutCluster(1, "production", 1, "new#abc")
+	other := rolloutCluster(2, "production", 2, "old#abc")
+
+	clusterList := NewClusterList(&Options{
+		AccountFilter: config.DefaultFilter,
+		Rollout: config.RolloutConfig{
+			CanaryCriticalityLevel: 1,
+			SoakTime:               time.Hour,
+		},
+	})
+
+	// the canary has just been updated
+	clusterList.UpdateAvailable(channels, []*api.Cluster{canary, other})
+	assert.NotContains(t, allClusterIds(clusterList), other.ID)
+
+	// the soak time has passed
+	for key := range clusterList.rollout.completed {
+		clusterList.rollout.completed[key] = time.Now().Add(-2 * time.Hour)
+	}
+	clusterList.UpdateAvailable(channels, []*api.Cluster{canary, other})
+	assert.Contains(t, allClusterIds(clusterList), other.ID)
+
+	// a new version restarts the soak time
+	canary.Status.CurrentVersion = "newer#abc"
+	channels = channel.NewGitVersions(map[string]channel.ConfigVersion{"stable": "newer"})
+	clusterList.UpdateAvailable(channels, []*api.Cluster{canary, other})
+	assert.NotContains(t, allClusterIds(clusterList), other.ID)
+}
@@ -0,0 +1,164 @@
+package controller
+
+import (
+	"sort"
+	"time"
+)
+
+// ClusterState describes what the controller currently knows about a single
+// cluster.
+type ClusterState struct {
+	ID                string       `json:"id"`
+	Alias             string       `json:"alias"`
+	Channel           string       `json:"channel"`
+	Environment       string       `json:"environment"`
+	LifecycleStatus   string       `json:"lifecycle_status"`
+	State             string       `json:"state"`
+	UpdatePriority    string       `json:"update_priority"`
+	Prioritized       bool         `json:"prioritized"`
+	QueuePosition     int          `json:"queue_position,omitempty"`
+	CurrentVersion    string       `json:"current_version"`
+	NextVersion       string       `json:"next_version"`
+	NextError         string       `json:"next_error,omitempty"`
+	FailedAttempts    int          `json:"failed_attempts,omitempty"`
+	BackoffUntil      *time.Time   `json:"backoff_until,omitempty"`
+	RollbackFrom      string       `json:"rollback_from,omitempty"`
+	MaintenanceWindow string       `json:"maintenance_window,omitempty"`
+	UpdateBlock       *UpdateBlock `json:"update_block,omitempty"`
+	Freeze            string       `json:"freeze,omitempty"`
+	LastProcessed     *time.Time   `json:"last_processed,omitempty"`
+}
+
+// stateName returns a human readable name of a cluster processing state.
+func stateName(state int) string {
+	switch state {
+	case stateIdle:
+		return "idle"
+	case stateProcessing:
+		return "processing"
+	case stateProcessed:
+		return "processed"
+	default:
+		return "unknown"
+	}
+}
+
+// updatePriorityName returns a human readable name of an update priority.
+func updatePriorityName(priority uint32) string {
+	switch priority {
+	case updatePriorityNone:
+		return "none"
+	case updatePriorityNormal:
+		return "normal"
+	case updatePriorityDecommissionRequested:
+		return "decommission-requested"
+	case updatePriorityAlreadyUpdating:
+		return "already-updating"
+	default:
+		return "unknown"
+	}
+}
+
+// newClusterState returns the ClusterState of a cluster. Only active update
+// blocks and freezes are included. Must be called with the cluster list lock
+// held.
+func (clusterList *ClusterList) newClusterState(clusterInfo *ClusterInfo, queuePosition int, now time.Time) *ClusterState {
+	result := &ClusterState{
+		ID:              clusterInfo.Cluster.ID,
+		Alias:           clusterInfo.Cluster.Alias,
+		Channel:         clusterInfo.Cluster.Channel,
+		Environment:     clusterInfo.Cluster.Environment,
+		LifecycleStatus: clusterInfo.Cluster.LifecycleStatus,
+		State:           stateName(clusterInfo.state),
+		UpdatePriority:  updatePriorityName(clusterInfo.updatePriority),
+		Prioritized:     clusterInfo.prioritized,
+		QueuePosition:   queuePosition,
+		CurrentVersion:  clusterInfo.CurrentVersion.String(),
+		NextVersion:     clusterInfo.NextVersion.String(),
+		FailedAttempts:  clusterInfo.failedAttempts,
+		RollbackFrom:    string(clusterInfo.rollbackFrom),
+
+		MaintenanceWindow: clusterInfo.Cluster.ConfigItems[maintenanceWindowConfigItem],
+	}
+
+	if clusterInfo.NextError != nil {
+		result.NextError = clusterInfo.NextError.Error()
+	}
+
+	if clusterInfo.updateBlock.active(now) {
+		updateBlock := *clusterInfo.updateBlock
+		result.UpdateBlock = &updateBlock
+	}
+
+	if freeze := clusterList.freezes.frozen(clusterInfo.Cluster, now); freeze != nil {
+		result.Freeze = freeze.Name
+	}
+
+	if !clusterInfo.backoffUntil.IsZero() {
+		backoffUntil := clusterInfo.backoffUntil
+		result.BackoffUntil = &backoffUntil
+	}
+
+	if clusterInfo.lastProcessed.After(time.Unix(0, 0)) {
+		lastProcessed := clusterInfo.lastProcessed
+		result.LastProcessed = &lastProcessed
+	}
+
+	return result
+}
+
+// queuePositions returns the 1-based position of every cluster waiting for
+// an update. Must be called with the lock held.
+func (clusterList *ClusterList) queuePositions() map[string]int {
+	result := make(map[string]int, len(clusterList.pendingUpdate))
+	for i, clusterInfo := range clusterList.pendingUpdate {
+		result[clusterInfo.Cluster.ID] = i + 1
+	}
+	return result
+}
+
+// Clusters returns the state of all clusters known to the controller sorted
+// by ID.
+func (clusterList *ClusterList) Clusters() []*ClusterState {
+	clusterList.Lock()
+	defer clusterList.Unlock()
+
+	positions := clusterList.queuePositions()
+	now := time.Now()
+
+	result := make([]*ClusterState, 0, len(clusterList.clusters))
+	for id, clusterInfo := range clusterList.clusters {
+		result = append(result, clusterList.newClusterState(clusterInfo, positions[id], now))
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].ID < result[j].ID
+	})
+	return result
+}
+
+// Cluster returns the state of a single cluster or nil if the cluster is not
+// known to the controller.
+func (clusterList *ClusterList) Cluster(id string) *ClusterState {
+	clusterList.Lock()
+	defer clusterList.Unlock()
+
+	clusterInfo, ok := clusterList.clusters[id]
+	if !ok {
+		return nil
+	}
+	return clusterList.newClusterState(clusterInfo, clusterList.queuePositions()[id], time.Now())
+}
+
+// PendingUpdates returns the state of all clusters waiting for an update in
+// the order they will be picked up by the workers.
+func (clusterList *ClusterList) PendingUpdates() []*ClusterState {
+	clusterList.Lock()
+	defer clusterList.Unlock()
+
+	now := time.Now()
+	result := make([]*ClusterState, 0, len(clusterList.pendingUpdate))
+	for i, clusterInfo := range clusterList.pendingUpdate {
+		result = append(result, clusterList.newClusterState(clusterInfo, i+1, now))
+	}
+	return result
+}
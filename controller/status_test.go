@@ -0,0 +1,70 @@
+package controller
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/zalando-incubator/cluster-lifecycle-manager/api"
+)
+
+func TestClusterListStatus(t *testing.T) {
+	normal := &api.Cluster{
+		ID:                    "aws:123456789011:eu-central-1:normal",
+		InfrastructureAccount: "aws:123456789011",
+		LifecycleStatus:       "ready",
+		Channel:               "dev",
+		Status:                mockStatus,
+	}
+	decommissionRequested := &api.Cluster{
+		ID:                    "aws:123456789012:eu-central-1:decommission-requested",
+		InfrastructureAccount: "aws:123456789012",
+		LifecycleStatus:       "decommission-requested",
+		Channel:               "dev",
+		Status:                mockStatus,
+	}
+	invalidChannel := &api.Cluster{
+		ID:                    "aws:123456789013:eu-central-1:invalid-channel",
+		InfrastructureAccount: "aws:123456789013",
+		LifecycleStatus:       "ready",
+		Channel:               "unknown",
+		Status:                mockStatus,
+	}
+
+	clusterList := NewClusterList(defaultOptions)
+	clusterList.UpdateAvailable(defaultChannels, []*api.Cluster{normal, decommissionRequested, invalidChannel})
+
+	pending := clusterList.PendingUpdates()
+	require.Len(t, pending, 3)
+	require.Equal(t, decommissionRequested.ID, pending[0].ID)
+	require.Equal(t, 1, pending[0].QueuePosition)
+	require.Equal(t, "decommission-requested", pending[0].UpdatePriority)
+
+	next := clusterList.SelectNext(dummyCancelFunc)
+	require.NotNil(t, next)
+	require.Equal(t, decommissionRequested.ID, next.Cluster.ID)
+
+	state := clusterList.Cluster(decommissionRequested.ID)
+	require.NotNil(t, state)
+	require.Equal(t, "processing", state.State)
+	require.Equal(t, 0, state.QueuePosition)
+	require.Nil(t, state.LastProcessed)
+
+	clusterList.ClusterProcessed(next)
+
+	state = clusterList.Cluster(decommissionRequested.ID)
+	require.Equal(t, "processed", state.State)
+	require.NotNil(t, state.LastProcessed)
+
+	state = clusterList.Cluster(invalidChannel.ID)
+	require.NotNil(t, state)
+	require.Equal(t, "unknown channel: unknown", state.NextError)
+	require.Empty(t, state.NextVersion)
+
+	clusters := clusterList.Clusters()
+	require.Len(t, clusters, 3)
+	require.Equal(t, normal.ID, clusters[0].ID)
+	require.Equal(t, "idle", clusters[0].State)
+	require.Equal(t, mockStatus.CurrentVersion, clusters[0].CurrentVersion)
+
+	require.Nil(t, clusterList.Cluster("aws:123456789014:eu-central-1:missing"))
+}
@@ -0,0 +1,54 @@
+package controller
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+)
+
+// updateBlockedConfigItem blocks the updates of a cluster. The value is a
+// JSON object with the reason for the block, its author and an optional
+// expiry, e.g. {"reason": "migrating etcd", "author": "jdoe", "expires":
+// "2018-11-01T00:00:00Z"}. Any other value is the reason of a block without
+// author and expiry.
+const updateBlockedConfigItem = "cluster_update_block"
+
+// UpdateBlock prevents a cluster from being updated until it's removed or
+// expires.
+type UpdateBlock struct {
+	Reason  string     `json:"reason"`
+	Author  string     `json:"author,omitempty"`
+	Expires *time.Time `json:"expires,omitempty"`
+}
+
+// parseUpdateBlock parses the value of the update block config item. Values
+// that can't be parsed still block the updates, with the value as reason, so
+// that a typo doesn't lift a block.
+func parseUpdateBlock(value string) *UpdateBlock {
+	if strings.HasPrefix(strings.TrimSpace(value), "{") {
+		var block UpdateBlock
+		if err := json.Unmarshal([]byte(value), &block); err == nil {
+			return &block
+		}
+	}
+	return &UpdateBlock{Reason: value}
+}
+
+// updateBlockOf returns the update block of a cluster, or nil if its updates
+// aren't blocked.
+func updateBlockOf(configItems map[string]string) *UpdateBlock {
+	value, ok := configItems[updateBlockedConfigItem]
+	if !ok {
+		return nil
+	}
+	return parseUpdateBlock(value)
+}
+
+// active returns true if the block is in effect at the provided time. All
+// methods of a nil block return false.
+func (b *UpdateBlock) active(now time.Time) bool {
+	if b == nil {
+		return false
+	}
+	return b.Expires == nil || now.Before(*b.Expires)
+}
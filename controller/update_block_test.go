@@ -0,0 +1,84 @@
+package controller
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/zalando-incubator/cluster-lifecycle-manager/api"
+)
+
+func TestParseUpdateBlock(t *testing.T) {
+	expires := time.Date(2018, time.November, 1, 0, 0, 0, 0, time.UTC)
+
+	for _, ti := range []struct {
+		msg   string
+		value string
+		block *UpdateBlock
+	}{
+		{
+			msg:   "structured block",
+			value: `{"reason": "migrating etcd", "author": "jdoe", "expires": "2018-11-01T00:00:00Z"}`,
+			block: &UpdateBlock{Reason: "migrating etcd", Author: "jdoe", Expires: &expires},
+		},
+		{
+			msg:   "structured block without expiry",
+			value: `{"reason": "migrating etcd", "author": "jdoe"}`,
+			block: &UpdateBlock{Reason: "migrating etcd", Author: "jdoe"},
+		},
+		{
+			msg:   "plain reason",
+			value: "please don't",
+			block: &UpdateBlock{Reason: "please don't"},
+		},
+		{
+			msg:   "invalid expiry",
+			value: `{"reason": "migrating etcd", "expires": "tomorrow"}`,
+			block: &UpdateBlock{Reason: `{"reason": "migrating etcd", "expires": "tomorrow"}`},
+		},
+	} {
+		t.Run(ti.msg, func(t *testing.T) {
+			require.Equal(t, ti.block, parseUpdateBlock(ti.value))
+		})
+	}
+}
+
+func TestUpdateBlockActive(t *testing.T) {
+	now := time.Now()
+	past := now.Add(-time.Hour)
+	future := now.Add(time.Hour)
+
+	var none *UpdateBlock
+	require.False(t, none.active(now))
+	require.True(t, (&UpdateBlock{Reason: "foo"}).active(now))
+	require.True(t, (&UpdateBlock{Reason: "foo", Expires: &future}).active(now))
+	require.False(t, (&UpdateBlock{Reason: "foo", Expires: &past}).active(now))
+}
+
+func TestExpiredUpdateBlock(t *testing.T) {
+	cluster := &api.Cluster{
+		ID:                    "aws:123456789011:eu-central-1:cluster",
+		InfrastructureAccount: "aws:123456789011",
+		LifecycleStatus:       "ready",
+		Channel:               "dev",
+		Status:                mockStatus,
+		ConfigItems: map[string]string{
+			updateBlockedConfigItem: `{"reason": "migrating etcd", "author": "jdoe", "expires": "` + time.Now().Add(time.Hour).Format(time.RFC3339) + `"}`,
+		},
+	}
+
+	clusterList := NewClusterList(defaultOptions)
+	clusterList.UpdateAvailable(defaultChannels, []*api.Cluster{cluster})
+	require.Empty(t, allClusterIds(clusterList))
+
+	state := clusterList.Cluster(cluster.ID)
+	require.NotNil(t, state.UpdateBlock)
+	require.Equal(t, "migrating etcd", state.UpdateBlock.Reason)
+	require.Equal(t, "jdoe", state.UpdateBlock.Author)
+
+	// expired blocks are ignored
+	cluster.ConfigItems[updateBlockedConfigItem] = `{"reason": "migrating etcd", "author": "jdoe", "expires": "` + time.Now().Add(-time.Hour).Format(time.RFC3339) + `"}`
+	clusterList.UpdateAvailable(defaultChannels, []*api.Cluster{cluster})
+	require.Equal(t, []string{cluster.ID}, allClusterIds(clusterList))
+	require.Nil(t, clusterList.Cluster(cluster.ID).UpdateBlock)
+}
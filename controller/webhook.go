@@ -0,0 +1,163 @@
+package controller
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"hash"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync/atomic"
+)
+
+const (
+	webhooksPath = "/webhooks/"
+
+	webhookGitHub   = "github"
+	webhookGitLab   = "gitlab"
+	webhookGeneric  = "generic"
+	webhookRegistry = "registry"
+
+	// maxWebhookPayload limits the size of the accepted webhook payloads,
+	// push events of large pushes can be quite big.
+	maxWebhookPayload = 10 << 20
+)
+
+// pushEvent contains the fields of GitHub and GitLab push events the
+// controller is interested in.
+type pushEvent struct {
+	Ref string `json:"ref"`
+}
+
+// RequestRefresh asks the controller to refresh the channel configuration
+// and the cluster list without waiting for the next interval, e.g. because
+// a channel or the cluster registry changed. Requests arriving within the
+// refresh debounce period are combined into a single refresh.
+func (c *Controller) RequestRefresh(source string) {
+	refreshRequests.WithLabelValues(source).Inc()
+	select {
+	case c.refreshRequests <- struct{}{}:
+	default:
+		// a refresh is already pending
+	}
+}
+
+// handleWebhook handles the webhook notifications about pushes to the
+// channel configuration repository and changes of the cluster registry.
+// Webhooks are disabled if no webhook secret is configured. Refreshes are
+// refused with 503 by replicas which wouldn't act on them: replicas which
+// aren't the leader and, if a cluster is specified with the cluster query
+// parameter, replicas which don't own the cluster.
+func (c *Controller) handleWebhook(w http.ResponseWriter, r *http.Request) {
+	if c.webhookSecret == "" {
+		http.Error(w, "webhooks are disabled", http.StatusForbidden)
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, maxWebhookPayload))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	source := strings.TrimPrefix(r.URL.Path, webhooksPath)
+
+	var authenticated, refresh bool
+	switch source {
+	case webhookGitHub:
+		authenticated = validGitHubSignature(r, body, c.webhookSecret)
+		refresh = r.Header.Get("X-GitHub-Event") == "push"
+	case webhookGitLab:
+		authenticated = secureEqual(r.Header.Get("X-Gitlab-Token"), c.webhookSecret)
+		event := r.Header.Get("X-Gitlab-Event")
+		refresh = event == "Push Hook" || event == "Tag Push Hook"
+	case webhookGeneric, webhookRegistry:
+		token, ok := bearerToken(r)
+		authenticated = ok && secureEqual(token, c.webhookSecret)
+		refresh = true
+	default:
+		http.NotFound(w, r)
+		return
+	}
+
+	if !authenticated {
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+
+	// other events, e.g. the GitHub ping, are acknowledged but ignored
+	if !refresh {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	logger := c.logger.WithField("webhook", source)
+	if source == webhookGitHub || source == webhookGitLab {
+		var event pushEvent
+		err = json.Unmarshal(body, &event)
+		if err != nil {
+			http.Error(w, "invalid push event: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		logger = logger.WithField("ref", event.Ref)
+	}
+
+	if atomic.LoadInt32(&c.running) == 0 {
+		http.Error(w, "not the leader", http.StatusServiceUnavailable)
+		return
+	}
+
+	if clusterID := r.URL.Query().Get("cluster"); clusterID != "" {
+		if c.shard != nil && !c.shard.Owns(clusterID) {
+			http.Error(w, "cluster is owned by another replica", http.StatusServiceUnavailable)
+			return
+		}
+		logger = logger.WithField("cluster", clusterID)
+	}
+
+	logger.Info("Webhook: refresh requested")
+	c.RequestRefresh(source)
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// validGitHubSignature validates the HMAC signature of a GitHub webhook
+// payload, preferring SHA-256 over the legacy SHA-1 signature.
+func validGitHubSignature(r *http.Request, body []byte, secret string) bool {
+	if signature := r.Header.Get("X-Hub-Signature-256"); signature != "" {
+		return validHMAC(sha256.New, "sha256=", signature, body, secret)
+	}
+	if signature := r.Header.Get("X-Hub-Signature"); signature != "" {
+		return validHMAC(sha1.New, "sha1=", signature, body, secret)
+	}
+	return false
+}
+
+func validHMAC(hashFunc func() hash.Hash, prefix, signature string, body []byte, secret string) bool {
+	if !strings.HasPrefix(signature, prefix) {
+		return false
+	}
+
+	expected, err := hex.DecodeString(strings.TrimPrefix(signature, prefix))
+	if err != nil {
+		return false
+	}
+
+	mac := hmac.New(hashFunc, []byte(secret))
+	mac.Write(body)
+	return hmac.Equal(mac.Sum(nil), expected)
+}
+
+// secureEqual compares a provided token with the expected one in constant
+// time.
+func secureEqual(token, expected string) bool {
+	return subtle.ConstantTimeCompare([]byte(token), []byte(expected)) == 1
+}
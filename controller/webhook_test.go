@@ -0,0 +1,252 @@
+package controller
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/zalando-incubator/cluster-lifecycle-manager/api"
+	"github.com/zalando-incubator/cluster-lifecycle-manager/config"
+	"github.com/zalando-incubator/cluster-lifecycle-manager/registry"
+)
+
+const (
+	webhookSecret = "secret"
+	pushPayload   = `{"ref": "refs/heads/stable"}`
+)
+
+func gitHubSignature(payload string) string {
+	mac := hmac.New(sha256.New, []byte(webhookSecret))
+	mac.Write([]byte(payload))
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestWebhookHandler(t *testing.T) {
+	for _, tc := range []struct {
+		msg       string
+		secret    string
+		method    string
+		path      string
+		headers   map[string]string
+		payload   string
+		stopped   bool
+		shard     Shard
+		status    int
+		refreshed bool
+	}{
+		{
+			msg:       "github push",
+			secret:    webhookSecret,
+			path:      "/webhooks/github",
+			headers:   map[string]string{"X-GitHub-Event": "push", "X-Hub-Signature-256": gitHubSignature(pushPayload)},
+			payload:   pushPayload,
+			status:    http.StatusAccepted,
+			refreshed: true,
+		},
+		{
+			msg:     "github push with an invalid signature",
+			secret:  webhookSecret,
+			path:    "/webhooks/github",
+			headers: map[string]string{"X-GitHub-Event": "push", "X-Hub-Signature-256": gitHubSignature("{}")},
+			payload: pushPayload,
+			status:  http.StatusUnauthorized,
+		},
+		{
+			msg:     "github ping",
+			secret:  webhookSecret,
+			path:    "/webhooks/github",
+			headers: map[string]string{"X-GitHub-Event": "ping", "X-Hub-Signature-256": gitHubSignature("{}")},
+			payload: "{}",
+			status:  http.StatusNoContent,
+		},
+		{
+			msg:       "gitlab push",
+			secret:    webhookSecret,
+			path:      "/webhooks/gitlab",
+			headers:   map[string]string{"X-Gitlab-Event": "Push Hook", "X-Gitlab-Token": webhookSecret},
+			payload:   pushPayload,
+			status:    http.StatusAccepted,
+			refreshed: true,
+		},
+		{
+			msg:     "gitlab push with an invalid token",
+			secret:  webhookSecret,
+			path:    "/webhooks/gitlab",
+			headers: map[string]string{"X-Gitlab-Event": "Push Hook", "X-Gitlab-Token": "invalid"},
+			payload: pushPayload,
+			status:  http.StatusUnauthorized,
+		},
+		{
+			msg:     "gitlab push with an invalid payload",
+			secret:  webhookSecret,
+			path:    "/webhooks/gitlab",
+			headers: map[string]string{"X-Gitlab-Event": "Push Hook", "X-Gitlab-Token": webhookSecret},
+			payload: "ref",
+			status:  http.StatusBadRequest,
+		},
+		{
+			msg:       "generic",
+			secret:    webhookSecret,
+			path:      "/webhooks/generic",
+			headers:   map[string]string{"Authorization": "Bearer " + webhookSecret},
+			status:    http.StatusAccepted,
+			refreshed: true,
+		},
+		{
+			msg:       "registry",
+			secret:    webhookSecret,
+			path:      "/webhooks/registry",
+			headers:   map[string]string{"Authorization": "Bearer " + webhookSecret},
+			status:    http.StatusAccepted,
+			refreshed: true,
+		},
+		{
+			msg:       "registry change of an owned cluster",
+			secret:    webhookSecret,
+			path:      "/webhooks/registry?cluster=owned",
+			headers:   map[string]string{"Authorization": "Bearer " + webhookSecret},
+			shard:     &mockShard{owned: map[string]bool{"owned": true}},
+			status:    http.StatusAccepted,
+			refreshed: true,
+		},
+		{
+			msg:     "registry change of a cluster owned by another replica",
+			secret:  webhookSecret,
+			path:    "/webhooks/registry?cluster=other",
+			headers: map[string]string{"Authorization": "Bearer " + webhookSecret},
+			shard:   &mockShard{owned: map[string]bool{"owned": true}},
+			status:  http.StatusServiceUnavailable,
+		},
+		{
+			msg:       "registry change of a cluster without sharding",
+			secret:    webhookSecret,
+			path:      "/webhooks/registry?cluster=other",
+			headers:   map[string]string{"Authorization": "Bearer " + webhookSecret},
+			status:    http.StatusAccepted,
+			refreshed: true,
+		},
+		{
+			msg:     "replica which isn't the leader",
+			secret:  webhookSecret,
+			path:    "/webhooks/generic",
+			headers: map[string]string{"Authorization": "Bearer " + webhookSecret},
+			stopped: true,
+			status:  http.StatusServiceUnavailable,
+		},
+		{
+			msg:     "generic without the Bearer scheme",
+			secret:  webhookSecret,
+			path:    "/webhooks/generic",
+			headers: map[string]string{"Authorization": webhookSecret},
+			status:  http.StatusUnauthorized,
+		},
+		{
+			msg:    "registry without a token",
+			secret: webhookSecret,
+			path:   "/webhooks/registry",
+			status: http.StatusUnauthorized,
+		},
+		{
+			msg:    "unknown webhook",
+			secret: webhookSecret,
+			path:   "/webhooks/bitbucket",
+			status: http.StatusNotFound,
+		},
+		{
+			msg:     "webhooks only accept POST",
+			secret:  webhookSecret,
+			method:  http.MethodGet,
+			path:    "/webhooks/generic",
+			headers: map[string]string{"Authorization": "Bearer " + webhookSecret},
+			status:  http.StatusMethodNotAllowed,
+		},
+		{
+			msg:     "webhooks are disabled without a secret",
+			path:    "/webhooks/generic",
+			headers: map[string]string{"Authorization": "Bearer "},
+			status:  http.StatusForbidden,
+		},
+	} {
+		t.Run(tc.msg, func(t *testing.T) {
+			controller := New(defaultLogger, MockRegistry(statusReady, nil), &mockProvisioner{}, MockChannelSource(defaultVersions, false), &Options{
+				AccountFilter: config.DefaultFilter,
+				WebhookSecret: tc.secret,
+				Shard:         tc.shard,
+			})
+			if !tc.stopped {
+				// as if Run was running
+				controller.running = 1
+			}
+
+			method := tc.method
+			if method == "" {
+				method = http.MethodPost
+			}
+
+			req := httptest.NewRequest(method, tc.path, strings.NewReader(tc.payload))
+			for key, value := range tc.headers {
+				req.Header.Set(key, value)
+			}
+
+			recorder := httptest.NewRecorder()
+			controller.Handler().ServeHTTP(recorder, req)
+			require.Equal(t, tc.status, recorder.Code)
+
+			if tc.refreshed {
+				require.Len(t, controller.refreshRequests, 1)
+			} else {
+				require.Len(t, controller.refreshRequests, 0)
+			}
+		})
+	}
+}
+
+type notifyingRegistry struct {
+	*mockRegistry
+	listed chan struct{}
+}
+
+func (r *notifyingRegistry) ListClusters(filter registry.Filter) ([]*api.Cluster, error) {
+	r.listed <- struct{}{}
+	return r.mockRegistry.ListClusters(filter)
+}
+
+func TestRequestRefresh(t *testing.T) {
+	registry := &notifyingRegistry{mockRegistry: MockRegistry(statusReady, nil), listed: make(chan struct{}, 10)}
+	controller := New(defaultLogger, registry, &mockProvisioner{}, MockChannelSource(defaultVersions, false), &Options{
+		AccountFilter:   config.DefaultFilter,
+		Interval:        time.Hour,
+		RefreshDebounce: 50 * time.Millisecond,
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go controller.Run(ctx)
+
+	// initial refresh
+	<-registry.listed
+
+	// a burst of requests results in a single refresh
+	for i := 0; i < 5; i++ {
+		controller.RequestRefresh(webhookGeneric)
+	}
+
+	select {
+	case <-registry.listed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("no refresh after the debounce period")
+	}
+
+	select {
+	case <-registry.listed:
+		t.Fatal("unexpected refresh")
+	case <-time.After(200 * time.Millisecond):
+	}
+}
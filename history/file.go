@@ -0,0 +1,120 @@
+package history
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+)
+
+const fileStoreSuffix = ".jsonl"
+
+// fileStore stores the history of every cluster in a separate file in a
+// directory. Each line of a file contains a JSON encoded entry.
+type fileStore struct {
+	sync.Mutex
+	dir string
+}
+
+// NewFileStore returns a history store persisting entries in dir. The
+// directory is created if it doesn't exist.
+func NewFileStore(dir string) (Store, error) {
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		return nil, err
+	}
+	return &fileStore{dir: dir}, nil
+}
+
+// filename returns the name of the file storing the history of a cluster.
+func (s *fileStore) filename(clusterID string) string {
+	return path.Join(s.dir, strings.Replace(clusterID, "/", "_", -1)+fileStoreSuffix)
+}
+
+func (s *fileStore) Record(entry *Entry) error {
+	if entry == nil || entry.ClusterID == "" {
+		return fmt.Errorf("failed to record history entry: missing cluster ID")
+	}
+
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	s.Lock()
+	defer s.Unlock()
+
+	file, err := os.OpenFile(s.filename(entry.ClusterID), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	_, err = file.Write(append(data, '\n'))
+	return err
+}
+
+func (s *fileStore) List(clusterID string) ([]*Entry, error) {
+	s.Lock()
+	defer s.Unlock()
+
+	var filenames []string
+	if clusterID != "" {
+		filenames = []string{s.filename(clusterID)}
+	} else {
+		files, err := ioutil.ReadDir(s.dir)
+		if err != nil {
+			return nil, err
+		}
+		for _, file := range files {
+			if !file.IsDir() && strings.HasSuffix(file.Name(), fileStoreSuffix) {
+				filenames = append(filenames, path.Join(s.dir, file.Name()))
+			}
+		}
+	}
+
+	result := []*Entry{}
+	for _, filename := range filenames {
+		entries, err := readEntries(filename)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, entries...)
+	}
+
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].Started.Before(result[j].Started)
+	})
+	return result, nil
+}
+
+// readEntries reads all entries stored in a file. A missing file is treated
+// as an empty history.
+func readEntries(filename string) ([]*Entry, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer file.Close()
+
+	var result []*Entry
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(nil, 1024*1024)
+	for scanner.Scan() {
+		var entry Entry
+		err := json.Unmarshal(scanner.Bytes(), &entry)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse history entry in %s: %v", filename, err)
+		}
+		result = append(result, &entry)
+	}
+	return result, scanner.Err()
+}
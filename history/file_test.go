@@ -0,0 +1,74 @@
+package history
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "clm-history")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	store, err := NewStore("file://" + path.Join(dir, "history"))
+	require.NoError(t, err)
+
+	entries, err := store.List("aws:123456789012:eu-central-1:kube-1")
+	require.NoError(t, err)
+	require.Empty(t, entries)
+
+	now := time.Now().UTC().Truncate(time.Second)
+	for _, entry := range []*Entry{
+		{
+			ClusterID: "aws:123456789012:eu-central-1:kube-1",
+			ToVersion: "abc#123",
+			Started:   now,
+			Finished:  now.Add(time.Minute),
+			Outcome:   OutcomeFailure,
+			Error:     "failed to provision",
+		},
+		{
+			ClusterID: "aws:123456789013:eu-central-1:kube-1",
+			ToVersion: "abc#456",
+			Started:   now.Add(2 * time.Minute),
+			Finished:  now.Add(3 * time.Minute),
+			Outcome:   OutcomeSuccess,
+		},
+		{
+			ClusterID:   "aws:123456789012:eu-central-1:kube-1",
+			FromVersion: "abc#000",
+			ToVersion:   "abc#123",
+			Started:     now.Add(4 * time.Minute),
+			Finished:    now.Add(5 * time.Minute),
+			Outcome:     OutcomeSuccess,
+			NodePools:   []string{"master-default", "default-worker"},
+		},
+	} {
+		require.NoError(t, store.Record(entry))
+	}
+
+	entries, err = store.List("aws:123456789012:eu-central-1:kube-1")
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	require.Equal(t, OutcomeFailure, entries[0].Outcome)
+	require.Equal(t, "failed to provision", entries[0].Error)
+	require.Equal(t, []string{"master-default", "default-worker"}, entries[1].NodePools)
+	require.True(t, now.Add(4*time.Minute).Equal(entries[1].Started))
+
+	entries, err = store.List("")
+	require.NoError(t, err)
+	require.Len(t, entries, 3)
+	require.Equal(t, "aws:123456789013:eu-central-1:kube-1", entries[1].ClusterID)
+
+	require.Error(t, store.Record(&Entry{}))
+}
+
+func TestNewStoreUnknownType(t *testing.T) {
+	_, err := NewStore("s3://bucket/history")
+	require.Error(t, err)
+}
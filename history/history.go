@@ -0,0 +1,66 @@
+package history
+
+import (
+	"fmt"
+	"net/url"
+	"time"
+)
+
+const (
+	// OutcomeSuccess is the outcome of an update which finished without
+	// errors.
+	OutcomeSuccess = "success"
+	// OutcomeFailure is the outcome of an update which failed.
+	OutcomeFailure = "failure"
+	// OutcomeCanceled is the outcome of an update which was aborted before
+	// it could finish, e.g. because the controller was shut down.
+	OutcomeCanceled = "canceled"
+	// OutcomeInterrupted is the outcome of an update which was stopped at
+	// a safe point during a graceful shutdown and is resumed later.
+	OutcomeInterrupted = "interrupted"
+)
+
+// Entry describes a single update attempt of a cluster.
+type Entry struct {
+	ClusterID       string    `json:"cluster_id"`
+	LifecycleStatus string    `json:"lifecycle_status"`
+	FromVersion     string    `json:"from_version"`
+	ToVersion       string    `json:"to_version"`
+	Started         time.Time `json:"started"`
+	Finished        time.Time `json:"finished"`
+	Worker          uint      `json:"worker"`
+	Outcome         string    `json:"outcome"`
+	Error           string    `json:"error,omitempty"`
+	NodePools       []string  `json:"node_pools,omitempty"`
+	Rollback        bool      `json:"rollback,omitempty"`
+	// RollbackFrom and RollbackTo are the channel versions of the rollback
+	// in effect after the update, so that it can be resumed after a
+	// restart.
+	RollbackFrom string `json:"rollback_from,omitempty"`
+	RollbackTo   string `json:"rollback_to,omitempty"`
+}
+
+// Store defines an interface for recording and querying the update history
+// of clusters.
+type Store interface {
+	// Record appends an entry to the history of a cluster.
+	Record(entry *Entry) error
+	// List returns the history of a cluster ordered by start time. If
+	// clusterID is empty the history of all clusters is returned.
+	List(clusterID string) ([]*Entry, error)
+}
+
+// NewStore initializes a new history store based on the uri.
+func NewStore(uri string) (Store, error) {
+	url, err := url.Parse(uri)
+	if err != nil {
+		return nil, err
+	}
+
+	switch url.Scheme {
+	case "file", "":
+		return NewFileStore(url.Host + url.Path)
+	default:
+		return nil, fmt.Errorf("unknown history store type: %v", url.Scheme)
+	}
+}
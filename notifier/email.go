@@ -0,0 +1,141 @@
+package notifier
+
+import (
+	"bytes"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/smtp"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// emailSink sends the event messages as plain text emails via SMTP.
+type emailSink struct {
+	addr string
+	host string
+	auth smtp.Auth
+	from string
+	to   []string
+}
+
+// NewEmailSink returns a sink sending emails via the SMTP server at
+// smtp://[user:password@]host:port. STARTTLS is used if the server supports
+// it; credentials are only sent over TLS or to localhost.
+func NewEmailSink(server, from string, to []string) (Sink, error) {
+	url, err := url.Parse(server)
+	if err != nil {
+		return nil, err
+	}
+
+	if url.Scheme != "smtp" || url.Hostname() == "" {
+		return nil, fmt.Errorf("invalid SMTP server, expected smtp://[user:password@]host:port: %s", server)
+	}
+	if from == "" || len(to) == 0 {
+		return nil, fmt.Errorf("email sink requires a sender and at least one recipient")
+	}
+
+	addr := url.Host
+	if url.Port() == "" {
+		addr = net.JoinHostPort(url.Hostname(), "25")
+	}
+
+	sink := &emailSink{
+		addr: addr,
+		host: url.Hostname(),
+		from: from,
+		to:   to,
+	}
+	if url.User != nil {
+		password, _ := url.User.Password()
+		sink.auth = smtp.PlainAuth("", url.User.Username(), password, sink.host)
+	}
+	return sink, nil
+}
+
+func (s *emailSink) Send(event *Event) error {
+	conn, err := net.DialTimeout("tcp", s.addr, sinkTimeout)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	err = conn.SetDeadline(time.Now().Add(sinkTimeout))
+	if err != nil {
+		return err
+	}
+
+	client, err := smtp.NewClient(conn, s.host)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		err = client.StartTLS(&tls.Config{ServerName: s.host})
+		if err != nil {
+			return err
+		}
+	}
+
+	if s.auth != nil {
+		err = client.Auth(s.auth)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = client.Mail(s.from)
+	if err != nil {
+		return err
+	}
+	for _, recipient := range s.to {
+		err = client.Rcpt(recipient)
+		if err != nil {
+			return err
+		}
+	}
+
+	writer, err := client.Data()
+	if err != nil {
+		return err
+	}
+	_, err = writer.Write(s.message(event))
+	if err != nil {
+		return err
+	}
+	err = writer.Close()
+	if err != nil {
+		return err
+	}
+
+	return client.Quit()
+}
+
+// message formats the event as a plain text email.
+func (s *emailSink) message(event *Event) []byte {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "From: %s\r\n", s.from)
+	fmt.Fprintf(&buf, "To: %s\r\n", strings.Join(s.to, ", "))
+	fmt.Fprintf(&buf, "Subject: [CLM] %s: %s\r\n", event.Type, headerValue(event.Alias))
+	fmt.Fprintf(&buf, "Date: %s\r\n", event.Time.Format(time.RFC1123Z))
+	buf.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	buf.WriteString("\r\n")
+	fmt.Fprintf(&buf, "%s\r\n\r\n", event.Message())
+	fmt.Fprintf(&buf, "Cluster:     %s\r\n", event.ClusterID)
+	fmt.Fprintf(&buf, "Owner:       %s\r\n", event.Owner)
+	fmt.Fprintf(&buf, "Environment: %s\r\n", event.Environment)
+	if event.FromVersion != "" {
+		fmt.Fprintf(&buf, "Version:     %s\r\n", event.FromVersion)
+	}
+	if event.ToVersion != "" {
+		fmt.Fprintf(&buf, "Target:      %s\r\n", event.ToVersion)
+	}
+	return buf.Bytes()
+}
+
+// headerValue strips line breaks from a value used in a mail header.
+func headerValue(value string) string {
+	return strings.NewReplacer("\r", "", "\n", "").Replace(value)
+}
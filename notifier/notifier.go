@@ -0,0 +1,341 @@
+package notifier
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+	"github.com/zalando-incubator/cluster-lifecycle-manager/api"
+	yaml "gopkg.in/yaml.v2"
+)
+
+const (
+	// EventUpdateStarted is sent when a worker starts updating or
+	// decommissioning a cluster.
+	EventUpdateStarted = "update-started"
+	// EventUpdateSucceeded is sent when a cluster was updated successfully.
+	EventUpdateSucceeded = "update-succeeded"
+	// EventUpdateFailed is sent when an update or decommission failed.
+	EventUpdateFailed = "update-failed"
+	// EventUpdateCanceled is sent when an update was aborted before it
+	// could finish, e.g. because the controller was shut down.
+	EventUpdateCanceled = "update-canceled"
+	// EventDecommissioned is sent when a cluster was decommissioned.
+	EventDecommissioned = "decommissioned"
+	// EventDrainStuck is sent when draining a node takes longer than the
+	// configured threshold.
+	EventDrainStuck = "drain-stuck"
+
+	sinkTypeWebhook = "webhook"
+	sinkTypeSlack   = "slack"
+	sinkTypeEmail   = "email"
+
+	// sinkTimeout bounds the time spent delivering a notification to a
+	// single sink, so that a slow sink doesn't hold up the delivery to the
+	// others for too long.
+	sinkTimeout = 10 * time.Second
+
+	// maxQueuedEvents is the number of events waiting to be delivered after
+	// which new events are dropped.
+	maxQueuedEvents = 1000
+)
+
+var notifications = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Namespace: "clm",
+		Name:      "notifications_total",
+		Help:      "Number of notifications sent partitioned by sink type and result.",
+	},
+	[]string{"sink", "result"},
+)
+
+var droppedNotifications = prometheus.NewCounter(
+	prometheus.CounterOpts{
+		Namespace: "clm",
+		Name:      "notifications_dropped_total",
+		Help:      "Number of events dropped because too many were waiting to be delivered.",
+	},
+)
+
+func init() {
+	prometheus.MustRegister(notifications)
+	prometheus.MustRegister(droppedNotifications)
+}
+
+// Event describes a change in the lifecycle of a cluster.
+type Event struct {
+	Type            string    `json:"type"`
+	Time            time.Time `json:"time"`
+	ClusterID       string    `json:"cluster_id"`
+	Alias           string    `json:"alias"`
+	Owner           string    `json:"owner"`
+	Environment     string    `json:"environment"`
+	LifecycleStatus string    `json:"lifecycle_status"`
+	FromVersion     string    `json:"from_version,omitempty"`
+	ToVersion       string    `json:"to_version,omitempty"`
+	Node            string    `json:"node,omitempty"`
+	Error           string    `json:"error,omitempty"`
+	Detail          string    `json:"detail,omitempty"`
+}
+
+// NewEvent initializes a new event of the provided type for a cluster.
+func NewEvent(eventType string, cluster *api.Cluster) *Event {
+	return &Event{
+		Type:            eventType,
+		Time:            time.Now().UTC(),
+		ClusterID:       cluster.ID,
+		Alias:           cluster.Alias,
+		Owner:           cluster.Owner,
+		Environment:     cluster.Environment,
+		LifecycleStatus: cluster.LifecycleStatus,
+	}
+}
+
+// Message returns a human readable description of the event.
+func (e *Event) Message() string {
+	cluster := fmt.Sprintf("%s (%s)", e.Alias, e.ClusterID)
+
+	action := "Update"
+	if e.LifecycleStatus == "decommission-requested" {
+		action = "Decommission"
+	}
+
+	var message string
+	switch e.Type {
+	case EventUpdateStarted:
+		message = fmt.Sprintf("%s of cluster %s started", action, cluster)
+	case EventUpdateSucceeded:
+		message = fmt.Sprintf("Update of cluster %s succeeded", cluster)
+	case EventUpdateFailed:
+		message = fmt.Sprintf("%s of cluster %s failed", action, cluster)
+	case EventUpdateCanceled:
+		message = fmt.Sprintf("%s of cluster %s was canceled", action, cluster)
+	case EventDecommissioned:
+		return fmt.Sprintf("Cluster %s was decommissioned", cluster)
+	case EventDrainStuck:
+		message = fmt.Sprintf("Draining node %s of cluster %s is stuck", e.Node, cluster)
+	default:
+		message = fmt.Sprintf("Cluster %s: %s", cluster, e.Type)
+	}
+
+	if e.ToVersion != "" && e.Type != EventDrainStuck {
+		message += " (version " + e.ToVersion + ")"
+	}
+	if e.Detail != "" {
+		message += ", " + e.Detail
+	}
+	if e.Error != "" {
+		message += ": " + e.Error
+	}
+	return message
+}
+
+// Sink delivers notifications to a single destination.
+type Sink interface {
+	Send(event *Event) error
+}
+
+// SinkConfig configures a sink and the events routed to it. Events are only
+// routed to a sink if they match all of its filters, empty filters match all
+// events.
+type SinkConfig struct {
+	// Type is one of webhook, slack and email.
+	Type string `yaml:"type"`
+	// URL is the URL of the webhook, or the SMTP server in the form
+	// smtp://[user:password@]host:port for email sinks.
+	URL  string   `yaml:"url"`
+	From string   `yaml:"from"`
+	To   []string `yaml:"to"`
+
+	Owners       []string `yaml:"owners"`
+	Environments []string `yaml:"environments"`
+	Events       []string `yaml:"events"`
+}
+
+// Config is the configuration of the notifier.
+type Config struct {
+	Sinks []*SinkConfig `yaml:"sinks"`
+	// DrainStuckAfter is the time after which draining a node is reported
+	// as stuck. Disabled if 0.
+	DrainStuckAfter time.Duration `yaml:"drain_stuck_after"`
+}
+
+// route is a sink together with the filters selecting its events.
+type route struct {
+	sinkType     string
+	sink         Sink
+	owners       map[string]bool
+	environments map[string]bool
+	events       map[string]bool
+}
+
+// matches returns true if the event should be delivered to the sink of the
+// route.
+func (r *route) matches(event *Event) bool {
+	return matchesFilter(r.owners, event.Owner) &&
+		matchesFilter(r.environments, event.Environment) &&
+		matchesFilter(r.events, event.Type)
+}
+
+func matchesFilter(filter map[string]bool, value string) bool {
+	return len(filter) == 0 || filter[value]
+}
+
+func toSet(values []string) map[string]bool {
+	result := make(map[string]bool, len(values))
+	for _, value := range values {
+		result[value] = true
+	}
+	return result
+}
+
+// Notifier routes events to the configured sinks. The events are delivered
+// in the background, one after another.
+type Notifier struct {
+	logger          *log.Entry
+	routes          []*route
+	drainStuckAfter time.Duration
+	done            chan struct{}
+
+	// the queue is only closed while holding the mutex, as events may
+	// still be sent after the shutdown
+	mutex  sync.Mutex
+	queue  chan *Event
+	closed bool
+}
+
+// New initializes a new notifier with the provided configuration.
+func New(logger *log.Entry, config *Config) (*Notifier, error) {
+	client := &http.Client{Timeout: sinkTimeout}
+
+	var routes []*route
+
+	for _, sinkConfig := range config.Sinks {
+		var sink Sink
+		var err error
+
+		switch sinkConfig.Type {
+		case sinkTypeWebhook:
+			sink, err = NewWebhookSink(client, sinkConfig.URL)
+		case sinkTypeSlack:
+			sink, err = NewSlackSink(client, sinkConfig.URL)
+		case sinkTypeEmail:
+			sink, err = NewEmailSink(sinkConfig.URL, sinkConfig.From, sinkConfig.To)
+		default:
+			err = fmt.Errorf("unknown sink type: %v", sinkConfig.Type)
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		routes = append(routes, &route{
+			sinkType:     sinkConfig.Type,
+			sink:         sink,
+			owners:       toSet(sinkConfig.Owners),
+			environments: toSet(sinkConfig.Environments),
+			events:       toSet(sinkConfig.Events),
+		})
+	}
+
+	return newNotifier(logger, routes, config.DrainStuckAfter, maxQueuedEvents), nil
+}
+
+// newNotifier initializes a notifier and starts delivering its events.
+func newNotifier(logger *log.Entry, routes []*route, drainStuckAfter time.Duration, queueSize int) *Notifier {
+	notifier := &Notifier{
+		logger:          logger,
+		routes:          routes,
+		drainStuckAfter: drainStuckAfter,
+		done:            make(chan struct{}),
+		queue:           make(chan *Event, queueSize),
+	}
+	go notifier.run()
+	return notifier
+}
+
+// NewFromFile initializes a new notifier with the configuration read from a
+// YAML file.
+func NewFromFile(logger *log.Entry, path string) (*Notifier, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var config Config
+	err = yaml.Unmarshal(data, &config)
+	if err != nil {
+		return nil, fmt.Errorf("invalid notifier configuration %s: %v", path, err)
+	}
+
+	return New(logger, &config)
+}
+
+// DrainStuckAfter returns the time after which draining a node is reported
+// as stuck, or 0 if it's never reported.
+func (n *Notifier) DrainStuckAfter() time.Duration {
+	return n.drainStuckAfter
+}
+
+// Notify queues the event for delivery to all sinks routed to without
+// waiting for it. Events are dropped if too many are waiting already or if
+// the notifier was shut down.
+func (n *Notifier) Notify(event *Event) {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+
+	if n.closed {
+		n.logger.WithField("cluster", event.Alias).Warnf("Dropping %s notification, the notifier is shut down", event.Type)
+		droppedNotifications.Inc()
+		return
+	}
+
+	select {
+	case n.queue <- event:
+	default:
+		n.logger.WithField("cluster", event.Alias).Warnf("Dropping %s notification, %d notifications are waiting to be delivered", event.Type, cap(n.queue))
+		droppedNotifications.Inc()
+	}
+}
+
+// Shutdown delivers the queued events and stops the notifier.
+func (n *Notifier) Shutdown() {
+	n.mutex.Lock()
+	if !n.closed {
+		n.closed = true
+		close(n.queue)
+	}
+	n.mutex.Unlock()
+
+	<-n.done
+}
+
+// run delivers the queued events until the queue is closed.
+func (n *Notifier) run() {
+	defer close(n.done)
+
+	for event := range n.queue {
+		n.deliver(event)
+	}
+}
+
+// deliver sends the event to all sinks routed to. Sinks are tried one after
+// another, failed deliveries are logged but not retried.
+func (n *Notifier) deliver(event *Event) {
+	for _, route := range n.routes {
+		if !route.matches(event) {
+			continue
+		}
+
+		err := route.sink.Send(event)
+		if err != nil {
+			n.logger.WithField("cluster", event.Alias).Errorf("Failed to send %s notification to %s sink: %s", event.Type, route.sinkType, err)
+			notifications.WithLabelValues(route.sinkType, "failure").Inc()
+			continue
+		}
+		notifications.WithLabelValues(route.sinkType, "success").Inc()
+	}
+}
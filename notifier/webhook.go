@@ -0,0 +1,93 @@
+package notifier
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+)
+
+// webhookSink posts the events JSON encoded to a URL.
+type webhookSink struct {
+	client *http.Client
+	url    string
+}
+
+// NewWebhookSink returns a sink posting the events as JSON to an HTTP(S)
+// endpoint.
+func NewWebhookSink(client *http.Client, endpoint string) (Sink, error) {
+	err := validateEndpoint(endpoint)
+	if err != nil {
+		return nil, err
+	}
+	return &webhookSink{client: client, url: endpoint}, nil
+}
+
+func (s *webhookSink) Send(event *Event) error {
+	return postJSON(s.client, s.url, event)
+}
+
+// slackMessage is the payload of a Slack-compatible incoming webhook.
+type slackMessage struct {
+	Text string `json:"text"`
+}
+
+// slackSink posts the event messages to a Slack-compatible incoming webhook.
+type slackSink struct {
+	client *http.Client
+	url    string
+}
+
+// NewSlackSink returns a sink posting the event messages to a
+// Slack-compatible incoming webhook.
+func NewSlackSink(client *http.Client, endpoint string) (Sink, error) {
+	err := validateEndpoint(endpoint)
+	if err != nil {
+		return nil, err
+	}
+	return &slackSink{client: client, url: endpoint}, nil
+}
+
+func (s *slackSink) Send(event *Event) error {
+	return postJSON(s.client, s.url, &slackMessage{Text: event.Message()})
+}
+
+// validateEndpoint checks that endpoint is an HTTP(S) URL.
+func validateEndpoint(endpoint string) error {
+	url, err := url.Parse(endpoint)
+	if err != nil {
+		return err
+	}
+
+	switch url.Scheme {
+	case "http", "https":
+		return nil
+	default:
+		return fmt.Errorf("invalid webhook URL, expected http or https: %s", endpoint)
+	}
+}
+
+// postJSON posts the JSON encoded payload to endpoint.
+func postJSON(client *http.Client, endpoint string, payload interface{}) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	resp, err := client.Post(endpoint, "application/json", bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	// drain the body so that the connection can be reused
+	io.Copy(ioutil.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected response status: %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,39 @@
+package notifier
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWebhookSinks(t *testing.T) {
+	var payload map[string]interface{}
+	status := http.StatusOK
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, http.MethodPost, r.Method)
+		require.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		payload = nil
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&payload))
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	event := &Event{Type: EventUpdateSucceeded, ClusterID: "aws:123456789012:eu-central-1:kube-1", Alias: "kube-1"}
+
+	webhook, err := NewWebhookSink(server.Client(), server.URL)
+	require.NoError(t, err)
+	require.NoError(t, webhook.Send(event))
+	require.Equal(t, EventUpdateSucceeded, payload["type"])
+	require.Equal(t, "kube-1", payload["alias"])
+
+	slack, err := NewSlackSink(server.Client(), server.URL)
+	require.NoError(t, err)
+	require.NoError(t, slack.Send(event))
+	require.Equal(t, map[string]interface{}{"text": event.Message()}, payload)
+
+	status = http.StatusInternalServerError
+	require.Error(t, webhook.Send(event))
+}
@@ -0,0 +1,81 @@
+package aws
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/zalando-incubator/cluster-lifecycle-manager/pkg/tracing"
+)
+
+const (
+	traceStartHandlerName = "clm.TraceStartHandler"
+	traceEndHandlerName   = "clm.TraceEndHandler"
+)
+
+// requestTracer records a span for every attempt of an AWS API request.
+type requestTracer struct {
+	sync.Mutex
+	ctx   context.Context
+	spans map[*request.Request]*tracing.Span
+}
+
+// TraceSession returns a copy of the session recording a span for every AWS
+// API call made with it. The spans are children of the span of the request
+// context if the call was made with one, and of the span of ctx otherwise.
+func TraceSession(ctx context.Context, sess *session.Session) *session.Session {
+	tracer := &requestTracer{
+		ctx:   ctx,
+		spans: make(map[*request.Request]*tracing.Span),
+	}
+
+	result := sess.Copy()
+	result.Handlers.Send.PushFrontNamed(request.NamedHandler{Name: traceStartHandlerName, Fn: tracer.start})
+	result.Handlers.Send.PushBackNamed(request.NamedHandler{Name: traceEndHandlerName, Fn: tracer.end})
+	return result
+}
+
+func (t *requestTracer) start(r *request.Request) {
+	parent := r.Context()
+	if tracing.FromContext(parent) == nil {
+		parent = t.ctx
+	}
+
+	_, span := tracing.StartClient(parent,
+		fmt.Sprintf("aws.%s.%s", r.ClientInfo.ServiceName, r.Operation.Name),
+		"aws.service", r.ClientInfo.ServiceName,
+		"aws.operation", r.Operation.Name,
+		"aws.region", aws.StringValue(r.Config.Region),
+		"aws.attempt", strconv.Itoa(r.RetryCount+1))
+	if span == nil {
+		return
+	}
+
+	t.Lock()
+	defer t.Unlock()
+	t.spans[r] = span
+}
+
+func (t *requestTracer) end(r *request.Request) {
+	t.Lock()
+	span, ok := t.spans[r]
+	delete(t.spans, r)
+	t.Unlock()
+
+	if !ok {
+		return
+	}
+
+	err := r.Error
+	if r.HTTPResponse != nil {
+		span.SetAttribute("http.status_code", strconv.Itoa(r.HTTPResponse.StatusCode))
+		if err == nil && r.HTTPResponse.StatusCode >= 400 {
+			err = fmt.Errorf("unexpected response status: %s", r.HTTPResponse.Status)
+		}
+	}
+	span.End(err)
+}
@@ -0,0 +1,106 @@
+package kubernetes
+
+import (
+	"fmt"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+)
+
+// eventSource is the component reported as the source of the recorded
+// events.
+const eventSource = "cluster-lifecycle-manager"
+
+// EventRecorder records Kubernetes Events about the objects of a cluster, so
+// that the users of the cluster can follow what happens during an update with
+// kubectl get events. Events are recorded on a best effort basis: failures
+// are logged but never fail the update. A nil recorder discards all events.
+type EventRecorder struct {
+	client kubernetes.Interface
+	logger *log.Entry
+	now    func() time.Time
+}
+
+// NewEventRecorder initializes a new recorder creating the events with the
+// provided client.
+func NewEventRecorder(logger *log.Entry, client kubernetes.Interface) *EventRecorder {
+	return &EventRecorder{
+		client: client,
+		logger: logger,
+		now:    time.Now,
+	}
+}
+
+// NodeReference returns a reference to the node with the provided name.
+// Node events are looked up by name, so the name is used as the UID as well,
+// like the kubelet does.
+func NodeReference(name string) *v1.ObjectReference {
+	return &v1.ObjectReference{
+		Kind:       "Node",
+		APIVersion: "v1",
+		Name:       name,
+		UID:        types.UID(name),
+	}
+}
+
+// PodReference returns a reference to a pod.
+func PodReference(pod *v1.Pod) *v1.ObjectReference {
+	return &v1.ObjectReference{
+		Kind:            "Pod",
+		APIVersion:      "v1",
+		Namespace:       pod.Namespace,
+		Name:            pod.Name,
+		UID:             pod.UID,
+		ResourceVersion: pod.ResourceVersion,
+	}
+}
+
+// NamespaceReference returns a reference to the namespace with the provided
+// name, used for events which aren't about a single object.
+func NamespaceReference(name string) *v1.ObjectReference {
+	return &v1.ObjectReference{
+		Kind:       "Namespace",
+		APIVersion: "v1",
+		Name:       name,
+	}
+}
+
+// Eventf records an event of the provided type (v1.EventTypeNormal or
+// v1.EventTypeWarning) about object.
+func (r *EventRecorder) Eventf(object *v1.ObjectReference, eventType, reason, messageFmt string, args ...interface{}) {
+	if r == nil {
+		return
+	}
+
+	// events about cluster scoped objects are stored in the default
+	// namespace
+	namespace := object.Namespace
+	if namespace == "" {
+		namespace = metav1.NamespaceDefault
+	}
+
+	now := metav1.NewTime(r.now())
+	event := &v1.Event{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      fmt.Sprintf("%v.%x", object.Name, now.UnixNano()),
+			Namespace: namespace,
+		},
+		InvolvedObject: *object,
+		Reason:         reason,
+		Message:        fmt.Sprintf(messageFmt, args...),
+		Source:         v1.EventSource{Component: eventSource},
+		FirstTimestamp: now,
+		LastTimestamp:  now,
+		Count:          1,
+		Type:           eventType,
+	}
+
+	_, err := r.client.CoreV1().Events(namespace).Create(event)
+	if err != nil {
+		r.logger.WithField("object", object.Kind+"/"+object.Name).Warnf("Failed to record %s event: %v", reason, err)
+	}
+}
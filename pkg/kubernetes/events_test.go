@@ -0,0 +1,49 @@
+package kubernetes
+
+import (
+	"testing"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestEventRecorder(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	recorder := NewEventRecorder(log.WithField("test", "events"), client)
+	recorder.now = func() time.Time { return time.Unix(1500000000, 0) }
+
+	pod := &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "app",
+			Namespace: "team",
+			UID:       "1234",
+		},
+	}
+	recorder.Eventf(PodReference(pod), v1.EventTypeWarning, "ForceTerminating", "Force terminating pod on node %s", "node-a")
+	recorder.Eventf(NodeReference("node-a"), v1.EventTypeNormal, "Cordoning", "Cordoning node")
+
+	podEvents, err := client.CoreV1().Events("team").List(metav1.ListOptions{})
+	require.NoError(t, err)
+	require.Len(t, podEvents.Items, 1)
+	event := podEvents.Items[0]
+	require.Equal(t, "Pod", event.InvolvedObject.Kind)
+	require.EqualValues(t, "1234", event.InvolvedObject.UID)
+	require.Equal(t, "ForceTerminating", event.Reason)
+	require.Equal(t, "Force terminating pod on node node-a", event.Message)
+	require.Equal(t, v1.EventTypeWarning, event.Type)
+	require.Equal(t, eventSource, event.Source.Component)
+
+	// node events are stored in the default namespace
+	nodeEvents, err := client.CoreV1().Events(metav1.NamespaceDefault).List(metav1.ListOptions{})
+	require.NoError(t, err)
+	require.Len(t, nodeEvents.Items, 1)
+	require.EqualValues(t, "node-a", nodeEvents.Items[0].InvolvedObject.UID)
+
+	// a nil recorder discards events
+	var disabled *EventRecorder
+	disabled.Eventf(NodeReference("node-a"), v1.EventTypeNormal, "Cordoning", "Cordoning node")
+}
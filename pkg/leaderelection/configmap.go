@@ -0,0 +1,231 @@
+package leaderelection
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"k8s.io/api/core/v1"
+	apiErrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+// leaderAnnotation is the annotation of the ConfigMap storing the leader
+// record.
+const leaderAnnotation = "cluster-lifecycle-manager.zalando.org/leader"
+
+type leaderRecord struct {
+	HolderIdentity       string    `json:"holderIdentity"`
+	LeaseDurationSeconds int       `json:"leaseDurationSeconds"`
+	AcquireTime          time.Time `json:"acquireTime"`
+	RenewTime            time.Time `json:"renewTime"`
+	LeaderTransitions    int       `json:"leaderTransitions"`
+}
+
+// ConfigMapLock is a lock stored in an annotation of a ConfigMap. Concurrent
+// attempts to acquire the lock are resolved by the optimistic concurrency
+// control of the API server. A ConfigMap is used because the
+// coordination.k8s.io Lease API isn't served by the Kubernetes versions the
+// vendored client-go supports.
+type ConfigMapLock struct {
+	sync.Mutex
+	client    rest.Interface
+	namespace string
+	name      string
+	now       func() time.Time
+
+	// the last observed leader record and when it was observed. The lease
+	// is considered expired based on the local clock only, so the clocks of
+	// the instances don't need to be in sync.
+	observedRecord string
+	observedTime   time.Time
+}
+
+// NewConfigMapLock initializes a new ConfigMap lock. client must be the REST
+// client of the core API group, which unlike the typed clients of the
+// vendored client-go allows aborting requests with a context.
+func NewConfigMapLock(client rest.Interface, namespace, name string) *ConfigMapLock {
+	return &ConfigMapLock{
+		client:    client,
+		namespace: namespace,
+		name:      name,
+		now:       time.Now,
+	}
+}
+
+// NewInClusterConfigMapLock initializes a new ConfigMap lock in the cluster
+// CLM is running in.
+func NewInClusterConfigMapLock(namespace, name string) (*ConfigMapLock, error) {
+	cfg, err := rest.InClusterConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := kubernetes.NewForConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewConfigMapLock(client.CoreV1().RESTClient(), namespace, name), nil
+}
+
+// TryAcquire acquires the lock for identity if it's not held by anyone else
+// or the lease of the current holder expired, or renews the lease if identity
+// already holds the lock.
+func (l *ConfigMapLock) TryAcquire(ctx context.Context, identity string, leaseDuration time.Duration) (bool, error) {
+	l.Lock()
+	defer l.Unlock()
+
+	now := l.now()
+	record := leaderRecord{
+		HolderIdentity:       identity,
+		LeaseDurationSeconds: int(leaseDuration / time.Second),
+		AcquireTime:          now,
+		RenewTime:            now,
+	}
+
+	configMap, err := l.get(ctx)
+	if err != nil {
+		if !apiErrors.IsNotFound(err) {
+			return false, err
+		}
+
+		configMap = &v1.ConfigMap{
+			TypeMeta: metav1.TypeMeta{
+				APIVersion: "v1",
+				Kind:       "ConfigMap",
+			},
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      l.name,
+				Namespace: l.namespace,
+			},
+		}
+
+		err = l.write(ctx, l.client.Post().Namespace(l.namespace).Resource("configmaps"), configMap, record)
+		if apiErrors.IsAlreadyExists(err) {
+			return false, nil
+		}
+		if err != nil {
+			return false, err
+		}
+		return true, nil
+	}
+
+	value := configMap.Annotations[leaderAnnotation]
+	if value != l.observedRecord {
+		l.observedRecord = value
+		l.observedTime = now
+	}
+
+	var current leaderRecord
+	if value != "" {
+		err = json.Unmarshal([]byte(value), &current)
+		if err != nil {
+			return false, err
+		}
+	}
+
+	record.LeaderTransitions = current.LeaderTransitions
+	if current.HolderIdentity == identity {
+		record.AcquireTime = current.AcquireTime
+	} else if current.HolderIdentity != "" && now.Before(l.observedTime.Add(time.Duration(current.LeaseDurationSeconds)*time.Second)) {
+		return false, nil
+	} else {
+		record.LeaderTransitions++
+	}
+
+	err = l.write(ctx, l.client.Put().Namespace(l.namespace).Resource("configmaps").Name(l.name), configMap, record)
+	if apiErrors.IsConflict(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// Release releases the lock if it's held by identity, so another instance
+// can take over without waiting for the lease to expire.
+func (l *ConfigMapLock) Release(ctx context.Context, identity string) error {
+	l.Lock()
+	defer l.Unlock()
+
+	configMap, err := l.get(ctx)
+	if err != nil {
+		if apiErrors.IsNotFound(err) {
+			return nil
+		}
+		return err
+	}
+
+	var current leaderRecord
+	if value := configMap.Annotations[leaderAnnotation]; value != "" {
+		err = json.Unmarshal([]byte(value), &current)
+		if err != nil {
+			return err
+		}
+	}
+
+	if current.HolderIdentity != identity {
+		return nil
+	}
+
+	record := leaderRecord{
+		RenewTime:         l.now(),
+		LeaderTransitions: current.LeaderTransitions,
+	}
+
+	err = l.write(ctx, l.client.Put().Namespace(l.namespace).Resource("configmaps").Name(l.name), configMap, record)
+	if apiErrors.IsConflict(err) {
+		return nil
+	}
+	return err
+}
+
+// get returns the ConfigMap storing the lock.
+func (l *ConfigMapLock) get(ctx context.Context) (*v1.ConfigMap, error) {
+	data, err := l.client.Get().Context(ctx).Namespace(l.namespace).Resource("configmaps").Name(l.name).Do().Raw()
+	if err != nil {
+		return nil, err
+	}
+
+	var result v1.ConfigMap
+	err = json.Unmarshal(data, &result)
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// write stores the leader record in the annotation of the ConfigMap, sends it
+// with the request and remembers the record as the last observed one. The
+// resource version of the ConfigMap makes sure that it wasn't changed by
+// anyone else in the meantime.
+func (l *ConfigMapLock) write(ctx context.Context, request *rest.Request, configMap *v1.ConfigMap, record leaderRecord) error {
+	value, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	if configMap.Annotations == nil {
+		configMap.Annotations = make(map[string]string)
+	}
+	configMap.Annotations[leaderAnnotation] = string(value)
+
+	data, err := json.Marshal(configMap)
+	if err != nil {
+		return err
+	}
+
+	err = request.Context(ctx).SetHeader("Content-Type", "application/json").Body(data).Do().Error()
+	if err != nil {
+		return err
+	}
+
+	l.observedRecord = string(value)
+	l.observedTime = l.now()
+	return nil
+}
@@ -0,0 +1,173 @@
+package leaderelection
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+const testConfigMapPath = "/api/v1/namespaces/kube-system/configmaps"
+
+// configMapServer is an API server storing a single ConfigMap.
+type configMapServer struct {
+	sync.Mutex
+	configMap *v1.ConfigMap
+	version   int
+	block     chan struct{}
+}
+
+func (s *configMapServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if s.block != nil {
+		<-s.block
+	}
+
+	s.Lock()
+	defer s.Unlock()
+
+	switch {
+	case r.Method == http.MethodGet && r.URL.Path == testConfigMapPath+"/cluster-lifecycle-manager":
+		if s.configMap == nil {
+			writeStatus(w, http.StatusNotFound, metav1.StatusReasonNotFound)
+			return
+		}
+		json.NewEncoder(w).Encode(s.configMap)
+	case r.Method == http.MethodPost && r.URL.Path == testConfigMapPath:
+		if s.configMap != nil {
+			writeStatus(w, http.StatusConflict, metav1.StatusReasonAlreadyExists)
+			return
+		}
+		s.store(w, r)
+	case r.Method == http.MethodPut && r.URL.Path == testConfigMapPath+"/cluster-lifecycle-manager":
+		var update v1.ConfigMap
+		json.NewDecoder(r.Body).Decode(&update)
+		if s.configMap == nil || update.ResourceVersion != s.configMap.ResourceVersion {
+			writeStatus(w, http.StatusConflict, metav1.StatusReasonConflict)
+			return
+		}
+		s.configMap = &update
+		s.version++
+		s.configMap.ResourceVersion = strconv.Itoa(s.version)
+		json.NewEncoder(w).Encode(s.configMap)
+	default:
+		writeStatus(w, http.StatusNotFound, metav1.StatusReasonNotFound)
+	}
+}
+
+func (s *configMapServer) store(w http.ResponseWriter, r *http.Request) {
+	var created v1.ConfigMap
+	json.NewDecoder(r.Body).Decode(&created)
+	s.configMap = &created
+	s.version++
+	s.configMap.ResourceVersion = strconv.Itoa(s.version)
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(s.configMap)
+}
+
+func (s *configMapServer) record(t *testing.T) leaderRecord {
+	s.Lock()
+	defer s.Unlock()
+
+	var record leaderRecord
+	require.NoError(t, json.Unmarshal([]byte(s.configMap.Annotations[leaderAnnotation]), &record))
+	return record
+}
+
+func writeStatus(w http.ResponseWriter, code int, reason metav1.StatusReason) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(&metav1.Status{
+		TypeMeta: metav1.TypeMeta{Kind: "Status", APIVersion: "v1"},
+		Status:   metav1.StatusFailure,
+		Reason:   reason,
+		Code:     int32(code),
+	})
+}
+
+func newTestConfigMapLock(t *testing.T, server *httptest.Server) *ConfigMapLock {
+	client, err := kubernetes.NewForConfig(&rest.Config{Host: server.URL})
+	require.NoError(t, err)
+	return NewConfigMapLock(client.CoreV1().RESTClient(), "kube-system", "cluster-lifecycle-manager")
+}
+
+func TestConfigMapLock(t *testing.T) {
+	configMaps := &configMapServer{}
+	server := httptest.NewServer(configMaps)
+	defer server.Close()
+
+	now := time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := func() time.Time { return now }
+
+	first := newTestConfigMapLock(t, server)
+	first.now = clock
+	second := newTestConfigMapLock(t, server)
+	second.now = clock
+
+	ctx := context.Background()
+
+	acquired, err := first.TryAcquire(ctx, "first", time.Minute)
+	require.NoError(t, err)
+	require.True(t, acquired)
+	require.Equal(t, "first", configMaps.record(t).HolderIdentity)
+
+	acquired, err = second.TryAcquire(ctx, "second", time.Minute)
+	require.NoError(t, err)
+	require.False(t, acquired)
+
+	// the first instance keeps renewing the lease
+	now = now.Add(45 * time.Second)
+	acquired, err = first.TryAcquire(ctx, "first", time.Minute)
+	require.NoError(t, err)
+	require.True(t, acquired)
+
+	now = now.Add(45 * time.Second)
+	acquired, err = second.TryAcquire(ctx, "second", time.Minute)
+	require.NoError(t, err)
+	require.False(t, acquired)
+
+	// the lease expires once the first instance stops renewing it
+	now = now.Add(61 * time.Second)
+	acquired, err = second.TryAcquire(ctx, "second", time.Minute)
+	require.NoError(t, err)
+	require.True(t, acquired)
+	require.Equal(t, "second", configMaps.record(t).HolderIdentity)
+	require.Equal(t, 1, configMaps.record(t).LeaderTransitions)
+
+	acquired, err = first.TryAcquire(ctx, "first", time.Minute)
+	require.NoError(t, err)
+	require.False(t, acquired)
+
+	// releasing the lock allows the other instance to take over right away
+	require.NoError(t, first.Release(ctx, "first"))
+	require.NoError(t, second.Release(ctx, "second"))
+
+	acquired, err = first.TryAcquire(ctx, "first", time.Minute)
+	require.NoError(t, err)
+	require.True(t, acquired)
+}
+
+func TestConfigMapLockTimeout(t *testing.T) {
+	configMaps := &configMapServer{block: make(chan struct{})}
+	server := httptest.NewServer(configMaps)
+	defer server.Close()
+	defer close(configMaps.block)
+
+	lock := newTestConfigMapLock(t, server)
+
+	// attempts are aborted once the context expires
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	_, err := lock.TryAcquire(ctx, "first", time.Minute)
+	require.Error(t, err)
+}
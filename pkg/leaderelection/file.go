@@ -0,0 +1,75 @@
+package leaderelection
+
+import (
+	"context"
+	"os"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// FileLock is a lock based on an advisory lock of a file. The lock is held
+// until it's released or the process terminates, the lease duration is
+// ignored. It's only suitable for running several instances on the same host,
+// e.g. for local testing.
+type FileLock struct {
+	sync.Mutex
+	path string
+	file *os.File
+}
+
+// NewFileLock initializes a new file lock.
+func NewFileLock(path string) *FileLock {
+	return &FileLock{path: path}
+}
+
+// TryAcquire acquires the lock for identity and writes the identity to the
+// lock file.
+func (l *FileLock) TryAcquire(_ context.Context, identity string, _ time.Duration) (bool, error) {
+	l.Lock()
+	defer l.Unlock()
+
+	if l.file != nil {
+		return true, nil
+	}
+
+	file, err := os.OpenFile(l.path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return false, err
+	}
+
+	err = syscall.Flock(int(file.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
+	if err != nil {
+		file.Close()
+		if err == syscall.EWOULDBLOCK {
+			return false, nil
+		}
+		return false, err
+	}
+
+	err = file.Truncate(0)
+	if err == nil {
+		_, err = file.WriteAt([]byte(identity+"\n"), 0)
+	}
+	if err != nil {
+		file.Close()
+		return false, err
+	}
+
+	l.file = file
+	return true, nil
+}
+
+// Release releases the lock.
+func (l *FileLock) Release(_ context.Context, _ string) error {
+	l.Lock()
+	defer l.Unlock()
+
+	if l.file == nil {
+		return nil
+	}
+
+	err := l.file.Close()
+	l.file = nil
+	return err
+}
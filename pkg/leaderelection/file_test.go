@@ -0,0 +1,46 @@
+package leaderelection
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileLock(t *testing.T) {
+	dir, err := ioutil.TempDir("", "clm-leader")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	lock, err := NewLock("file://" + path.Join(dir, "leader"))
+	require.NoError(t, err)
+	other := NewFileLock(path.Join(dir, "leader"))
+
+	acquired, err := lock.TryAcquire(context.Background(), "first", time.Minute)
+	require.NoError(t, err)
+	require.True(t, acquired)
+
+	content, err := ioutil.ReadFile(path.Join(dir, "leader"))
+	require.NoError(t, err)
+	require.Equal(t, "first\n", string(content))
+
+	// renewing the lock
+	acquired, err = lock.TryAcquire(context.Background(), "first", time.Minute)
+	require.NoError(t, err)
+	require.True(t, acquired)
+
+	acquired, err = other.TryAcquire(context.Background(), "second", time.Minute)
+	require.NoError(t, err)
+	require.False(t, acquired)
+
+	require.NoError(t, lock.Release(context.Background(), "first"))
+
+	acquired, err = other.TryAcquire(context.Background(), "second", time.Minute)
+	require.NoError(t, err)
+	require.True(t, acquired)
+	require.NoError(t, other.Release(context.Background(), "second"))
+}
@@ -0,0 +1,175 @@
+package leaderelection
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+)
+
+var leader = prometheus.NewGauge(
+	prometheus.GaugeOpts{
+		Namespace: "clm",
+		Name:      "leader",
+		Help:      "Whether this instance is currently the leader (1) or not (0).",
+	},
+)
+
+func init() {
+	prometheus.MustRegister(leader)
+}
+
+// Lock is a lock with a limited lease which can be held by a single
+// instance at a time.
+type Lock interface {
+	// TryAcquire acquires the lock for identity, or renews the lease if
+	// identity already holds it. It returns false if the lock is held by
+	// someone else. The attempt is aborted once ctx is done.
+	TryAcquire(ctx context.Context, identity string, leaseDuration time.Duration) (bool, error)
+	// Release releases the lock if it's held by identity.
+	Release(ctx context.Context, identity string) error
+}
+
+// NewLock initializes a new lock based on the uri. Supported are
+// kubernetes://<namespace>/<name>, a ConfigMap in the cluster CLM is running
+// in, and file:///<path>, a lock file for running several instances on the
+// same host.
+func NewLock(uri string) (Lock, error) {
+	url, err := url.Parse(uri)
+	if err != nil {
+		return nil, err
+	}
+
+	switch url.Scheme {
+	case "file":
+		return NewFileLock(url.Host + url.Path), nil
+	case "kubernetes":
+		name := strings.Trim(url.Path, "/")
+		if url.Host == "" || name == "" || strings.Contains(name, "/") {
+			return nil, fmt.Errorf("invalid lock location, expected kubernetes://<namespace>/<name>: %s", uri)
+		}
+		return NewInClusterConfigMapLock(url.Host, name)
+	default:
+		return nil, fmt.Errorf("unknown lock type: %v", url.Scheme)
+	}
+}
+
+// Elector campaigns for a lock and runs a function only while holding it.
+type Elector struct {
+	logger        *log.Entry
+	lock          Lock
+	identity      string
+	leaseDuration time.Duration
+	renewDeadline time.Duration
+	retryPeriod   time.Duration
+}
+
+// NewElector initializes a new elector. The lease is renewed every third of
+// the lease duration. If it couldn't be renewed for two thirds of the lease
+// duration, the elector gives up the leadership before anyone else can take
+// it over. Attempts to renew the lease are aborted at that point as well.
+func NewElector(logger *log.Entry, lock Lock, identity string, leaseDuration time.Duration) *Elector {
+	return &Elector{
+		logger:        logger.WithField("identity", identity),
+		lock:          lock,
+		identity:      identity,
+		leaseDuration: leaseDuration,
+		renewDeadline: leaseDuration * 2 / 3,
+		retryPeriod:   leaseDuration / 3,
+	}
+}
+
+// Run blocks until ctx is canceled. Whenever the elector becomes the leader,
+// run is called with a context which is canceled as soon as the leadership is
+// lost. The elector waits for run to return before it campaigns again. It
+// releases the lock and returns when ctx is canceled or run returns on its
+// own.
+func (e *Elector) Run(ctx context.Context, run func(ctx context.Context)) {
+	for e.acquire(ctx) {
+		e.logger.Info("Acquired leadership.")
+		leader.Set(1)
+
+		leaderCtx, cancel := context.WithCancel(ctx)
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			run(leaderCtx)
+		}()
+
+		lost := e.renew(leaderCtx, done)
+		cancel()
+		<-done
+		leader.Set(0)
+
+		if !lost {
+			break
+		}
+		e.logger.Warn("Lost leadership.")
+	}
+
+	releaseCtx, cancel := context.WithTimeout(context.Background(), e.retryPeriod)
+	defer cancel()
+
+	err := e.lock.Release(releaseCtx, e.identity)
+	if err != nil {
+		e.logger.Errorf("Failed to release leader election lock: %s", err)
+	}
+}
+
+// acquire retries acquiring the lock until it succeeds. It returns false if
+// ctx was canceled before.
+func (e *Elector) acquire(ctx context.Context) bool {
+	for {
+		attemptCtx, cancel := context.WithTimeout(ctx, e.retryPeriod)
+		acquired, err := e.lock.TryAcquire(attemptCtx, e.identity, e.leaseDuration)
+		cancel()
+		if err != nil {
+			e.logger.Errorf("Failed to acquire leader election lock: %s", err)
+		} else if acquired {
+			return true
+		}
+
+		select {
+		case <-time.After(e.retryPeriod):
+		case <-ctx.Done():
+			return false
+		}
+	}
+}
+
+// renew renews the lease until it's lost, ctx is canceled or done is closed.
+// It returns true if the lease was lost.
+func (e *Elector) renew(ctx context.Context, done <-chan struct{}) bool {
+	lastRenewal := time.Now()
+
+	for {
+		select {
+		case <-time.After(e.retryPeriod):
+		case <-ctx.Done():
+			return false
+		case <-done:
+			return false
+		}
+
+		// a renewal that can't finish before the renew deadline is
+		// useless, the leadership is given up at that point anyway
+		attemptCtx, cancel := context.WithDeadline(ctx, lastRenewal.Add(e.renewDeadline))
+		acquired, err := e.lock.TryAcquire(attemptCtx, e.identity, e.leaseDuration)
+		cancel()
+		switch {
+		case err != nil:
+			e.logger.Errorf("Failed to renew leader election lock: %s", err)
+			if time.Since(lastRenewal) >= e.renewDeadline {
+				return true
+			}
+		case !acquired:
+			return true
+		default:
+			lastRenewal = time.Now()
+		}
+	}
+}
@@ -0,0 +1,147 @@
+package leaderelection
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+)
+
+type mockLock struct {
+	sync.Mutex
+	available bool
+	holder    string
+}
+
+func (l *mockLock) TryAcquire(_ context.Context, identity string, _ time.Duration) (bool, error) {
+	l.Lock()
+	defer l.Unlock()
+	if !l.available {
+		return false, nil
+	}
+	l.holder = identity
+	return true, nil
+}
+
+func (l *mockLock) Release(_ context.Context, identity string) error {
+	l.Lock()
+	defer l.Unlock()
+	if l.holder == identity {
+		l.holder = ""
+	}
+	return nil
+}
+
+func (l *mockLock) setAvailable(available bool) {
+	l.Lock()
+	defer l.Unlock()
+	l.available = available
+}
+
+func (l *mockLock) currentHolder() string {
+	l.Lock()
+	defer l.Unlock()
+	return l.holder
+}
+
+func TestNewLock(t *testing.T) {
+	for _, uri := range []string{
+		"kubernetes://kube-system",
+		"kubernetes:///cluster-lifecycle-manager",
+		"kubernetes://kube-system/cluster-lifecycle-manager/leader",
+		"etcd://localhost/leader",
+	} {
+		_, err := NewLock(uri)
+		require.Error(t, err, uri)
+	}
+}
+
+func TestElector(t *testing.T) {
+	lock := &mockLock{available: true}
+	elector := NewElector(log.WithField("test", "elector"), lock, "first", 30*time.Millisecond)
+
+	started := make(chan struct{})
+	stopped := make(chan struct{})
+	run := func(ctx context.Context) {
+		started <- struct{}{}
+		<-ctx.Done()
+		stopped <- struct{}{}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		elector.Run(ctx, run)
+	}()
+
+	<-started
+	require.Equal(t, "first", lock.currentHolder())
+
+	// losing the leadership cancels the context
+	lock.setAvailable(false)
+	<-stopped
+
+	// the leadership is acquired again
+	lock.setAvailable(true)
+	<-started
+
+	// the lock is released on shutdown
+	cancel()
+	<-stopped
+	<-done
+	require.Equal(t, "", lock.currentHolder())
+}
+
+func TestElectorRunReturns(t *testing.T) {
+	lock := &mockLock{available: true}
+	elector := NewElector(log.WithField("test", "elector"), lock, "first", 30*time.Millisecond)
+
+	// the elector stops campaigning once run returns on its own
+	elector.Run(context.Background(), func(ctx context.Context) {})
+	require.Equal(t, "", lock.currentHolder())
+}
+
+// hangingLock hangs when renewing the lease until the attempt is aborted.
+type hangingLock struct {
+	mockLock
+	acquired bool
+}
+
+func (l *hangingLock) TryAcquire(ctx context.Context, identity string, leaseDuration time.Duration) (bool, error) {
+	l.Lock()
+	acquired := l.acquired
+	l.acquired = true
+	l.Unlock()
+
+	if acquired {
+		<-ctx.Done()
+		return false, ctx.Err()
+	}
+	return l.mockLock.TryAcquire(ctx, identity, leaseDuration)
+}
+
+func TestElectorRenewTimeout(t *testing.T) {
+	lock := &hangingLock{mockLock: mockLock{available: true}}
+	elector := NewElector(log.WithField("test", "elector"), lock, "first", 30*time.Millisecond)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// the leadership is given up even though the renewal never returns on
+	// its own
+	lost := make(chan struct{})
+	go elector.Run(ctx, func(ctx context.Context) {
+		<-ctx.Done()
+		close(lost)
+	})
+
+	select {
+	case <-lost:
+	case <-time.After(5 * time.Second):
+		t.Fatal("leadership wasn't given up")
+	}
+}
@@ -0,0 +1,161 @@
+package sharding
+
+import (
+	"sync"
+	"time"
+
+	"k8s.io/api/core/v1"
+	apiErrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+// heartbeatAttempts is the number of attempts to store a heartbeat if other
+// members update the ConfigMap concurrently.
+const heartbeatAttempts = 3
+
+type observation struct {
+	value string
+	time  time.Time
+}
+
+// ConfigMapMembership keeps track of the members in a ConfigMap, with a key
+// per member whose value changes on every heartbeat.
+type ConfigMapMembership struct {
+	sync.Mutex
+	client    kubernetes.Interface
+	namespace string
+	name      string
+	now       func() time.Time
+
+	// the last observed heartbeat of each member and when it was observed.
+	// Members are considered gone based on the local clock only, so the
+	// clocks of the instances don't need to be in sync.
+	observed map[string]observation
+}
+
+// NewConfigMapMembership initializes a new ConfigMap based membership.
+func NewConfigMapMembership(client kubernetes.Interface, namespace, name string) *ConfigMapMembership {
+	return &ConfigMapMembership{
+		client:    client,
+		namespace: namespace,
+		name:      name,
+		now:       time.Now,
+		observed:  make(map[string]observation),
+	}
+}
+
+// NewInClusterConfigMapMembership initializes a new ConfigMap based
+// membership in the cluster CLM is running in.
+func NewInClusterConfigMapMembership(namespace, name string) (*ConfigMapMembership, error) {
+	cfg, err := rest.InClusterConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := kubernetes.NewForConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewConfigMapMembership(client, namespace, name), nil
+}
+
+// Heartbeat stores the heartbeat of identity and returns the members whose
+// heartbeat changed within the ttl. Members gone for longer are removed from
+// the ConfigMap.
+func (m *ConfigMapMembership) Heartbeat(identity string, ttl time.Duration) ([]string, error) {
+	m.Lock()
+	defer m.Unlock()
+
+	var err error
+	for i := 0; i < heartbeatAttempts; i++ {
+		var members []string
+		members, err = m.heartbeat(identity, ttl)
+		if !apiErrors.IsConflict(err) && !apiErrors.IsAlreadyExists(err) {
+			return members, err
+		}
+	}
+	return nil, err
+}
+
+func (m *ConfigMapMembership) heartbeat(identity string, ttl time.Duration) ([]string, error) {
+	now := m.now()
+	configMaps := m.client.CoreV1().ConfigMaps(m.namespace)
+
+	exists := true
+	configMap, err := configMaps.Get(m.name, metav1.GetOptions{})
+	if err != nil {
+		if !apiErrors.IsNotFound(err) {
+			return nil, err
+		}
+		exists = false
+		configMap = &v1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      m.name,
+				Namespace: m.namespace,
+			},
+		}
+	}
+
+	data := make(map[string]string, len(configMap.Data)+1)
+	var members []string
+	for member, value := range configMap.Data {
+		observed, ok := m.observed[member]
+		if !ok || observed.value != value {
+			observed = observation{value: value, time: now}
+			m.observed[member] = observed
+		}
+
+		if member == identity || now.Sub(observed.time) >= ttl {
+			continue
+		}
+		data[member] = value
+		members = append(members, member)
+	}
+
+	data[identity] = now.UTC().Format(time.RFC3339Nano)
+	members = append(members, identity)
+	configMap.Data = data
+
+	if exists {
+		_, err = configMaps.Update(configMap)
+	} else {
+		_, err = configMaps.Create(configMap)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for member := range m.observed {
+		if _, ok := data[member]; !ok {
+			delete(m.observed, member)
+		}
+	}
+	return members, nil
+}
+
+// Leave removes the key of identity from the ConfigMap.
+func (m *ConfigMapMembership) Leave(identity string) error {
+	m.Lock()
+	defer m.Unlock()
+
+	configMaps := m.client.CoreV1().ConfigMaps(m.namespace)
+
+	configMap, err := configMaps.Get(m.name, metav1.GetOptions{})
+	if err != nil {
+		if apiErrors.IsNotFound(err) {
+			return nil
+		}
+		return err
+	}
+
+	if _, ok := configMap.Data[identity]; !ok {
+		return nil
+	}
+	delete(configMap.Data, identity)
+
+	_, err = configMaps.Update(configMap)
+	return err
+}
@@ -0,0 +1,49 @@
+package sharding
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestConfigMapMembership(t *testing.T) {
+	client := fake.NewSimpleClientset()
+
+	now := time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := func() time.Time { return now }
+
+	first := NewConfigMapMembership(client, "kube-system", "cluster-lifecycle-manager-shards")
+	first.now = clock
+	second := NewConfigMapMembership(client, "kube-system", "cluster-lifecycle-manager-shards")
+	second.now = clock
+
+	members, err := first.Heartbeat("clm-0", time.Minute)
+	require.NoError(t, err)
+	require.ElementsMatch(t, []string{"clm-0"}, members)
+
+	now = now.Add(time.Second)
+	members, err = second.Heartbeat("clm-1", time.Minute)
+	require.NoError(t, err)
+	require.ElementsMatch(t, []string{"clm-0", "clm-1"}, members)
+
+	// the first member stops sending heartbeats and is removed
+	for i := 0; i < 4; i++ {
+		now = now.Add(20 * time.Second)
+		members, err = second.Heartbeat("clm-1", time.Minute)
+		require.NoError(t, err)
+	}
+	require.ElementsMatch(t, []string{"clm-1"}, members)
+
+	configMap, err := client.CoreV1().ConfigMaps("kube-system").Get("cluster-lifecycle-manager-shards", metav1.GetOptions{})
+	require.NoError(t, err)
+	require.Len(t, configMap.Data, 1)
+
+	require.NoError(t, second.Leave("clm-1"))
+
+	members, err = first.Heartbeat("clm-0", time.Minute)
+	require.NoError(t, err)
+	require.ElementsMatch(t, []string{"clm-0"}, members)
+}
@@ -0,0 +1,57 @@
+package sharding
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"time"
+)
+
+// FileMembership keeps track of the members in a directory, with a file per
+// member which is touched on every heartbeat. It's only suitable for running
+// several instances on the same host, e.g. for local testing.
+type FileMembership struct {
+	dir string
+}
+
+// NewFileMembership initializes a new file based membership.
+func NewFileMembership(dir string) *FileMembership {
+	return &FileMembership{dir: dir}
+}
+
+// Heartbeat touches the file of identity and returns the members whose file
+// was touched within the ttl.
+func (m *FileMembership) Heartbeat(identity string, ttl time.Duration) ([]string, error) {
+	err := os.MkdirAll(m.dir, 0755)
+	if err != nil {
+		return nil, err
+	}
+
+	err = ioutil.WriteFile(path.Join(m.dir, identity), []byte(time.Now().UTC().Format(time.RFC3339)+"\n"), 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	files, err := ioutil.ReadDir(m.dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var members []string
+	for _, file := range files {
+		if file.IsDir() || time.Since(file.ModTime()) >= ttl {
+			continue
+		}
+		members = append(members, file.Name())
+	}
+	return members, nil
+}
+
+// Leave removes the file of identity.
+func (m *FileMembership) Leave(identity string) error {
+	err := os.Remove(path.Join(m.dir, identity))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
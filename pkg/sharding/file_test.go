@@ -0,0 +1,43 @@
+package sharding
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileMembership(t *testing.T) {
+	dir, err := ioutil.TempDir("", "clm-shards")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	membership, err := NewMembership("file://" + path.Join(dir, "members"))
+	require.NoError(t, err)
+
+	members, err := membership.Heartbeat("clm-0", time.Minute)
+	require.NoError(t, err)
+	require.Equal(t, []string{"clm-0"}, members)
+
+	members, err = membership.Heartbeat("clm-1", time.Minute)
+	require.NoError(t, err)
+	require.Equal(t, []string{"clm-0", "clm-1"}, members)
+
+	// members without a recent heartbeat are gone
+	old := time.Now().Add(-2 * time.Minute)
+	require.NoError(t, os.Chtimes(path.Join(dir, "members", "clm-0"), old, old))
+
+	members, err = membership.Heartbeat("clm-1", time.Minute)
+	require.NoError(t, err)
+	require.Equal(t, []string{"clm-1"}, members)
+
+	require.NoError(t, membership.Leave("clm-1"))
+	require.NoError(t, membership.Leave("clm-1"))
+
+	members, err = membership.Heartbeat("clm-2", time.Minute)
+	require.NoError(t, err)
+	require.Equal(t, []string{"clm-2"}, members)
+}
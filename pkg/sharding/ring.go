@@ -0,0 +1,62 @@
+package sharding
+
+import (
+	"hash/crc32"
+	"sort"
+	"strconv"
+)
+
+// virtualNodes is the number of points each member is assigned on the ring.
+// More points spread the keys more evenly across the members.
+const virtualNodes = 128
+
+// Ring assigns keys to members by consistent hashing. When a member joins or
+// leaves, only the keys of that member move.
+type Ring struct {
+	hashes  []uint32
+	members map[uint32]string
+}
+
+// NewRing initializes a new ring with the provided members.
+func NewRing(members []string) *Ring {
+	ring := &Ring{
+		members: make(map[uint32]string, len(members)*virtualNodes),
+	}
+
+	for _, member := range members {
+		for i := 0; i < virtualNodes; i++ {
+			hash := crc32.ChecksumIEEE([]byte(member + "#" + strconv.Itoa(i)))
+			// on the unlikely collision, the smaller member wins so that
+			// every instance builds the same ring
+			if existing, ok := ring.members[hash]; ok && existing < member {
+				continue
+			}
+			if _, ok := ring.members[hash]; !ok {
+				ring.hashes = append(ring.hashes, hash)
+			}
+			ring.members[hash] = member
+		}
+	}
+
+	sort.Slice(ring.hashes, func(i, j int) bool {
+		return ring.hashes[i] < ring.hashes[j]
+	})
+	return ring
+}
+
+// Owner returns the member owning the key, or an empty string if the ring
+// has no members.
+func (r *Ring) Owner(key string) string {
+	if len(r.hashes) == 0 {
+		return ""
+	}
+
+	hash := crc32.ChecksumIEEE([]byte(key))
+	i := sort.Search(len(r.hashes), func(i int) bool {
+		return r.hashes[i] >= hash
+	})
+	if i == len(r.hashes) {
+		i = 0
+	}
+	return r.members[r.hashes[i]]
+}
@@ -0,0 +1,54 @@
+package sharding
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func clusterIDs(count int) []string {
+	var result []string
+	for i := 0; i < count; i++ {
+		result = append(result, fmt.Sprintf("aws:123456789012:eu-central-1:kube-%d", i))
+	}
+	return result
+}
+
+func TestRingEmpty(t *testing.T) {
+	require.Equal(t, "", NewRing(nil).Owner("aws:123456789012:eu-central-1:kube-1"))
+}
+
+func TestRingDistribution(t *testing.T) {
+	ring := NewRing([]string{"clm-0", "clm-1", "clm-2"})
+
+	owned := make(map[string]int)
+	for _, id := range clusterIDs(3000) {
+		owned[ring.Owner(id)]++
+	}
+
+	require.Len(t, owned, 3)
+	for member, count := range owned {
+		require.InDelta(t, 1000, count, 250, member)
+	}
+}
+
+func TestRingRebalance(t *testing.T) {
+	before := NewRing([]string{"clm-0", "clm-1", "clm-2"})
+	after := NewRing([]string{"clm-2", "clm-1", "clm-0", "clm-3"})
+
+	for _, id := range clusterIDs(1000) {
+		// only clusters moving to the new member change their owner
+		if owner := after.Owner(id); owner != "clm-3" {
+			require.Equal(t, before.Owner(id), owner, id)
+		}
+	}
+
+	removed := NewRing([]string{"clm-0", "clm-2"})
+	for _, id := range clusterIDs(1000) {
+		// only clusters of the removed member change their owner
+		if owner := before.Owner(id); owner != "clm-1" {
+			require.Equal(t, owner, removed.Owner(id), id)
+		}
+	}
+}
@@ -0,0 +1,212 @@
+package sharding
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+)
+
+var shardMembers = prometheus.NewGauge(
+	prometheus.GaugeOpts{
+		Namespace: "clm",
+		Name:      "shard_members",
+		Help:      "Number of live controller instances the clusters are sharded across.",
+	},
+)
+
+func init() {
+	prometheus.MustRegister(shardMembers)
+}
+
+// Membership keeps track of the live instances of the controller.
+type Membership interface {
+	// Heartbeat announces identity as a live member for the next ttl and
+	// returns the identities of all live members.
+	Heartbeat(identity string, ttl time.Duration) ([]string, error)
+	// Leave removes identity from the members.
+	Leave(identity string) error
+}
+
+// NewMembership initializes a new membership based on the uri. Supported are
+// kubernetes://<namespace>/<name>, a ConfigMap in the cluster CLM is running
+// in, and file:///<path>, a directory for running several instances on the
+// same host.
+func NewMembership(uri string) (Membership, error) {
+	url, err := url.Parse(uri)
+	if err != nil {
+		return nil, err
+	}
+
+	switch url.Scheme {
+	case "file":
+		return NewFileMembership(url.Host + url.Path), nil
+	case "kubernetes":
+		name := strings.Trim(url.Path, "/")
+		if url.Host == "" || name == "" || strings.Contains(name, "/") {
+			return nil, fmt.Errorf("invalid membership location, expected kubernetes://<namespace>/<name>: %s", uri)
+		}
+		return NewInClusterConfigMapMembership(url.Host, name)
+	default:
+		return nil, fmt.Errorf("unknown membership type: %v", url.Scheme)
+	}
+}
+
+// Sharder assigns the clusters to the live instances of the controller by
+// consistent hashing of the cluster IDs.
+type Sharder struct {
+	sync.Mutex
+	logger        *log.Entry
+	membership    Membership
+	identity      string
+	ttl           time.Duration
+	renewDeadline time.Duration
+	changes       chan struct{}
+	now           func() time.Time
+
+	members       []string
+	ring          *Ring
+	previous      *Ring
+	changed       time.Time
+	settled       bool
+	lastHeartbeat time.Time
+}
+
+// NewSharder initializes a new sharder. Every instance sends a heartbeat
+// every third of the ttl and is considered gone if it didn't send one for the
+// ttl. If an instance couldn't send a heartbeat for two thirds of the ttl, it
+// gives up its clusters before anyone else can take them over.
+func NewSharder(logger *log.Entry, membership Membership, identity string, ttl time.Duration) *Sharder {
+	return &Sharder{
+		logger:        logger.WithField("identity", identity),
+		membership:    membership,
+		identity:      identity,
+		ttl:           ttl,
+		renewDeadline: ttl * 2 / 3,
+		changes:       make(chan struct{}, 1),
+		now:           time.Now,
+		ring:          NewRing(nil),
+		previous:      NewRing(nil),
+	}
+}
+
+// Run sends heartbeats and updates the assignment of the clusters until ctx
+// is canceled.
+func (s *Sharder) Run(ctx context.Context) {
+	for {
+		members, err := s.membership.Heartbeat(s.identity, s.ttl)
+		if err != nil {
+			s.logger.Errorf("Failed to send shard heartbeat: %s", err)
+			s.expire()
+		} else {
+			s.update(members)
+		}
+		s.settle()
+
+		select {
+		case <-time.After(s.ttl / 3):
+		case <-ctx.Done():
+			err := s.membership.Leave(s.identity)
+			if err != nil {
+				s.logger.Errorf("Failed to leave the shard members: %s", err)
+			}
+			return
+		}
+	}
+}
+
+// update rebuilds the ring if the members changed.
+func (s *Sharder) update(members []string) {
+	sort.Strings(members)
+
+	s.Lock()
+	defer s.Unlock()
+
+	s.lastHeartbeat = s.now()
+
+	if strings.Join(members, ",") == strings.Join(s.members, ",") {
+		return
+	}
+
+	s.logger.Infof("Shard members changed: %s", strings.Join(members, ", "))
+	shardMembers.Set(float64(len(members)))
+
+	s.members = members
+	s.previous = s.ring
+	s.ring = NewRing(members)
+	s.changed = s.now()
+	s.settled = false
+	s.notify()
+}
+
+// expire gives up all clusters once the last successful heartbeat is older
+// than the renew deadline. When the heartbeats succeed again, the clusters are
+// only owned after the ttl, like after any other change of the members.
+func (s *Sharder) expire() {
+	s.Lock()
+	defer s.Unlock()
+
+	if s.members == nil || s.now().Sub(s.lastHeartbeat) < s.renewDeadline {
+		return
+	}
+
+	s.logger.Warnf("Giving up all clusters, no successful shard heartbeat since %s", s.lastHeartbeat)
+	shardMembers.Set(0)
+
+	s.members = nil
+	s.ring = NewRing(nil)
+	s.previous = s.ring
+	s.changed = s.now()
+	s.settled = true
+	s.notify()
+}
+
+// settle notifies about the clusters moved to this instance once they're
+// owned.
+func (s *Sharder) settle() {
+	s.Lock()
+	defer s.Unlock()
+
+	if s.settled || s.now().Sub(s.changed) < s.ttl {
+		return
+	}
+	s.settled = true
+	s.notify()
+}
+
+func (s *Sharder) notify() {
+	select {
+	case s.changes <- struct{}{}:
+	default:
+	}
+}
+
+// Owns returns true if the cluster is assigned to this instance. A cluster
+// moved to this instance from another one is only owned once the previous
+// owner had the time to notice the change and stop updating it. No clusters
+// are owned if the last successful heartbeat is older than the renew
+// deadline, as the other instances might take them over soon.
+func (s *Sharder) Owns(clusterID string) bool {
+	s.Lock()
+	defer s.Unlock()
+
+	if s.now().Sub(s.lastHeartbeat) >= s.renewDeadline {
+		return false
+	}
+	if s.ring.Owner(clusterID) != s.identity {
+		return false
+	}
+	return s.previous.Owner(clusterID) == s.identity || s.now().Sub(s.changed) >= s.ttl
+}
+
+// Changes returns a channel which receives a value whenever the assignment
+// of the clusters changed.
+func (s *Sharder) Changes() <-chan struct{} {
+	return s.changes
+}
@@ -0,0 +1,151 @@
+package sharding
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+)
+
+type staticMembership struct {
+	members []string
+}
+
+func (m *staticMembership) Heartbeat(_ string, _ time.Duration) ([]string, error) {
+	return m.members, nil
+}
+
+func (m *staticMembership) Leave(_ string) error {
+	return nil
+}
+
+type flakyMembership struct {
+	sync.Mutex
+	members []string
+	err     error
+}
+
+func (m *flakyMembership) Heartbeat(_ string, _ time.Duration) ([]string, error) {
+	m.Lock()
+	defer m.Unlock()
+	return m.members, m.err
+}
+
+func (m *flakyMembership) Leave(_ string) error {
+	return nil
+}
+
+func (m *flakyMembership) setError(err error) {
+	m.Lock()
+	defer m.Unlock()
+	m.err = err
+}
+
+func TestNewMembership(t *testing.T) {
+	for _, uri := range []string{
+		"kubernetes://kube-system",
+		"kubernetes:///cluster-lifecycle-manager",
+		"etcd://localhost/members",
+	} {
+		_, err := NewMembership(uri)
+		require.Error(t, err, uri)
+	}
+}
+
+func TestSharderOwns(t *testing.T) {
+	now := time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	sharder := NewSharder(log.WithField("test", "sharder"), &staticMembership{}, "clm-0", time.Minute)
+	sharder.now = func() time.Time { return now }
+
+	ids := clusterIDs(100)
+
+	owned := func() []string {
+		var result []string
+		for _, id := range ids {
+			if sharder.Owns(id) {
+				result = append(result, id)
+			}
+		}
+		return result
+	}
+
+	// nothing is owned before the first heartbeat
+	require.Empty(t, owned())
+
+	// clusters are only owned once the previous owner had time to stop
+	sharder.update([]string{"clm-0"})
+	require.Empty(t, owned())
+	<-sharder.Changes()
+
+	now = now.Add(time.Minute)
+	sharder.update([]string{"clm-0"})
+	require.Equal(t, ids, owned())
+
+	sharder.settle()
+	<-sharder.Changes()
+
+	// clusters moved to a new member are given up right away
+	sharder.update([]string{"clm-0", "clm-1"})
+	remaining := owned()
+	require.NotEmpty(t, remaining)
+	require.NotEqual(t, ids, remaining)
+
+	// the clusters of a member which left are taken over after the ttl
+	sharder.update([]string{"clm-0"})
+	require.Equal(t, remaining, owned())
+
+	now = now.Add(time.Minute)
+	sharder.update([]string{"clm-0"})
+	require.Equal(t, ids, owned())
+
+	// nothing is owned once the last heartbeat is older than the renew
+	// deadline
+	now = now.Add(40 * time.Second)
+	require.Empty(t, owned())
+}
+
+func TestSharderRun(t *testing.T) {
+	sharder := NewSharder(log.WithField("test", "sharder"), &staticMembership{members: []string{"clm-0"}}, "clm-0", 30*time.Millisecond)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go sharder.Run(ctx)
+
+	// notified when the members change and once the clusters are owned
+	<-sharder.Changes()
+	<-sharder.Changes()
+	require.True(t, sharder.Owns("aws:123456789012:eu-central-1:kube-1"))
+}
+
+func TestSharderHeartbeatFailure(t *testing.T) {
+	membership := &flakyMembership{members: []string{"clm-0"}}
+	sharder := NewSharder(log.WithField("test", "sharder"), membership, "clm-0", 300*time.Millisecond)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go sharder.Run(ctx)
+
+	clusterID := "aws:123456789012:eu-central-1:kube-1"
+
+	<-sharder.Changes()
+	<-sharder.Changes()
+	require.True(t, sharder.Owns(clusterID))
+
+	// the clusters are given up before the other members consider this one
+	// gone
+	membership.setError(errors.New("unavailable"))
+	<-sharder.Changes()
+	require.False(t, sharder.Owns(clusterID))
+
+	// and only owned again after the ttl once the heartbeats succeed
+	membership.setError(nil)
+	<-sharder.Changes()
+	require.False(t, sharder.Owns(clusterID))
+	<-sharder.Changes()
+	require.True(t, sharder.Owns(clusterID))
+}
@@ -0,0 +1,70 @@
+package tracing
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+)
+
+// jsonSpan is the representation of a span written by the JSON exporter.
+type jsonSpan struct {
+	TraceID    string            `json:"trace_id"`
+	SpanID     string            `json:"span_id"`
+	ParentID   string            `json:"parent_span_id,omitempty"`
+	Name       string            `json:"name"`
+	Start      time.Time         `json:"start"`
+	End        time.Time         `json:"end"`
+	Duration   string            `json:"duration"`
+	Attributes map[string]string `json:"attributes,omitempty"`
+	Error      string            `json:"error,omitempty"`
+}
+
+// jsonExporter writes every span as a JSON encoded line as soon as it ends,
+// so that no spans are lost if the process exits.
+type jsonExporter struct {
+	sync.Mutex
+	writer io.Writer
+}
+
+// NewJSONExporter returns an exporter writing one JSON encoded span per line
+// to writer. It's meant for local use, e.g. when provisioning a single
+// cluster from the command line.
+func NewJSONExporter(writer io.Writer) Exporter {
+	return &jsonExporter{writer: writer}
+}
+
+func (e *jsonExporter) Export(spans []*SpanData) error {
+	e.Lock()
+	defer e.Unlock()
+
+	encoder := json.NewEncoder(e.writer)
+	for _, span := range spans {
+		record := &jsonSpan{
+			TraceID:  span.TraceID,
+			SpanID:   span.SpanID,
+			ParentID: span.ParentID,
+			Name:     span.Name,
+			Start:    span.Start.UTC(),
+			End:      span.End.UTC(),
+			Duration: span.End.Sub(span.Start).String(),
+			Error:    span.Error,
+		}
+		if len(span.Attributes) > 0 {
+			record.Attributes = make(map[string]string, len(span.Attributes))
+			for _, attribute := range span.Attributes {
+				record.Attributes[attribute.Key] = attribute.Value
+			}
+		}
+
+		err := encoder.Encode(record)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (e *jsonExporter) Shutdown() error {
+	return nil
+}
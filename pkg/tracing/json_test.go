@@ -0,0 +1,55 @@
+package tracing
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestJSONExporter(t *testing.T) {
+	var buf bytes.Buffer
+	exporter := NewJSONExporter(&buf)
+
+	start := time.Date(2018, 10, 1, 12, 0, 0, 0, time.UTC)
+	err := exporter.Export([]*SpanData{
+		{
+			TraceID:    "0102",
+			SpanID:     "03",
+			Name:       "provision",
+			Start:      start,
+			End:        start.Add(90 * time.Second),
+			Attributes: []Attribute{{Key: "cluster", Value: "foo"}},
+		},
+		{
+			TraceID:  "0102",
+			SpanID:   "04",
+			ParentID: "03",
+			Name:     "drain",
+			Start:    start,
+			End:      start.Add(time.Second),
+			Error:    "failed",
+		},
+	})
+	require.NoError(t, err)
+	require.NoError(t, exporter.Shutdown())
+
+	var spans []jsonSpan
+	scanner := bufio.NewScanner(&buf)
+	for scanner.Scan() {
+		var span jsonSpan
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &span))
+		spans = append(spans, span)
+	}
+
+	require.Len(t, spans, 2)
+	require.Equal(t, "provision", spans[0].Name)
+	require.Equal(t, "1m30s", spans[0].Duration)
+	require.Equal(t, map[string]string{"cluster": "foo"}, spans[0].Attributes)
+	require.Empty(t, spans[0].ParentID)
+	require.Equal(t, "03", spans[1].ParentID)
+	require.Equal(t, "failed", spans[1].Error)
+}
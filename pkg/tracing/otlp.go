@@ -0,0 +1,243 @@
+package tracing
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	otlpTracesPath = "/v1/traces"
+	serviceName    = "cluster-lifecycle-manager"
+
+	// spans are exported in batches of up to maxBatchSize spans at least
+	// every batchInterval. Spans are dropped if more than maxQueueSize are
+	// waiting to be exported.
+	maxBatchSize  = 512
+	maxQueueSize  = 4096
+	batchInterval = 5 * time.Second
+
+	otlpTimeout = 10 * time.Second
+
+	statusCodeError = 2
+)
+
+// The OTLP/HTTP JSON encoding of the spans, see
+// https://github.com/open-telemetry/opentelemetry-proto.
+type otlpRequest struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+type otlpResourceSpans struct {
+	Resource   otlpResource     `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpResource struct {
+	Attributes []otlpAttribute `json:"attributes"`
+}
+
+type otlpScopeSpans struct {
+	Scope otlpScope  `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpScope struct {
+	Name string `json:"name"`
+}
+
+type otlpSpan struct {
+	TraceID           string          `json:"traceId"`
+	SpanID            string          `json:"spanId"`
+	ParentSpanID      string          `json:"parentSpanId,omitempty"`
+	Name              string          `json:"name"`
+	Kind              int             `json:"kind"`
+	StartTimeUnixNano string          `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string          `json:"endTimeUnixNano"`
+	Attributes        []otlpAttribute `json:"attributes,omitempty"`
+	Status            *otlpStatus     `json:"status,omitempty"`
+}
+
+type otlpAttribute struct {
+	Key   string    `json:"key"`
+	Value otlpValue `json:"value"`
+}
+
+type otlpValue struct {
+	StringValue string `json:"stringValue"`
+}
+
+type otlpStatus struct {
+	Code    int    `json:"code"`
+	Message string `json:"message,omitempty"`
+}
+
+// otlpExporter exports the spans in batches to an OTLP/HTTP endpoint, e.g.
+// an OpenTelemetry collector.
+type otlpExporter struct {
+	client   *http.Client
+	endpoint string
+	done     chan struct{}
+
+	// spans may still be exported after the shutdown, by spans started
+	// before it, so the queue is only closed while holding the mutex
+	mutex  sync.Mutex
+	queue  chan *SpanData
+	closed bool
+}
+
+// NewOTLPExporter returns an exporter sending the spans to the OTLP/HTTP
+// endpoint at url. The default traces path /v1/traces is appended if url
+// has no path.
+func NewOTLPExporter(url string) Exporter {
+	endpoint := strings.TrimSuffix(url, "/")
+	if !strings.Contains(strings.TrimPrefix(strings.TrimPrefix(endpoint, "http://"), "https://"), "/") {
+		endpoint += otlpTracesPath
+	}
+
+	e := &otlpExporter{
+		client:   &http.Client{Timeout: otlpTimeout},
+		endpoint: endpoint,
+		queue:    make(chan *SpanData, maxQueueSize),
+		done:     make(chan struct{}),
+	}
+	go e.run()
+	return e
+}
+
+// Export queues the spans to be exported with the next batch. Spans exported
+// after the shutdown are dropped.
+func (e *otlpExporter) Export(spans []*SpanData) error {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	if e.closed {
+		return fmt.Errorf("trace exporter is shut down, dropping %d spans", len(spans))
+	}
+
+	for _, span := range spans {
+		select {
+		case e.queue <- span:
+		default:
+			return fmt.Errorf("trace export queue is full, dropping span %s", span.Name)
+		}
+	}
+	return nil
+}
+
+// Shutdown exports the queued spans and stops the exporter.
+func (e *otlpExporter) Shutdown() error {
+	e.mutex.Lock()
+	if !e.closed {
+		e.closed = true
+		close(e.queue)
+	}
+	e.mutex.Unlock()
+
+	<-e.done
+	return nil
+}
+
+// run exports the queued spans until the queue is closed.
+func (e *otlpExporter) run() {
+	defer close(e.done)
+
+	ticker := time.NewTicker(batchInterval)
+	defer ticker.Stop()
+
+	batch := make([]*SpanData, 0, maxBatchSize)
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		err := e.send(batch)
+		if err != nil {
+			log.Warnf("Failed to export %d spans: %v", len(batch), err)
+		}
+		batch = make([]*SpanData, 0, maxBatchSize)
+	}
+
+	for {
+		select {
+		case span, ok := <-e.queue:
+			if !ok {
+				flush()
+				return
+			}
+			batch = append(batch, span)
+			if len(batch) >= maxBatchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		}
+	}
+}
+
+// send posts a batch of spans to the endpoint.
+func (e *otlpExporter) send(spans []*SpanData) error {
+	data, err := json.Marshal(otlpPayload(spans))
+	if err != nil {
+		return err
+	}
+
+	resp, err := e.client.Post(e.endpoint, "application/json", bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(ioutil.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected response status: %s", resp.Status)
+	}
+	return nil
+}
+
+// otlpPayload converts the spans into an OTLP export request.
+func otlpPayload(spans []*SpanData) *otlpRequest {
+	result := make([]otlpSpan, 0, len(spans))
+	for _, span := range spans {
+		converted := otlpSpan{
+			TraceID:           span.TraceID,
+			SpanID:            span.SpanID,
+			ParentSpanID:      span.ParentID,
+			Name:              span.Name,
+			Kind:              span.Kind,
+			StartTimeUnixNano: strconv.FormatInt(span.Start.UnixNano(), 10),
+			EndTimeUnixNano:   strconv.FormatInt(span.End.UnixNano(), 10),
+		}
+		for _, attribute := range span.Attributes {
+			converted.Attributes = append(converted.Attributes, otlpAttribute{Key: attribute.Key, Value: otlpValue{StringValue: attribute.Value}})
+		}
+		if span.Error != "" {
+			converted.Status = &otlpStatus{Code: statusCodeError, Message: span.Error}
+		}
+		result = append(result, converted)
+	}
+
+	return &otlpRequest{
+		ResourceSpans: []otlpResourceSpans{
+			{
+				Resource: otlpResource{
+					Attributes: []otlpAttribute{{Key: "service.name", Value: otlpValue{StringValue: serviceName}}},
+				},
+				ScopeSpans: []otlpScopeSpans{
+					{
+						Scope: otlpScope{Name: serviceName},
+						Spans: result,
+					},
+				},
+			},
+		},
+	}
+}
@@ -0,0 +1,121 @@
+package tracing
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestOTLPExporterEndpoint(t *testing.T) {
+	for _, tc := range []struct {
+		url      string
+		endpoint string
+	}{
+		{url: "http://collector:4318", endpoint: "http://collector:4318/v1/traces"},
+		{url: "http://collector:4318/", endpoint: "http://collector:4318/v1/traces"},
+		{url: "https://collector/custom/traces", endpoint: "https://collector/custom/traces"},
+	} {
+		t.Run(tc.url, func(t *testing.T) {
+			exporter := NewOTLPExporter(tc.url).(*otlpExporter)
+			defer exporter.Shutdown()
+			require.Equal(t, tc.endpoint, exporter.endpoint)
+		})
+	}
+}
+
+func TestOTLPExporter(t *testing.T) {
+	var mu sync.Mutex
+	var requests []otlpRequest
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, otlpTracesPath, r.URL.Path)
+		require.Equal(t, "application/json", r.Header.Get("Content-Type"))
+
+		var request otlpRequest
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&request))
+
+		mu.Lock()
+		defer mu.Unlock()
+		requests = append(requests, request)
+	}))
+	defer server.Close()
+
+	exporter := NewOTLPExporter(server.URL)
+
+	start := time.Unix(1538395200, 0)
+	err := exporter.Export([]*SpanData{
+		{
+			TraceID:    "0102",
+			SpanID:     "03",
+			Name:       "provision",
+			Kind:       KindInternal,
+			Start:      start,
+			End:        start.Add(time.Second),
+			Attributes: []Attribute{{Key: "cluster", Value: "foo"}},
+		},
+		{
+			TraceID:  "0102",
+			SpanID:   "04",
+			ParentID: "03",
+			Name:     "aws.ec2.DescribeVpcs",
+			Kind:     KindClient,
+			Start:    start,
+			End:      start.Add(time.Second),
+			Error:    "failed",
+		},
+	})
+	require.NoError(t, err)
+
+	// queued spans are flushed on shutdown
+	require.NoError(t, exporter.Shutdown())
+
+	mu.Lock()
+	defer mu.Unlock()
+	require.Len(t, requests, 1)
+	require.Len(t, requests[0].ResourceSpans, 1)
+
+	resourceSpans := requests[0].ResourceSpans[0]
+	require.Equal(t, []otlpAttribute{{Key: "service.name", Value: otlpValue{StringValue: serviceName}}}, resourceSpans.Resource.Attributes)
+	require.Len(t, resourceSpans.ScopeSpans, 1)
+
+	spans := resourceSpans.ScopeSpans[0].Spans
+	require.Equal(t, []otlpSpan{
+		{
+			TraceID:           "0102",
+			SpanID:            "03",
+			Name:              "provision",
+			Kind:              KindInternal,
+			StartTimeUnixNano: "1538395200000000000",
+			EndTimeUnixNano:   "1538395201000000000",
+			Attributes:        []otlpAttribute{{Key: "cluster", Value: otlpValue{StringValue: "foo"}}},
+		},
+		{
+			TraceID:           "0102",
+			SpanID:            "04",
+			ParentSpanID:      "03",
+			Name:              "aws.ec2.DescribeVpcs",
+			Kind:              KindClient,
+			StartTimeUnixNano: "1538395200000000000",
+			EndTimeUnixNano:   "1538395201000000000",
+			Status:            &otlpStatus{Code: statusCodeError, Message: "failed"},
+		},
+	}, spans)
+}
+
+func TestOTLPExporterExportAfterShutdown(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	exporter := NewOTLPExporter(server.URL)
+	require.NoError(t, exporter.Shutdown())
+	require.NoError(t, exporter.Shutdown())
+
+	// spans ending after the shutdown are dropped
+	err := exporter.Export([]*SpanData{{TraceID: "0102", SpanID: "03", Name: "provision"}})
+	require.Error(t, err)
+}
@@ -0,0 +1,207 @@
+package tracing
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	// KindInternal is the kind of spans of operations within CLM.
+	KindInternal = 1
+	// KindClient is the kind of spans of requests to other services,
+	// e.g. AWS API calls.
+	KindClient = 3
+)
+
+type spanKey struct{}
+
+// SpanData is the immutable record of a finished span passed to exporters.
+type SpanData struct {
+	TraceID    string
+	SpanID     string
+	ParentID   string
+	Name       string
+	Kind       int
+	Start      time.Time
+	End        time.Time
+	Attributes []Attribute
+	Error      string
+}
+
+// Attribute is a key/value pair describing a span.
+type Attribute struct {
+	Key   string
+	Value string
+}
+
+// Exporter exports finished spans.
+type Exporter interface {
+	Export(spans []*SpanData) error
+	// Shutdown flushes the spans not exported yet.
+	Shutdown() error
+}
+
+var (
+	exporterMu sync.RWMutex
+	exporter   Exporter
+)
+
+// Setup configures the exporter of the spans based on the uri and returns a
+// function flushing the remaining spans on shutdown. Supported are
+// http(s)://<collector>, an OTLP/HTTP endpoint, e.g.
+// http://otel-collector:4318, file:///<path> and stdout://, writing one JSON
+// encoded span per line. Tracing is disabled if uri is empty.
+func Setup(uri string) (func(), error) {
+	if uri == "" {
+		return func() {}, nil
+	}
+
+	url, err := url.Parse(uri)
+	if err != nil {
+		return nil, err
+	}
+
+	var newExporter Exporter
+	var file *os.File
+	switch url.Scheme {
+	case "http", "https":
+		newExporter = NewOTLPExporter(uri)
+	case "file":
+		file, err = os.OpenFile(url.Host+url.Path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+		if err != nil {
+			return nil, err
+		}
+		newExporter = NewJSONExporter(file)
+	case "stdout":
+		newExporter = NewJSONExporter(os.Stdout)
+	default:
+		return nil, fmt.Errorf("unknown trace exporter type: %v", url.Scheme)
+	}
+
+	SetExporter(newExporter)
+	return func() {
+		SetExporter(nil)
+		newExporter.Shutdown()
+		if file != nil {
+			file.Close()
+		}
+	}, nil
+}
+
+// SetExporter sets the exporter of the spans. Spans are only recorded if an
+// exporter is set.
+func SetExporter(e Exporter) {
+	exporterMu.Lock()
+	defer exporterMu.Unlock()
+	exporter = e
+}
+
+func currentExporter() Exporter {
+	exporterMu.RLock()
+	defer exporterMu.RUnlock()
+	return exporter
+}
+
+// Span is an operation in progress. All methods of a nil span are no-ops, so
+// callers don't need to check whether tracing is enabled.
+type Span struct {
+	sync.Mutex
+	data     SpanData
+	exporter Exporter
+	ended    bool
+}
+
+// Start starts a span of an internal operation as a child of the span of ctx,
+// or as the root of a new trace if ctx has none. Attributes are passed as
+// alternating keys and values. It returns a context carrying the new span.
+func Start(ctx context.Context, name string, attributes ...string) (context.Context, *Span) {
+	return start(ctx, name, KindInternal, attributes)
+}
+
+// StartClient starts a span of a request to another service, see Start.
+func StartClient(ctx context.Context, name string, attributes ...string) (context.Context, *Span) {
+	return start(ctx, name, KindClient, attributes)
+}
+
+func start(ctx context.Context, name string, kind int, attributes []string) (context.Context, *Span) {
+	e := currentExporter()
+	if e == nil {
+		return ctx, nil
+	}
+
+	span := &Span{
+		exporter: e,
+		data: SpanData{
+			SpanID: newID(8),
+			Name:   name,
+			Kind:   kind,
+			Start:  time.Now(),
+		},
+	}
+
+	if parent := FromContext(ctx); parent != nil {
+		span.data.TraceID = parent.data.TraceID
+		span.data.ParentID = parent.data.SpanID
+	} else {
+		span.data.TraceID = newID(16)
+	}
+
+	for i := 0; i+1 < len(attributes); i += 2 {
+		span.SetAttribute(attributes[i], attributes[i+1])
+	}
+
+	return context.WithValue(ctx, spanKey{}, span), span
+}
+
+// FromContext returns the span of ctx, or nil if there's none.
+func FromContext(ctx context.Context) *Span {
+	span, _ := ctx.Value(spanKey{}).(*Span)
+	return span
+}
+
+// SetAttribute adds an attribute to the span.
+func (s *Span) SetAttribute(key, value string) {
+	if s == nil {
+		return
+	}
+
+	s.Lock()
+	defer s.Unlock()
+	s.data.Attributes = append(s.data.Attributes, Attribute{Key: key, Value: value})
+}
+
+// End finishes the span and hands it to the exporter. The span is marked as
+// failed if err is not nil. Only the first call has an effect.
+func (s *Span) End(err error) {
+	if s == nil {
+		return
+	}
+
+	s.Lock()
+	if s.ended {
+		s.Unlock()
+		return
+	}
+	s.ended = true
+	s.data.End = time.Now()
+	if err != nil {
+		s.data.Error = err.Error()
+	}
+	data := s.data
+	s.Unlock()
+
+	s.exporter.Export([]*SpanData{&data})
+}
+
+// newID returns a random hex encoded ID of n bytes.
+func newID(n int) string {
+	id := make([]byte, n)
+	rand.Read(id)
+	return hex.EncodeToString(id)
+}
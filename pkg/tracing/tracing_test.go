@@ -0,0 +1,95 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type recordingExporter struct {
+	sync.Mutex
+	spans    []*SpanData
+	shutdown bool
+}
+
+func (e *recordingExporter) Export(spans []*SpanData) error {
+	e.Lock()
+	defer e.Unlock()
+	e.spans = append(e.spans, spans...)
+	return nil
+}
+
+func (e *recordingExporter) Shutdown() error {
+	e.shutdown = true
+	return nil
+}
+
+func TestSpansDisabled(t *testing.T) {
+	SetExporter(nil)
+
+	ctx, span := Start(context.Background(), "test", "key", "value")
+	require.Nil(t, span)
+	require.Nil(t, FromContext(ctx))
+
+	// nil spans are no-ops
+	span.SetAttribute("key", "value")
+	span.End(errors.New("failed"))
+}
+
+func TestSpanHierarchy(t *testing.T) {
+	exporter := &recordingExporter{}
+	SetExporter(exporter)
+	defer SetExporter(nil)
+
+	ctx, root := Start(context.Background(), "root", "cluster", "foo")
+	require.Equal(t, root, FromContext(ctx))
+
+	childCtx, child := StartClient(ctx, "child")
+	require.Equal(t, child, FromContext(childCtx))
+	child.SetAttribute("node", "bar")
+	child.End(errors.New("failed"))
+	child.End(nil)
+
+	root.End(nil)
+
+	require.Len(t, exporter.spans, 2)
+	childData, rootData := exporter.spans[0], exporter.spans[1]
+
+	require.Equal(t, "root", rootData.Name)
+	require.Equal(t, KindInternal, rootData.Kind)
+	require.Len(t, rootData.TraceID, 32)
+	require.Len(t, rootData.SpanID, 16)
+	require.Empty(t, rootData.ParentID)
+	require.Equal(t, []Attribute{{Key: "cluster", Value: "foo"}}, rootData.Attributes)
+	require.Empty(t, rootData.Error)
+	require.False(t, rootData.End.Before(rootData.Start))
+
+	require.Equal(t, "child", childData.Name)
+	require.Equal(t, KindClient, childData.Kind)
+	require.Equal(t, rootData.TraceID, childData.TraceID)
+	require.Equal(t, rootData.SpanID, childData.ParentID)
+	require.NotEqual(t, rootData.SpanID, childData.SpanID)
+	require.Equal(t, []Attribute{{Key: "node", Value: "bar"}}, childData.Attributes)
+	require.Equal(t, "failed", childData.Error)
+}
+
+func TestSetup(t *testing.T) {
+	shutdown, err := Setup("")
+	require.NoError(t, err)
+	require.Nil(t, currentExporter())
+	shutdown()
+
+	for _, uri := range []string{"stdout://", "http://localhost:4318"} {
+		shutdown, err = Setup(uri)
+		require.NoError(t, err)
+		require.NotNil(t, currentExporter())
+		shutdown()
+		require.Nil(t, currentExporter())
+	}
+
+	_, err = Setup("foo://bar")
+	require.Error(t, err)
+}
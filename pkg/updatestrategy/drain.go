@@ -9,6 +9,8 @@ import (
 
 	"github.com/cenkalti/backoff"
 	log "github.com/sirupsen/logrus"
+	kubeUtils "github.com/zalando-incubator/cluster-lifecycle-manager/pkg/kubernetes"
+	"github.com/zalando-incubator/cluster-lifecycle-manager/pkg/tracing"
 	"golang.org/x/sync/errgroup"
 	"k8s.io/api/core/v1"
 	policy "k8s.io/api/policy/v1beta1"
@@ -39,10 +41,13 @@ func timestampAnnotation(logger *log.Entry, node *Node, annotation string, fallb
 }
 
 // drain tries to cleanly evict all of the pods on a node, and then forcibly terminates the remaining ones.
-func (m *KubernetesNodePoolManager) drain(ctx context.Context, node *Node) error {
+func (m *KubernetesNodePoolManager) drain(ctx context.Context, node *Node) (err error) {
+	ctx, span := tracing.Start(ctx, "drain", "node", node.Name)
+	defer func() { span.End(err) }()
+
 	m.logger.WithField("node", node.Name).Info("Draining node")
 
-	err := m.labelNode(node, lifecycleStatusLabel, lifecycleStatusDraining)
+	err = m.labelNode(node, lifecycleStatusLabel, lifecycleStatusDraining)
 	if err != nil {
 		return err
 	}
@@ -55,6 +60,15 @@ func (m *KubernetesNodePoolManager) drain(ctx context.Context, node *Node) error
 
 	lastForcedTermination := timestampAnnotation(m.logger, node, lastForcedDrainAnnotation, time.Unix(0, 0))
 
+	m.events.Eventf(kubeUtils.NodeReference(node.Name), v1.EventTypeNormal, eventReasonDraining, "Draining the node for a cluster update since %s", drainStart.UTC().Format(time.RFC3339))
+
+	// the number of evicted pods is summarized on the node once the drain ends
+	evicted := &evictedPods{}
+	defer m.recordEvictions(node, evicted)
+
+	stuckReporter := drainStuckReporterFrom(ctx)
+	reportedStuck := false
+
 	// fast eviction (in parallel) as long as we can evict something without violating PDBs
 	for {
 		err = ctx.Err()
@@ -71,14 +85,14 @@ func (m *KubernetesNodePoolManager) drain(ctx context.Context, node *Node) error
 			return nil
 		}
 
-		evicted, err := m.evictParallel(ctx, pods)
+		evictedAny, err := m.evictParallel(ctx, pods, evicted)
 		if err == nil {
 			err = ctx.Err()
 		}
 		if err != nil {
 			return err
 		}
-		if !evicted {
+		if !evictedAny {
 			break
 		}
 		time.Sleep(m.drainConfig.PollInterval)
@@ -91,6 +105,8 @@ func (m *KubernetesNodePoolManager) drain(ctx context.Context, node *Node) error
 			return err
 		}
 
+		reportedStuck = stuckReporter.check(node.Name, drainStart, reportedStuck)
+
 		pods, err := m.evictablePods(node.Name)
 		if err != nil {
 			return err
@@ -100,7 +116,7 @@ func (m *KubernetesNodePoolManager) drain(ctx context.Context, node *Node) error
 			return nil
 		}
 
-		forceEvicted, err := m.evictOrForceTerminatePod(ctx, pods, drainStart, lastForcedTermination)
+		forceEvicted, err := m.evictOrForceTerminatePod(ctx, pods, drainStart, lastForcedTermination, evicted)
 		if err != nil {
 			return err
 		}
@@ -119,7 +135,7 @@ func (m *KubernetesNodePoolManager) drain(ctx context.Context, node *Node) error
 	return nil
 }
 
-func (m *KubernetesNodePoolManager) evictParallel(ctx context.Context, pods []v1.Pod) (bool, error) {
+func (m *KubernetesNodePoolManager) evictParallel(ctx context.Context, pods []v1.Pod, evictions *evictedPods) (bool, error) {
 	var evicted int64
 	var group errgroup.Group
 
@@ -136,6 +152,7 @@ func (m *KubernetesNodePoolManager) evictParallel(ctx context.Context, pods []v1
 			}
 
 			atomic.AddInt64(&evicted, 1)
+			m.recordEviction(pod, evictions)
 			return nil
 		})
 	}
@@ -152,11 +169,35 @@ func (m *KubernetesNodePoolManager) podLogger(pod v1.Pod) *log.Entry {
 	})
 }
 
+// evictedPods counts the pods evicted from a node being drained.
+type evictedPods struct {
+	count int64
+}
+
+// recordEviction records an event about the eviction of a pod from a node
+// being drained. The event is attached to the pod, so that the owners of the
+// pod can see why it was evicted.
+func (m *KubernetesNodePoolManager) recordEviction(pod v1.Pod, evictions *evictedPods) {
+	m.events.Eventf(kubeUtils.PodReference(&pod), v1.EventTypeNormal, eventReasonEvicting, "Evicted the pod to drain node %s for a cluster update", pod.Spec.NodeName)
+	atomic.AddInt64(&evictions.count, 1)
+}
+
+// recordEvictions records an event summarizing the number of pods evicted
+// from a node.
+func (m *KubernetesNodePoolManager) recordEvictions(node *Node, evictions *evictedPods) {
+	evicted := atomic.LoadInt64(&evictions.count)
+	if evicted == 0 {
+		return
+	}
+
+	m.events.Eventf(kubeUtils.NodeReference(node.Name), v1.EventTypeNormal, eventReasonEvicting, "Evicted %d pods to drain the node for a cluster update", evicted)
+}
+
 func (m *KubernetesNodePoolManager) logPdbViolated(pod v1.Pod) {
 	m.podLogger(pod).Info("Pod Disruption Budget violated")
 }
 
-func (m *KubernetesNodePoolManager) evictOrForceTerminatePod(ctx context.Context, pods []v1.Pod, drainStart, lastForcedTermination time.Time) (bool, error) {
+func (m *KubernetesNodePoolManager) evictOrForceTerminatePod(ctx context.Context, pods []v1.Pod, drainStart, lastForcedTermination time.Time, evictions *evictedPods) (bool, error) {
 	for _, pod := range pods {
 		err := ctx.Err()
 		if err != nil {
@@ -166,6 +207,7 @@ func (m *KubernetesNodePoolManager) evictOrForceTerminatePod(ctx context.Context
 		// try evicting normally
 		err = evictPod(m.kube, m.logger, pod)
 		if err == nil {
+			m.recordEviction(pod, evictions)
 			return false, nil
 		}
 		if !isPDBViolation(err) {
@@ -177,6 +219,10 @@ func (m *KubernetesNodePoolManager) evictOrForceTerminatePod(ctx context.Context
 
 		forceTerminate, err := m.forceTerminationAllowed(pod, time.Now(), drainStart, lastForcedTermination)
 		if forceTerminate {
+			m.events.Eventf(kubeUtils.PodReference(&pod), v1.EventTypeWarning, eventReasonForceTerminating,
+				"Force terminating the pod violating its PodDisruptionBudget, node %s is draining since %s (grace period %s)",
+				pod.Spec.NodeName, drainStart.UTC().Format(time.RFC3339), m.drainConfig.ForceEvictionGracePeriod)
+
 			err = deletePod(m.kube, m.podLogger(pod), pod)
 			if err != nil {
 				return false, err
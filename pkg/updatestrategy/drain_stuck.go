@@ -0,0 +1,43 @@
+package updatestrategy
+
+import (
+	"context"
+	"time"
+)
+
+type drainStuckKey struct{}
+
+// drainStuckReporter reports nodes which couldn't be drained in time.
+type drainStuckReporter struct {
+	after   time.Duration
+	handler func(node string, drainStart time.Time)
+}
+
+// WithDrainStuckHandler returns a context making every drain of a node call
+// handler once if the node couldn't be drained within after. The drain
+// itself continues.
+func WithDrainStuckHandler(ctx context.Context, after time.Duration, handler func(node string, drainStart time.Time)) context.Context {
+	return context.WithValue(ctx, drainStuckKey{}, &drainStuckReporter{after: after, handler: handler})
+}
+
+// drainStuckReporterFrom returns the reporter of ctx, or nil if stuck drains
+// aren't reported.
+func drainStuckReporterFrom(ctx context.Context) *drainStuckReporter {
+	reporter, _ := ctx.Value(drainStuckKey{}).(*drainStuckReporter)
+	return reporter
+}
+
+// check calls the handler if the drain of node started at drainStart is
+// stuck and wasn't reported yet. It returns true if the drain was reported.
+func (r *drainStuckReporter) check(node string, drainStart time.Time, reported bool) bool {
+	if r == nil || reported || r.after <= 0 {
+		return reported
+	}
+
+	if time.Since(drainStart) < r.after {
+		return false
+	}
+
+	r.handler(node, drainStart)
+	return true
+}
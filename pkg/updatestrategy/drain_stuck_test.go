@@ -0,0 +1,33 @@
+package updatestrategy
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDrainStuckReporter(t *testing.T) {
+	require.Nil(t, drainStuckReporterFrom(context.Background()))
+
+	var reported []string
+	ctx := WithDrainStuckHandler(context.Background(), time.Hour, func(node string, drainStart time.Time) {
+		reported = append(reported, node)
+	})
+	reporter := drainStuckReporterFrom(ctx)
+	require.NotNil(t, reporter)
+
+	// not stuck yet
+	require.False(t, reporter.check("node-a", time.Now().Add(-time.Minute), false))
+	require.Empty(t, reported)
+
+	// stuck drains are reported only once
+	require.True(t, reporter.check("node-a", time.Now().Add(-2*time.Hour), false))
+	require.True(t, reporter.check("node-a", time.Now().Add(-2*time.Hour), true))
+	require.Equal(t, []string{"node-a"}, reported)
+
+	// a nil reporter never reports
+	var disabled *drainStuckReporter
+	require.False(t, disabled.check("node-b", time.Unix(0, 0), false))
+}
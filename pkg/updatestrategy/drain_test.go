@@ -11,6 +11,7 @@ import (
 	log "github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	kubeUtils "github.com/zalando-incubator/cluster-lifecycle-manager/pkg/kubernetes"
 	"k8s.io/api/core/v1"
 	policy "k8s.io/api/policy/v1beta1"
 	"k8s.io/apimachinery/pkg/api/errors"
@@ -98,18 +99,36 @@ func TestTerminateNode(t *testing.T) {
 			},
 		},
 	}
+	kube := setupMockKubernetes(t, []*v1.Node{node}, pods, nil)
 	mgr := &KubernetesNodePoolManager{
 		logger:  logger,
-		kube:    setupMockKubernetes(t, []*v1.Node{node}, pods, nil),
+		kube:    kube,
 		backend: backend,
 		drainConfig: &DrainConfig{
 			PollInterval: time.Second,
 		},
+		events: kubeUtils.NewEventRecorder(logger, kube),
 	}
 
 	err := mgr.TerminateNode(context.Background(), &Node{Name: node.Name}, false)
 	assert.NoError(t, err)
 
+	// the eviction is recorded on the pod and summarized on the node
+	events, err := kube.CoreV1().Events(metav1.NamespaceDefault).List(metav1.ListOptions{})
+	require.NoError(t, err)
+	evictions := make(map[string]v1.Event)
+	for _, event := range events.Items {
+		if event.Reason == eventReasonEvicting {
+			evictions[event.InvolvedObject.Kind] = event
+		}
+	}
+	require.Len(t, evictions, 2)
+	require.Equal(t, "a", evictions["Pod"].InvolvedObject.Name)
+	require.Equal(t, "default", evictions["Pod"].InvolvedObject.Namespace)
+	require.Equal(t, "Evicted the pod to drain node "+testNodeName+" for a cluster update", evictions["Pod"].Message)
+	require.Equal(t, testNodeName, evictions["Node"].InvolvedObject.Name)
+	require.Equal(t, "Evicted 1 pods to drain the node for a cluster update", evictions["Node"].Message)
+
 	// test when evictPod returns 429
 	evictPod = evictPodFailPDB
 
@@ -0,0 +1,35 @@
+package updatestrategy
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	generationOld = "old"
+	generationNew = "new"
+)
+
+var (
+	nodePoolUpdateInProgress = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: "clm",
+			Subsystem: "node_pool_update",
+			Name:      "in_progress",
+			Help:      "Whether a rolling update of the node pool is currently running (1) or not (0).",
+		},
+		[]string{"cluster", "node_pool"},
+	)
+	nodePoolUpdateNodes = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: "clm",
+			Subsystem: "node_pool_update",
+			Name:      "nodes",
+			Help:      "Number of nodes in a node pool being updated, partitioned by old and new generation.",
+		},
+		[]string{"cluster", "node_pool", "generation"},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(nodePoolUpdateInProgress, nodePoolUpdateNodes)
+}
@@ -9,6 +9,7 @@ import (
 	"github.com/cenkalti/backoff"
 	log "github.com/sirupsen/logrus"
 	"github.com/zalando-incubator/cluster-lifecycle-manager/api"
+	kubeUtils "github.com/zalando-incubator/cluster-lifecycle-manager/pkg/kubernetes"
 	"k8s.io/api/core/v1"
 	apiErrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -32,6 +33,13 @@ const (
 
 	decommissionPendingTaintKey   = "decommission-pending"
 	decommissionPendingTaintValue = "rolling-upgrade"
+
+	// reasons of the events recorded about nodes and pods
+	eventReasonCordoning        = "Cordoning"
+	eventReasonDraining         = "Draining"
+	eventReasonEvicting         = "Evicting"
+	eventReasonForceTerminating = "ForceTerminating"
+	eventReasonTerminating      = "Terminating"
 )
 
 // NodePoolManager defines an interface for managing node pools when performing
@@ -72,6 +80,7 @@ type KubernetesNodePoolManager struct {
 	backend     ProviderNodePoolsBackend
 	logger      *log.Entry
 	drainConfig *DrainConfig
+	events      *kubeUtils.EventRecorder
 }
 
 // NewKubernetesNodePoolManager initializes a new Kubernetes NodePool manager
@@ -84,6 +93,7 @@ func NewKubernetesNodePoolManager(logger *log.Entry, kubeClient kubernetes.Inter
 		backend:     poolBackend,
 		logger:      logger,
 		drainConfig: drainConfig,
+		events:      kubeUtils.NewEventRecorder(logger, kubeClient),
 	}
 }
 
@@ -283,6 +293,7 @@ func (m *KubernetesNodePoolManager) TerminateNode(ctx context.Context, node *Nod
 	}
 
 	m.logger.WithField("node", node.Name).Info("Terminating node")
+	m.events.Eventf(kubeUtils.NodeReference(node.Name), v1.EventTypeNormal, eventReasonTerminating, "Terminating the instance of the node for a cluster update")
 
 	return m.backend.Terminate(node, decrementDesired)
 }
@@ -358,7 +369,14 @@ func (m *KubernetesNodePoolManager) ScalePool(ctx context.Context, nodePool *api
 func (m *KubernetesNodePoolManager) CordonNode(node *Node) error {
 	unschedulable := []byte(`{"spec": {"unschedulable": true}}`)
 	_, err := m.kube.CoreV1().Nodes().Patch(node.Name, types.StrategicMergePatchType, unschedulable)
-	return err
+	if err != nil {
+		return err
+	}
+
+	if !node.Cordoned {
+		m.events.Eventf(kubeUtils.NodeReference(node.Name), v1.EventTypeNormal, eventReasonCordoning, "Cordoned the node, it's going to be replaced by a cluster update")
+	}
+	return nil
 }
 
 // WaitForDesiredNodes waits for the current number of nodes to match the
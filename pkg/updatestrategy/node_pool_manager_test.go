@@ -245,12 +245,29 @@ func TestCordonNode(t *testing.T) {
 		},
 	}
 
-	mgr := &KubernetesNodePoolManager{
-		kube: setupMockKubernetes(t, []*v1.Node{node}, nil, nil),
-	}
+	kube := setupMockKubernetes(t, []*v1.Node{node}, nil, nil)
+	mgr := NewKubernetesNodePoolManager(log.WithField("test", true), kube, nil, &DrainConfig{})
 
 	err := mgr.CordonNode(&Node{Name: node.Name})
 	assert.NoError(t, err)
+
+	updated, err := kube.CoreV1().Nodes().Get(node.Name, metav1.GetOptions{})
+	require.NoError(t, err)
+	require.True(t, updated.Spec.Unschedulable)
+
+	// the users of the cluster can see why the node was cordoned
+	events, err := kube.CoreV1().Events(metav1.NamespaceDefault).List(metav1.ListOptions{})
+	require.NoError(t, err)
+	require.Len(t, events.Items, 1)
+	require.Equal(t, eventReasonCordoning, events.Items[0].Reason)
+	require.Equal(t, node.Name, events.Items[0].InvolvedObject.Name)
+
+	// cordoning a cordoned node again isn't recorded
+	err = mgr.CordonNode(&Node{Name: node.Name, Cordoned: true})
+	assert.NoError(t, err)
+	events, err = kube.CoreV1().Events(metav1.NamespaceDefault).List(metav1.ListOptions{})
+	require.NoError(t, err)
+	require.Len(t, events.Items, 1)
 }
 
 func TestScalePool(tt *testing.T) {
@@ -0,0 +1,20 @@
+package updatestrategy
+
+import "context"
+
+type nodePoolRolledKey struct{}
+
+// WithNodePoolRolledHandler returns a context making every update of a node
+// pool call handler once its outdated nodes start being replaced. Node pools
+// without outdated nodes aren't reported.
+func WithNodePoolRolledHandler(ctx context.Context, handler func(nodePool string)) context.Context {
+	return context.WithValue(ctx, nodePoolRolledKey{}, handler)
+}
+
+// ReportNodePoolRolled calls the handler of ctx, if any, for a node pool
+// being rolled. It's called by the update strategies.
+func ReportNodePoolRolled(ctx context.Context, nodePool string) {
+	if handler, ok := ctx.Value(nodePoolRolledKey{}).(func(string)); ok {
+		handler(nodePool)
+	}
+}
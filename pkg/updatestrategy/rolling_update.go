@@ -6,6 +6,7 @@ import (
 	"time"
 
 	"github.com/zalando-incubator/cluster-lifecycle-manager/api"
+	"github.com/zalando-incubator/cluster-lifecycle-manager/pkg/util/interrupt"
 
 	log "github.com/sirupsen/logrus"
 )
@@ -18,20 +19,30 @@ var (
 // RollingUpdateStrategy is a cluster node update strategy which will roll the
 // nodes with a specified surge.
 type RollingUpdateStrategy struct {
+	clusterID       string
 	nodePoolManager NodePoolManager
 	surge           int
 	logger          *log.Entry
 }
 
 // NewRollingUpdateStrategy initializes a new RollingUpdateStrategy.
-func NewRollingUpdateStrategy(logger *log.Entry, nodePoolManager NodePoolManager, surge int) *RollingUpdateStrategy {
+func NewRollingUpdateStrategy(logger *log.Entry, clusterID string, nodePoolManager NodePoolManager, surge int) *RollingUpdateStrategy {
 	return &RollingUpdateStrategy{
+		clusterID:       clusterID,
 		nodePoolManager: nodePoolManager,
 		surge:           surge,
 		logger:          logger.WithField("strategy", "rolling"),
 	}
 }
 
+// reportProgress exposes the number of old and new nodes of the node pool
+// being updated.
+func (r *RollingUpdateStrategy) reportProgress(nodePoolDesc *api.NodePool, nodePool *NodePool) {
+	oldNodes, newNodes := r.splitOldNewNodes(nodePool)
+	nodePoolUpdateNodes.WithLabelValues(r.clusterID, nodePoolDesc.Name, generationOld).Set(float64(len(oldNodes)))
+	nodePoolUpdateNodes.WithLabelValues(r.clusterID, nodePoolDesc.Name, generationNew).Set(float64(len(newNodes)))
+}
+
 func (r *RollingUpdateStrategy) markOldNodes(nodePool *NodePool) error {
 	for _, node := range nodePool.Nodes {
 		if node.Generation != nodePool.Generation {
@@ -52,7 +63,8 @@ func (r *RollingUpdateStrategy) isUpdateDone(nodePool *NodePool) bool {
 
 // terminateCordonedNodes filters for nodes to be terminated and terminates the
 // nodes one by one. It will conditionally scale down the node pool in case
-// there is less than surge old nodes left.
+// there is less than surge old nodes left. If an interruption is requested,
+// no further nodes are terminated after the current one.
 func (r *RollingUpdateStrategy) terminateCordonedNodes(ctx context.Context, nodePool *NodePool, surge int) error {
 	oldNodes, _ := r.splitOldNewNodes(nodePool)
 	nodesToTerminate := filterNodesToTerminate(oldNodes)
@@ -71,6 +83,11 @@ func (r *RollingUpdateStrategy) terminateCordonedNodes(ctx context.Context, node
 		}
 
 		numOldNodes--
+
+		// the remaining nodes are terminated when the update is resumed
+		if interrupt.Requested(ctx) {
+			break
+		}
 	}
 
 	return nil
@@ -103,7 +120,9 @@ func (r *RollingUpdateStrategy) increaseByUnmatchedNodes(ctx context.Context, no
 }
 
 // Update performs a rolling update of a single node pool. Passing a context
-// allows stopping the update loop in case the context is canceled.
+// allows stopping the update loop in case the context is canceled. If an
+// interruption is requested through the context, the update stops once the
+// nodes terminated so far are replaced and returns interrupt.ErrInterrupted.
 func (r *RollingUpdateStrategy) Update(ctx context.Context, nodePoolDesc *api.NodePool) error {
 	r.logger.Infof("Initializing update of node pool '%s'", nodePoolDesc.Name)
 
@@ -111,9 +130,14 @@ func (r *RollingUpdateStrategy) Update(ctx context.Context, nodePoolDesc *api.No
 		return nil
 	}
 
+	inProgress := nodePoolUpdateInProgress.WithLabelValues(r.clusterID, nodePoolDesc.Name)
+	inProgress.Set(1)
+	defer inProgress.Set(0)
+
 	// limit surge to max size of the node pool
 	surge := int(math.Min(float64(nodePoolDesc.MaxSize), float64(r.surge)))
 	spotPool := nodePoolDesc.DiscountStrategy == api.DiscountStrategySpot
+	rolled := false
 
 	for {
 		// wait/scale to ensure that we have at least 'surge' new nodes in the node pool
@@ -127,6 +151,8 @@ func (r *RollingUpdateStrategy) Update(ctx context.Context, nodePoolDesc *api.No
 			return err
 		}
 
+		r.reportProgress(nodePoolDesc, nodePool)
+
 		if err = ctx.Err(); err != nil {
 			return err
 		}
@@ -145,6 +171,11 @@ func (r *RollingUpdateStrategy) Update(ctx context.Context, nodePoolDesc *api.No
 			break
 		}
 
+		if !rolled {
+			ReportNodePoolRolled(ctx, nodePoolDesc.Name)
+			rolled = true
+		}
+
 		// terminate all cordoned nodes and conditionally scale
 		// down the node pool in case there are less than surge old
 		// nodes left to update
@@ -163,7 +194,11 @@ func (r *RollingUpdateStrategy) Update(ctx context.Context, nodePoolDesc *api.No
 			return err
 		}
 
-		if err = ctx.Err(); err != nil {
+		r.reportProgress(nodePoolDesc, nodePool)
+
+		// safe point: the terminated nodes are replaced and no further
+		// nodes are cordoned yet
+		if err = interrupt.Check(ctx); err != nil {
 			return err
 		}
 
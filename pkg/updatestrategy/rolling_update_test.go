@@ -3,11 +3,13 @@ package updatestrategy
 import (
 	"context"
 	"math/rand"
+	"reflect"
 	"testing"
 	"time"
 
 	log "github.com/sirupsen/logrus"
 	"github.com/zalando-incubator/cluster-lifecycle-manager/api"
+	"github.com/zalando-incubator/cluster-lifecycle-manager/pkg/util/interrupt"
 )
 
 const letterBytes = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ1234567890"
@@ -273,7 +275,7 @@ func TestUpdate(tt *testing.T) {
 		tt.Run(tc.msg, func(t *testing.T) {
 			logger := log.WithField("test", true)
 			np := &api.NodePool{Name: "test", MaxSize: tc.nodePoolMaxSize}
-			strategy := NewRollingUpdateStrategy(logger, tc.nodePoolManager, tc.surge)
+			strategy := NewRollingUpdateStrategy(logger, "test-cluster", tc.nodePoolManager, tc.surge)
 			err := strategy.Update(context.Background(), np)
 			if err != nil && tc.success {
 				t.Errorf("should not fail: %v", err)
@@ -320,3 +322,82 @@ func equalNodePool(a, b *NodePool) bool {
 
 	return true
 }
+
+func TestUpdateInterrupted(t *testing.T) {
+	nodePoolManager := &mockNodePoolManager{
+		nodePool: &NodePool{
+			Min:        3,
+			Max:        3,
+			Current:    3,
+			Desired:    3,
+			Generation: 2,
+			Nodes: []*Node{
+				mockNode("a", 1, true, false),
+				mockNode("b", 1, false, false),
+				mockNode("c", 1, false, false),
+			},
+		},
+	}
+
+	signal := make(chan struct{})
+	close(signal)
+	ctx := interrupt.WithSignal(context.Background(), signal)
+
+	logger := log.WithField("test", true)
+	strategy := NewRollingUpdateStrategy(logger, "cluster", nodePoolManager, 1)
+	err := strategy.Update(ctx, &api.NodePool{MaxSize: 3})
+	if err != interrupt.ErrInterrupted {
+		t.Fatalf("expected update to be interrupted, got: %v", err)
+	}
+
+	// the update stops after replacing the node which was already cordoned
+	oldNodes, newNodes := strategy.splitOldNewNodes(nodePoolManager.nodePool)
+	if len(oldNodes) != 2 || len(newNodes) != 2 {
+		t.Errorf("expected 2 old and 2 new nodes, got %d old and %d new", len(oldNodes), len(newNodes))
+	}
+}
+
+func TestUpdateReportsRolledNodePools(t *testing.T) {
+	for _, tc := range []struct {
+		msg    string
+		nodes  []*Node
+		rolled []string
+	}{
+		{
+			msg:   "up to date node pool",
+			nodes: []*Node{mockNode("a", 2, false, false), mockNode("b", 2, false, false)},
+		},
+		{
+			msg:    "outdated node pool",
+			nodes:  []*Node{mockNode("a", 1, false, false), mockNode("b", 1, false, false)},
+			rolled: []string{"worker"},
+		},
+	} {
+		t.Run(tc.msg, func(t *testing.T) {
+			nodePoolManager := &mockNodePoolManager{
+				nodePool: &NodePool{
+					Min:        2,
+					Max:        2,
+					Current:    2,
+					Desired:    2,
+					Generation: 2,
+					Nodes:      tc.nodes,
+				},
+			}
+
+			var rolled []string
+			ctx := WithNodePoolRolledHandler(context.Background(), func(nodePool string) {
+				rolled = append(rolled, nodePool)
+			})
+
+			strategy := NewRollingUpdateStrategy(log.WithField("test", true), "cluster", nodePoolManager, 1)
+			err := strategy.Update(ctx, &api.NodePool{Name: "worker", MaxSize: 2})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(tc.rolled, rolled) {
+				t.Errorf("expected rolled node pools %v, got %v", tc.rolled, rolled)
+			}
+		})
+	}
+}
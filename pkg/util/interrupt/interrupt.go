@@ -0,0 +1,46 @@
+package interrupt
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrInterrupted is returned by long running operations which stopped at a
+// safe point because an interruption was requested. Unlike a canceled
+// operation, an interrupted one can be resumed later.
+var ErrInterrupted = errors.New("interrupted at a safe point")
+
+type signalKey struct{}
+
+// WithSignal returns a copy of ctx carrying a channel which is closed when
+// the operations using the context should stop at their next safe point.
+func WithSignal(ctx context.Context, signal <-chan struct{}) context.Context {
+	return context.WithValue(ctx, signalKey{}, signal)
+}
+
+// Requested returns true if an interruption was requested for ctx.
+func Requested(ctx context.Context) bool {
+	signal, ok := ctx.Value(signalKey{}).(<-chan struct{})
+	if !ok {
+		return false
+	}
+
+	select {
+	case <-signal:
+		return true
+	default:
+		return false
+	}
+}
+
+// Check returns the error of ctx if it's done or ErrInterrupted if an
+// interruption was requested. It's meant to be called at safe points.
+func Check(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if Requested(ctx) {
+		return ErrInterrupted
+	}
+	return nil
+}
@@ -0,0 +1,25 @@
+package interrupt
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheck(t *testing.T) {
+	require.NoError(t, Check(context.Background()))
+
+	signal := make(chan struct{})
+	ctx, cancel := context.WithCancel(WithSignal(context.Background(), signal))
+	require.False(t, Requested(ctx))
+	require.NoError(t, Check(ctx))
+
+	close(signal)
+	require.True(t, Requested(ctx))
+	require.Equal(t, ErrInterrupted, Check(ctx))
+
+	// a canceled context takes precedence
+	cancel()
+	require.Equal(t, context.Canceled, Check(ctx))
+}
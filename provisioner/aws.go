@@ -40,6 +40,8 @@ const (
 	stackMaxSize                = 51200
 	cloudformationValidationErr = "ValidationError"
 	cloudformationNoUpdateMsg   = "No updates are to be performed."
+	cloudformationNoChangesMsg  = "didn't contain changes"
+	changeSetWaitTime           = 5 * time.Second
 	clmCFBucketPattern          = "cluster-lifecycle-manager-%s-%s"
 	lifecycleStatusReady        = "ready"
 	etcdInstanceTypeKey         = "etcd_instance_type"
@@ -76,6 +78,9 @@ type cloudFormationAPI interface {
 	DeleteStack(input *cloudformation.DeleteStackInput) (*cloudformation.DeleteStackOutput, error)
 	UpdateTerminationProtection(intput *cloudformation.UpdateTerminationProtectionInput) (*cloudformation.UpdateTerminationProtectionOutput, error)
 	DescribeStacksPages(input *cloudformation.DescribeStacksInput, fn func(resp *cloudformation.DescribeStacksOutput, lastPage bool) bool) error
+	CreateChangeSet(input *cloudformation.CreateChangeSetInput) (*cloudformation.CreateChangeSetOutput, error)
+	DescribeChangeSet(input *cloudformation.DescribeChangeSetInput) (*cloudformation.DescribeChangeSetOutput, error)
+	DeleteChangeSet(input *cloudformation.DeleteChangeSetInput) (*cloudformation.DeleteChangeSetOutput, error)
 }
 
 // s3API is a minimal interface containing only the methods we use from the S3 API
@@ -190,29 +195,53 @@ func decodeUserData(encodedUserData string) (string, error) {
 // If the stackTemplate exceeds the max size, it will automatically upload it
 // to S3 before creating or updating the stack.
 func (a *awsAdapter) applyClusterStack(stackName, stackTemplate string, cluster *api.Cluster, s3BucketName string) error {
-	var templateURL string
-	if len(stackTemplate) > stackMaxSize {
-		// create S3 bucket if it doesn't exist
-		err := a.createS3Bucket(s3BucketName)
-		if err != nil {
-			return err
-		}
-
-		// Upload the stack template to S3
-		result, err := a.s3Uploader.Upload(&s3manager.UploadInput{
-			Bucket: aws.String(s3BucketName),
-			Key:    aws.String(fmt.Sprintf("%s.template", cluster.ID)),
-			Body:   strings.NewReader(stackTemplate),
-		})
-		if err != nil {
-			return err
-		}
-		templateURL = result.Location
+	templateURL, err := a.uploadStackTemplate(stackTemplate, s3BucketName, fmt.Sprintf("%s.template", cluster.ID))
+	if err != nil {
+		return err
 	}
 
 	return a.applyStack(stackName, stackTemplate, templateURL, nil, true)
 }
 
+// planClusterStack returns the changes applying stackTemplate to the stack
+// specified by stackName would make. Like applyClusterStack it uploads the
+// template to S3 if it exceeds the max size, but it doesn't overwrite the
+// template of the current stack.
+func (a *awsAdapter) planClusterStack(ctx context.Context, stackName, stackTemplate string, cluster *api.Cluster, s3BucketName string) (*StackChange, error) {
+	templateURL, err := a.uploadStackTemplate(stackTemplate, s3BucketName, fmt.Sprintf("%s.plan.template", cluster.ID))
+	if err != nil {
+		return nil, err
+	}
+
+	return a.planStack(ctx, stackName, stackTemplate, templateURL, nil)
+}
+
+// uploadStackTemplate uploads the stackTemplate to S3 if it exceeds the max
+// size for templates passed directly to CloudFormation and returns its URL.
+// An empty URL is returned for smaller templates.
+func (a *awsAdapter) uploadStackTemplate(stackTemplate, s3BucketName, key string) (string, error) {
+	if len(stackTemplate) <= stackMaxSize {
+		return "", nil
+	}
+
+	// create S3 bucket if it doesn't exist
+	err := a.createS3Bucket(s3BucketName)
+	if err != nil {
+		return "", err
+	}
+
+	// Upload the stack template to S3
+	result, err := a.s3Uploader.Upload(&s3manager.UploadInput{
+		Bucket: aws.String(s3BucketName),
+		Key:    aws.String(key),
+		Body:   strings.NewReader(stackTemplate),
+	})
+	if err != nil {
+		return "", err
+	}
+	return result.Location, nil
+}
+
 // applyStack applies a cloudformation stack.
 func (a *awsAdapter) applyStack(stackName string, stackTemplate string, stackTemplateURL string, tags []*cloudformation.Tag, updateStack bool) error {
 	createParams := &cloudformation.CreateStackInput{
@@ -283,6 +312,122 @@ func (a *awsAdapter) applyStack(stackName string, stackTemplate string, stackTem
 	return nil
 }
 
+// planStackCreation returns the change to a stack which is only created if it
+// doesn't exist yet, but never updated.
+func (a *awsAdapter) planStackCreation(stackName string) (*StackChange, error) {
+	result := &StackChange{
+		Name:   stackName,
+		Action: StackActionNone,
+	}
+
+	_, err := a.getStackByName(stackName)
+	if err != nil {
+		if isDoesNotExistsErr(err) {
+			result.Action = StackActionCreate
+			return result, nil
+		}
+		return nil, err
+	}
+	return result, nil
+}
+
+// planStack returns the changes applying a cloudformation stack would make.
+// The changes are computed by creating a change set which is deleted again
+// without being executed. Stacks which don't exist yet are only reported as
+// created since creating a change set for them would create the stack.
+func (a *awsAdapter) planStack(ctx context.Context, stackName string, stackTemplate string, stackTemplateURL string, tags []*cloudformation.Tag) (*StackChange, error) {
+	result := &StackChange{
+		Name:   stackName,
+		Action: StackActionUpdate,
+	}
+
+	_, err := a.getStackByName(stackName)
+	if err != nil {
+		if isDoesNotExistsErr(err) {
+			result.Action = StackActionCreate
+			return result, nil
+		}
+		return nil, err
+	}
+
+	changeSetName := fmt.Sprintf("clm-plan-%d", time.Now().Unix())
+
+	createParams := &cloudformation.CreateChangeSetInput{
+		StackName:     aws.String(stackName),
+		ChangeSetName: aws.String(changeSetName),
+		ChangeSetType: aws.String(cloudformation.ChangeSetTypeUpdate),
+		Capabilities:  []*string{aws.String(cloudformation.CapabilityCapabilityNamedIam)},
+		Tags:          tags,
+	}
+
+	if stackTemplateURL != "" {
+		createParams.TemplateURL = aws.String(stackTemplateURL)
+	} else {
+		createParams.TemplateBody = aws.String(stackTemplate)
+	}
+
+	_, err = a.cloudformationClient.CreateChangeSet(createParams)
+	if err != nil {
+		return nil, err
+	}
+
+	defer func() {
+		_, err := a.cloudformationClient.DeleteChangeSet(&cloudformation.DeleteChangeSetInput{
+			StackName:     aws.String(stackName),
+			ChangeSetName: aws.String(changeSetName),
+		})
+		if err != nil {
+			a.logger.Warnf("Failed to delete change set %s of stack %s: %v", changeSetName, stackName, err)
+		}
+	}()
+
+	describeParams := &cloudformation.DescribeChangeSetInput{
+		StackName:     aws.String(stackName),
+		ChangeSetName: aws.String(changeSetName),
+	}
+
+	for {
+		resp, err := a.cloudformationClient.DescribeChangeSet(describeParams)
+		if err != nil {
+			return nil, err
+		}
+
+		switch aws.StringValue(resp.Status) {
+		case cloudformation.ChangeSetStatusCreateComplete:
+			for _, change := range resp.Changes {
+				if change.ResourceChange == nil {
+					continue
+				}
+				result.Changes = append(result.Changes, &ResourceChange{
+					Action:       aws.StringValue(change.ResourceChange.Action),
+					LogicalID:    aws.StringValue(change.ResourceChange.LogicalResourceId),
+					ResourceType: aws.StringValue(change.ResourceChange.ResourceType),
+					Replacement:  aws.StringValue(change.ResourceChange.Replacement),
+				})
+			}
+
+			if resp.NextToken == nil {
+				return result, nil
+			}
+			describeParams.NextToken = resp.NextToken
+			continue
+		case cloudformation.ChangeSetStatusFailed:
+			// change sets without any changes are reported as failed
+			if strings.Contains(aws.StringValue(resp.StatusReason), cloudformationNoChangesMsg) {
+				result.Action = StackActionNone
+				return result, nil
+			}
+			return nil, fmt.Errorf("failed to create change set for stack %s: %s", stackName, aws.StringValue(resp.StatusReason))
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, errTimeoutExceeded
+		case <-time.After(changeSetWaitTime):
+		}
+	}
+}
+
 func (a *awsAdapter) getStackByName(stackName string) (*cloudformation.Stack, error) {
 	params := &cloudformation.DescribeStacksInput{
 		StackName: aws.String(stackName),
@@ -413,13 +558,36 @@ func (a *awsAdapter) DeleteStack(parentCtx context.Context, stackName string) er
 		if isDoesNotExistsErr(err) {
 			return nil
 		}
-		return err
+		return newStackError(stackName, err)
 	}
 	return nil
 }
 
 // CreateOrUpdateEtcdStack creates or updates an etcd stack.
 func (a *awsAdapter) CreateOrUpdateEtcdStack(parentCtx context.Context, stackName, stackDefinitionPath, networkCIDR, vpcID string, cluster *api.Cluster) error {
+	template, err := a.etcdStackTemplate(stackDefinitionPath, networkCIDR, vpcID, cluster)
+	if err != nil {
+		return err
+	}
+
+	err = a.applyStack(stackName, template, "", nil, false)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(parentCtx, maxWaitTimeout)
+	defer cancel()
+	err = a.waitForStack(ctx, waitTime, stackName)
+	if err != nil {
+		return newStackError(stackName, err)
+	}
+
+	return nil
+}
+
+// etcdStackTemplate renders the CloudFormation template of the etcd stack
+// with senza.
+func (a *awsAdapter) etcdStackTemplate(stackDefinitionPath, networkCIDR, vpcID string, cluster *api.Cluster) (string, error) {
 	bucketName := fmt.Sprintf("zalando-kubernetes-etcd-%s-%s", getAWSAccountID(cluster.InfrastructureAccount), cluster.Region)
 
 	if bucket, ok := cluster.ConfigItems[etcdS3BackupBucketKey]; ok {
@@ -428,7 +596,7 @@ func (a *awsAdapter) CreateOrUpdateEtcdStack(parentCtx context.Context, stackNam
 
 	hostedZone, err := getHostedZone(cluster.APIServerURL)
 	if err != nil {
-		return err
+		return "", err
 	}
 
 	args := []string{
@@ -460,7 +628,7 @@ func (a *awsAdapter) CreateOrUpdateEtcdStack(parentCtx context.Context, stackNam
 
 	enVars, err := a.getEnvVars()
 	if err != nil {
-		return err
+		return "", err
 	}
 
 	cmd.Env = enVars
@@ -468,24 +636,12 @@ func (a *awsAdapter) CreateOrUpdateEtcdStack(parentCtx context.Context, stackNam
 	output, err := cmd.Output()
 	if err != nil {
 		if exitErr, ok := err.(*exec.ExitError); ok {
-			return fmt.Errorf("%v: %s", err, string(exitErr.Stderr))
+			return "", fmt.Errorf("%v: %s", err, string(exitErr.Stderr))
 		}
-		return err
-	}
-
-	err = a.applyStack(stackName, string(output), "", nil, false)
-	if err != nil {
-		return err
-	}
-
-	ctx, cancel := context.WithTimeout(parentCtx, maxWaitTimeout)
-	defer cancel()
-	err = a.waitForStack(ctx, waitTime, stackName)
-	if err != nil {
-		return err
+		return "", err
 	}
 
-	return nil
+	return string(output), nil
 }
 
 // createS3Bucket creates an s3 bucket if it doesn't exist.
@@ -35,6 +35,7 @@ type cloudFormationAPIStub struct {
 	createErr           error
 	updateErr           error
 	deleteErr           error
+	changeSet           *cloudformation.DescribeChangeSetOutput
 }
 
 func (c *cloudFormationAPIStub) DescribeStacks(input *cloudformation.DescribeStacksInput) (*cloudformation.DescribeStacksOutput, error) {
@@ -66,6 +67,18 @@ func (c *cloudFormationAPIStub) DescribeStacksPages(input *cloudformation.Descri
 	return nil
 }
 
+func (c *cloudFormationAPIStub) CreateChangeSet(input *cloudformation.CreateChangeSetInput) (*cloudformation.CreateChangeSetOutput, error) {
+	return nil, nil
+}
+
+func (c *cloudFormationAPIStub) DescribeChangeSet(input *cloudformation.DescribeChangeSetInput) (*cloudformation.DescribeChangeSetOutput, error) {
+	return c.changeSet, nil
+}
+
+func (c *cloudFormationAPIStub) DeleteChangeSet(input *cloudformation.DeleteChangeSetInput) (*cloudformation.DeleteChangeSetOutput, error) {
+	return nil, nil
+}
+
 func (c *cloudFormationAPIStub) setStatus(status string) {
 	c.statusMutex.Lock()
 	c.status = &status
@@ -199,6 +212,79 @@ func testWaitForStackWithRollback(t *testing.T) {
 	}
 }
 
+func TestPlanStack(t *testing.T) {
+	for _, tc := range []struct {
+		msg       string
+		changeSet *cloudformation.DescribeChangeSetOutput
+		action    string
+		changes   int
+		success   bool
+	}{
+		{
+			msg: "resources changed",
+			changeSet: &cloudformation.DescribeChangeSetOutput{
+				Status: aws.String(cloudformation.ChangeSetStatusCreateComplete),
+				Changes: []*cloudformation.Change{
+					{
+						ResourceChange: &cloudformation.ResourceChange{
+							Action:            aws.String(cloudformation.ChangeActionModify),
+							LogicalResourceId: aws.String("AutoScalingGroup"),
+							ResourceType:      aws.String("AWS::AutoScaling::AutoScalingGroup"),
+							Replacement:       aws.String(cloudformation.ReplacementFalse),
+						},
+					},
+				},
+			},
+			action:  StackActionUpdate,
+			changes: 1,
+			success: true,
+		},
+		{
+			msg: "no changes",
+			changeSet: &cloudformation.DescribeChangeSetOutput{
+				Status:       aws.String(cloudformation.ChangeSetStatusFailed),
+				StatusReason: aws.String("The submitted information didn't contain changes."),
+			},
+			action:  StackActionNone,
+			success: true,
+		},
+		{
+			msg: "change set failed",
+			changeSet: &cloudformation.DescribeChangeSetOutput{
+				Status:       aws.String(cloudformation.ChangeSetStatusFailed),
+				StatusReason: aws.String("Template format error"),
+			},
+			success: false,
+		},
+	} {
+		t.Run(tc.msg, func(t *testing.T) {
+			a := newAWSAdapterWithStubs(cloudformation.StackStatusUpdateComplete, "123")
+			a.cloudformationClient.(*cloudFormationAPIStub).changeSet = tc.changeSet
+
+			change, err := a.planStack(context.Background(), "foobar", "template", "", nil)
+			if !tc.success {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, "foobar", change.Name)
+			assert.Equal(t, tc.action, change.Action)
+			assert.Len(t, change.Changes, tc.changes)
+		})
+	}
+}
+
+func TestPlanStackCreation(t *testing.T) {
+	a := newAWSAdapterWithStubs(cloudformation.StackStatusUpdateComplete, "123")
+
+	// existing stacks aren't updated
+	change, err := a.planStackCreation("foobar")
+	assert.NoError(t, err)
+	assert.Equal(t, "foobar", change.Name)
+	assert.Equal(t, StackActionNone, change.Action)
+	assert.Empty(t, change.Changes)
+}
+
 func TestGetStackByName(t *testing.T) {
 	a := newAWSAdapterWithStubs("", "GroupName")
 	s, err := a.getStackByName("foobar")
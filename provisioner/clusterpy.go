@@ -11,6 +11,7 @@ import (
 	"path"
 	"sort"
 	"strings"
+	"syscall"
 	"time"
 	"unicode"
 
@@ -28,6 +29,7 @@ import (
 	"github.com/cenkalti/backoff"
 	"github.com/pkg/errors"
 	log "github.com/sirupsen/logrus"
+	"k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
 	"github.com/zalando-incubator/cluster-lifecycle-manager/api"
@@ -35,12 +37,16 @@ import (
 	"github.com/zalando-incubator/cluster-lifecycle-manager/config"
 	awsUtils "github.com/zalando-incubator/cluster-lifecycle-manager/pkg/aws"
 	"github.com/zalando-incubator/cluster-lifecycle-manager/pkg/kubernetes"
+	"github.com/zalando-incubator/cluster-lifecycle-manager/pkg/tracing"
 	"github.com/zalando-incubator/cluster-lifecycle-manager/pkg/updatestrategy"
 	"github.com/zalando-incubator/cluster-lifecycle-manager/pkg/util/command"
+	"github.com/zalando-incubator/cluster-lifecycle-manager/pkg/util/interrupt"
 )
 
 const (
 	providerID                     = "zalando-aws"
+	etcdStackName                  = "etcd-cluster-etcd"
+	healthCheckAPIServerTimeout    = 5 * time.Minute
 	manifestsPath                  = "cluster/manifests"
 	deletionsFile                  = "deletions.yaml"
 	clusterStackFileName           = "cluster.yaml"
@@ -59,6 +65,10 @@ const (
 	configKeyUpdateStrategy        = "update_strategy"
 	updateStrategyRolling          = "rolling"
 	defaultMaxRetryTime            = 5 * time.Minute
+
+	// reasons of the events recorded while applying the manifests
+	eventReasonApplyingManifests = "ApplyingManifests"
+	eventReasonApplyFailed       = "ApplyFailed"
 )
 
 type clusterpyProvisioner struct {
@@ -106,7 +116,7 @@ func (p *clusterpyProvisioner) updateDefaults(cluster *api.Cluster, channelConfi
 		if os.IsNotExist(err) {
 			return nil
 		}
-		return err
+		return newTemplateError(defaultsFile, err)
 	}
 
 	var defaults map[string]string
@@ -141,34 +151,29 @@ func (p *clusterpyProvisioner) decryptConfigItems(cluster *api.Cluster) error {
 
 // Provision provisions/updates a cluster on AWS. Provision is an idempotent
 // operation for the same input.
-func (p *clusterpyProvisioner) Provision(ctx context.Context, logger *log.Entry, cluster *api.Cluster, channelConfig *channel.Config) error {
-	awsAdapter, updater, nodePoolManager, err := p.prepareProvision(logger, cluster, channelConfig)
+func (p *clusterpyProvisioner) Provision(ctx context.Context, logger *log.Entry, cluster *api.Cluster, channelConfig *channel.Config) (err error) {
+	ctx, span := tracing.Start(ctx, "provision", "cluster", cluster.ID)
+	defer func() { span.End(err) }()
+
+	awsAdapter, updater, nodePoolManager, err := p.prepareProvision(ctx, logger, cluster, channelConfig)
 	if err != nil {
 		return err
 	}
 
-	// get VPC information
-	var vpc *ec2.Vpc
-	vpcID, ok := cluster.ConfigItems[vpcIDConfigItemKey]
-	if !ok { // if vpcID is not defined, autodiscover it
-		vpc, err = awsAdapter.GetDefaultVPC()
-		if err != nil {
-			return err
-		}
-		vpcID = aws.StringValue(vpc.VpcId)
-		cluster.ConfigItems[vpcIDConfigItemKey] = vpcID
-	} else {
-		vpc, err = awsAdapter.GetVPC(vpcID)
-		if err != nil {
-			return err
-		}
+	_, phase := tracing.Start(ctx, "vpc")
+	vpc, err := getVPC(awsAdapter, cluster)
+	phase.End(err)
+	if err != nil {
+		return err
 	}
 
 	if err = ctx.Err(); err != nil {
 		return err
 	}
 
-	err = p.tagSubnets(awsAdapter, vpcID, cluster)
+	_, phase = tracing.Start(ctx, "tag-subnets")
+	err = p.tagSubnets(awsAdapter, aws.StringValue(vpc.VpcId), cluster)
+	phase.End(err)
 	if err != nil {
 		return err
 	}
@@ -177,7 +182,24 @@ func (p *clusterpyProvisioner) Provision(ctx context.Context, logger *log.Entry,
 		return err
 	}
 
-	subnets, err := awsAdapter.GetSubnets(vpcID)
+	values, err := clusterValues(awsAdapter, cluster, vpc)
+	if err != nil {
+		return err
+	}
+
+	// render the manifests to find out if they're valid
+	manifestsPath := path.Join(channelConfig.Path, manifestsPath)
+	manifests, err := p.renderManifests(cluster, manifestsPath)
+	if err != nil {
+		return err
+	}
+
+	// create etcd stack if needed.
+	etcdStackDefinitionPath := path.Join(channelConfig.Path, "cluster", "etcd-cluster.yaml")
+
+	phaseCtx, phase := tracing.Start(ctx, "etcd-stack")
+	err = awsAdapter.CreateOrUpdateEtcdStack(phaseCtx, etcdStackName, etcdStackDefinitionPath, aws.StringValue(vpc.CidrBlock), aws.StringValue(vpc.VpcId), cluster)
+	phase.End(err)
 	if err != nil {
 		return err
 	}
@@ -186,11 +208,144 @@ func (p *clusterpyProvisioner) Provision(ctx context.Context, logger *log.Entry,
 		return err
 	}
 
+	cfgBasePath := path.Join(channelConfig.Path, "cluster")
+
+	bucketName := clusterBucketName(cluster)
+
+	phaseCtx, phase = tracing.Start(ctx, "cluster-stack")
+	err = createOrUpdateClusterStack(awsAdapter, phaseCtx, cfgBasePath, cluster, values, bucketName)
+	phase.End(err)
+	if err != nil {
+		return err
+	}
+
+	if err = ctx.Err(); err != nil {
+		return err
+	}
+
+	cfgNodePoolBaseDir := path.Join(cfgBasePath, "node-pools")
+
+	// provision node pools
+	nodePoolProvisioner := &AWSNodePoolProvisioner{
+		awsAdapter:      awsAdapter,
+		nodePoolManager: nodePoolManager,
+		bucketName:      bucketName,
+		cfgBaseDir:      cfgNodePoolBaseDir,
+		Cluster:         cluster,
+		logger:          logger,
+	}
+
+	phaseCtx, phase = tracing.Start(ctx, "node-pool-stacks")
+	err = nodePoolProvisioner.Provision(phaseCtx, values)
+	phase.End(err)
+	if err != nil {
+		return err
+	}
+
+	// wait for API server to be ready
+	_, phase = tracing.Start(ctx, "wait-for-apiserver")
+	err = waitForAPIServer(logger, cluster.APIServerURL, 15*time.Minute)
+	phase.End(err)
+	if err != nil {
+		return err
+	}
+
+	// safe point: the remaining steps are repeated when the update is
+	// resumed
+	if err = interrupt.Check(ctx); err != nil {
+		return err
+	}
+
+	if p.updatesNodePools(cluster) {
+		// update nodes
+		nodePools := cluster.NodePools
+
+		sort.Sort(api.NodePools(nodePools))
+		for _, nodePool := range nodePools {
+			updateCtx, phase := tracing.Start(ctx, "rolling-update", "node_pool", nodePool.Name)
+			err := updateNodePool(updateCtx, updater, nodePool, p.updateStrategy.Timeout)
+			phase.End(err)
+			if err != nil {
+				return err
+			}
+
+			if err = interrupt.Check(ctx); err != nil {
+				return err
+			}
+		}
+	}
+
+	// clean up removed node pools
+	phaseCtx, phase = tracing.Start(ctx, "reconcile-node-pools")
+	err = nodePoolProvisioner.Reconcile(phaseCtx)
+	phase.End(err)
+	if err != nil {
+		return err
+	}
+
+	if err = ctx.Err(); err != nil {
+		return err
+	}
+
+	_, phase = tracing.Start(ctx, "apply-manifests")
+	err = p.apply(logger, cluster, manifestsPath, manifests)
+	phase.End(err)
+	return err
+}
+
+// updatesNodePools returns true if provisioning the cluster rolls the nodes
+// of its node pools.
+func (p *clusterpyProvisioner) updatesNodePools(cluster *api.Cluster) bool {
+	if p.applyOnly {
+		return false
+	}
+
+	switch cluster.LifecycleStatus {
+	case models.ClusterLifecycleStatusRequested, models.ClusterUpdateLifecycleStatusCreating:
+		log.Warnf("New cluster (%s), skipping node pool update", cluster.LifecycleStatus)
+		return false
+	default:
+		return true
+	}
+}
+
+// clusterBucketName returns the name of the S3 bucket used for storing
+// templates and user data of a cluster. The AWS account ID is part of the
+// name to ensure uniqueness across accounts.
+func clusterBucketName(cluster *api.Cluster) string {
+	return fmt.Sprintf(clmCFBucketPattern, strings.TrimPrefix(cluster.InfrastructureAccount, "aws:"), cluster.Region)
+}
+
+// getVPC returns the VPC of a cluster. If the VPC is not defined in the config
+// items the default VPC is used.
+func getVPC(awsAdapter *awsAdapter, cluster *api.Cluster) (*ec2.Vpc, error) {
+	vpcID, ok := cluster.ConfigItems[vpcIDConfigItemKey]
+	if ok {
+		return awsAdapter.GetVPC(vpcID)
+	}
+
+	// if vpcID is not defined, autodiscover it
+	vpc, err := awsAdapter.GetDefaultVPC()
+	if err != nil {
+		return nil, err
+	}
+	cluster.ConfigItems[vpcIDConfigItemKey] = aws.StringValue(vpc.VpcId)
+	return vpc, nil
+}
+
+// clusterValues returns the values passed to the cluster and node pool stack
+// templates.
+func clusterValues(awsAdapter *awsAdapter, cluster *api.Cluster, vpc *ec2.Vpc) (map[string]interface{}, error) {
+	subnets, err := awsAdapter.GetSubnets(aws.StringValue(vpc.VpcId))
+	if err != nil {
+		return nil, err
+	}
+
 	// if subnets are defined in the config items, filter the subnet list
 	if subnetIds, ok := cluster.ConfigItems[subnetsConfigItemKey]; ok {
 		subnets, err = filterSubnets(subnets, strings.Split(subnetIds, ","))
 		if err != nil {
-			return err
+			return nil, err
 		}
 	}
 
@@ -216,23 +371,23 @@ func (p *clusterpyProvisioner) Provision(ctx context.Context, logger *log.Entry,
 
 	apiURL, err := url.Parse(cluster.APIServerURL)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	// TODO: should this be done like this or via a config item?
 	hostedZone, err := getHostedZone(cluster.APIServerURL)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	certificates, err := awsAdapter.GetCertificates()
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	loadBalancerCert, err := certs.FindBestMatchingCertificate(certificates, apiURL.Host)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	values := map[string]interface{}{
@@ -246,100 +401,189 @@ func (p *clusterpyProvisioner) Provision(ctx context.Context, logger *log.Entry,
 		"vpc_ipv4_cidr":             aws.StringValue(vpc.CidrBlock),
 	}
 
-	// render the manifests to find out if they're valid
+	return values, nil
+}
+
+// Plan returns the changes Provision would make to a cluster without applying
+// any of them. Change sets are created for the cluster and node pool stacks
+// and deleted again, every manifest is diffed against the cluster with a
+// server side dry run if kubectl supports it. The etcd stack is only reported
+// if it would be created, as Provision never updates it.
+func (p *clusterpyProvisioner) Plan(ctx context.Context, logger *log.Entry, cluster *api.Cluster, channelConfig *channel.Config) (*Plan, error) {
+	awsAdapter, _, nodePoolManager, err := p.prepareProvision(ctx, logger, cluster, channelConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	vpc, err := getVPC(awsAdapter, cluster)
+	if err != nil {
+		return nil, err
+	}
+
+	values, err := clusterValues(awsAdapter, cluster, vpc)
+	if err != nil {
+		return nil, err
+	}
+
 	manifestsPath := path.Join(channelConfig.Path, manifestsPath)
 	manifests, err := p.renderManifests(cluster, manifestsPath)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	// create etcd stack if needed.
-	etcdStackDefinitionPath := path.Join(channelConfig.Path, "cluster", "etcd-cluster.yaml")
+	plan := &Plan{ClusterID: cluster.ID}
 
-	err = awsAdapter.CreateOrUpdateEtcdStack(ctx, "etcd-cluster-etcd", etcdStackDefinitionPath, aws.StringValue(vpc.CidrBlock), aws.StringValue(vpc.VpcId), cluster)
+	// Provision only creates the etcd stack but never updates it
+	etcdChange, err := awsAdapter.planStackCreation(etcdStackName)
 	if err != nil {
-		return err
+		return nil, err
 	}
+	plan.Stacks = append(plan.Stacks, etcdChange)
 
-	if err = ctx.Err(); err != nil {
-		return err
+	cfgBasePath := path.Join(channelConfig.Path, "cluster")
+	bucketName := clusterBucketName(cluster)
+
+	clusterTemplate, err := clusterStackTemplate(cfgBasePath, cluster, values)
+	if err != nil {
+		return nil, err
 	}
 
-	cfgBasePath := path.Join(channelConfig.Path, "cluster")
+	clusterChange, err := awsAdapter.planClusterStack(ctx, cluster.LocalID, clusterTemplate, cluster, bucketName)
+	if err != nil {
+		return nil, err
+	}
+	plan.Stacks = append(plan.Stacks, clusterChange)
 
-	// create bucket name with aws account ID to ensure uniqueness across
-	// accounts.
-	bucketName := fmt.Sprintf(clmCFBucketPattern, strings.TrimPrefix(cluster.InfrastructureAccount, "aws:"), cluster.Region)
+	nodePoolProvisioner := &AWSNodePoolProvisioner{
+		awsAdapter:         awsAdapter,
+		nodePoolManager:    nodePoolManager,
+		bucketName:         bucketName,
+		cfgBaseDir:         path.Join(cfgBasePath, "node-pools"),
+		Cluster:            cluster,
+		logger:             logger,
+		skipUserDataUpload: true,
+	}
 
-	err = createOrUpdateClusterStack(awsAdapter, ctx, cfgBasePath, cluster, values, bucketName)
+	nodePoolChanges, err := nodePoolProvisioner.Plan(ctx, values)
 	if err != nil {
-		return err
+		return nil, err
 	}
+	plan.Stacks = append(plan.Stacks, nodePoolChanges...)
 
-	if err = ctx.Err(); err != nil {
-		return err
+	if p.updatesNodePools(cluster) {
+		plan.NodePools, err = planNodePoolUpdates(nodePoolManager, cluster, nodePoolChanges)
+		if err != nil {
+			return nil, err
+		}
 	}
 
-	cfgNodePoolBaseDir := path.Join(cfgBasePath, "node-pools")
+	if kubectlDiffAvailable() {
+		plan.Manifests, err = p.diffManifests(logger, cluster, manifests)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		plan.ManifestDiffUnavailable = true
+		for _, m := range manifests {
+			plan.Manifests = append(plan.Manifests, &ManifestChange{Name: m.name})
+		}
+	}
 
-	// provision node pools
-	nodePoolProvisioner := &AWSNodePoolProvisioner{
-		awsAdapter:      awsAdapter,
-		nodePoolManager: nodePoolManager,
-		bucketName:      bucketName,
-		cfgBaseDir:      cfgNodePoolBaseDir,
-		Cluster:         cluster,
-		logger:          logger,
+	deletions, err := parseDeletions(manifestsPath)
+	if err != nil {
+		return nil, err
 	}
 
-	err = nodePoolProvisioner.Provision(values)
+	for _, deletion := range append(deletions.PreApply, deletions.PostApply...) {
+		plan.Deletions = append(plan.Deletions, deletion.String())
+	}
+
+	return plan, nil
+}
+
+// HealthCheck checks that a provisioned cluster works: the API server must be
+// reachable and all nodes of its node pools must be ready.
+func (p *clusterpyProvisioner) HealthCheck(ctx context.Context, logger *log.Entry, cluster *api.Cluster, channelConfig *channel.Config) error {
+	_, _, nodePoolManager, err := p.prepareProvision(ctx, logger, cluster, channelConfig)
 	if err != nil {
 		return err
 	}
 
-	// wait for API server to be ready
-	err = waitForAPIServer(logger, cluster.APIServerURL, 15*time.Minute)
+	err = waitForAPIServer(logger, cluster.APIServerURL, healthCheckAPIServerTimeout)
 	if err != nil {
 		return err
 	}
 
-	if err = ctx.Err(); err != nil {
-		return err
+	for _, nodePool := range cluster.NodePools {
+		if err = ctx.Err(); err != nil {
+			return err
+		}
+
+		pool, err := nodePoolManager.GetPool(nodePool)
+		if err != nil {
+			return err
+		}
+
+		ready := len(pool.ReadyNodes())
+		if ready < len(pool.Nodes) {
+			return fmt.Errorf("only %d of %d nodes of node pool %s are ready", ready, len(pool.Nodes), nodePool.Name)
+		}
 	}
 
-	if !p.applyOnly {
-		switch cluster.LifecycleStatus {
-		case models.ClusterLifecycleStatusRequested, models.ClusterUpdateLifecycleStatusCreating:
-			log.Warnf("New cluster (%s), skipping node pool update", cluster.LifecycleStatus)
-		default:
-			// update nodes
-			nodePools := cluster.NodePools
+	return nil
+}
 
-			sort.Sort(api.NodePools(nodePools))
-			for _, nodePool := range nodePools {
-				err := updater.Update(ctx, nodePool)
-				if err != nil {
-					return err
-				}
+// planNodePoolUpdates returns the node pools which would be rolled by the
+// update strategy. A node pool is rolled if the launch configuration of its
+// stack changes or if it already has nodes of an older generation.
+func planNodePoolUpdates(nodePoolManager updatestrategy.NodePoolManager, cluster *api.Cluster, stackChanges []*StackChange) ([]*NodePoolChange, error) {
+	var result []*NodePoolChange
+
+	for _, nodePool := range cluster.NodePools {
+		stackName := nodePoolStackName(cluster, nodePool)
+
+		changed := false
+		for _, stackChange := range stackChanges {
+			if stackChange.Name != stackName {
+				continue
+			}
 
-				if err = ctx.Err(); err != nil {
-					return err
+			for _, change := range stackChange.Changes {
+				if change.ResourceType == launchConfigurationResourceType || change.ResourceType == launchTemplateResourceType {
+					changed = true
 				}
 			}
 		}
-	}
 
-	// clean up removed node pools
-	err = nodePoolProvisioner.Reconcile(ctx)
-	if err != nil {
-		return err
-	}
+		if changed {
+			result = append(result, &NodePoolChange{
+				Name:   nodePool.Name,
+				Reason: "launch configuration changes",
+			})
+			continue
+		}
 
-	if err = ctx.Err(); err != nil {
-		return err
+		pool, err := nodePoolManager.GetPool(nodePool)
+		if err != nil {
+			return nil, err
+		}
+
+		outdated := 0
+		for _, node := range pool.Nodes {
+			if node.Generation != pool.Generation {
+				outdated++
+			}
+		}
+
+		if outdated > 0 {
+			result = append(result, &NodePoolChange{
+				Name:   nodePool.Name,
+				Reason: fmt.Sprintf("%d of %d nodes are outdated", outdated, len(pool.Nodes)),
+			})
+		}
 	}
 
-	return p.apply(logger, cluster, manifestsPath, manifests)
+	return result, nil
 }
 
 type clusterStackParams struct {
@@ -347,7 +591,8 @@ type clusterStackParams struct {
 	Values  map[string]interface{}
 }
 
-func createOrUpdateClusterStack(awsAdapter *awsAdapter, ctx context.Context, baseDir string, cluster *api.Cluster, values map[string]interface{}, bucketName string) error {
+// clusterStackTemplate renders the template of the cluster stack.
+func clusterStackTemplate(baseDir string, cluster *api.Cluster, values map[string]interface{}) (string, error) {
 	params := &clusterStackParams{
 		Cluster: cluster,
 		Values:  values,
@@ -355,6 +600,14 @@ func createOrUpdateClusterStack(awsAdapter *awsAdapter, ctx context.Context, bas
 
 	stackFilePath := path.Join(baseDir, clusterStackFileName)
 	output, err := renderTemplate(newTemplateContext(baseDir), stackFilePath, params)
+	if err != nil {
+		return "", newTemplateError(stackFilePath, err)
+	}
+	return output, nil
+}
+
+func createOrUpdateClusterStack(awsAdapter *awsAdapter, ctx context.Context, baseDir string, cluster *api.Cluster, values map[string]interface{}, bucketName string) error {
+	output, err := clusterStackTemplate(baseDir, cluster, values)
 	if err != nil {
 		return err
 	}
@@ -368,7 +621,7 @@ func createOrUpdateClusterStack(awsAdapter *awsAdapter, ctx context.Context, bas
 	defer cancel()
 	err = awsAdapter.waitForStack(ctx, waitTime, cluster.LocalID)
 	if err != nil {
-		return err
+		return newStackError(cluster.LocalID, err)
 	}
 	return nil
 }
@@ -442,8 +695,12 @@ func selectSubnetIDs(subnets []*ec2.Subnet) map[string]string {
 }
 
 // Decommission decommissions a cluster provisioned in AWS.
-func (p *clusterpyProvisioner) Decommission(logger *log.Entry, cluster *api.Cluster, channelConfig *channel.Config) error {
-	awsAdapter, _, _, err := p.prepareProvision(logger, cluster, channelConfig)
+func (p *clusterpyProvisioner) Decommission(logger *log.Entry, cluster *api.Cluster, channelConfig *channel.Config) (err error) {
+	// we don't support cancelling decommission operations yet
+	ctx, span := tracing.Start(context.Background(), "decommission", "cluster", cluster.ID)
+	defer func() { span.End(err) }()
+
+	awsAdapter, _, _, err := p.prepareProvision(ctx, logger, cluster, channelConfig)
 	if err != nil {
 		return err
 	}
@@ -460,9 +717,6 @@ func (p *clusterpyProvisioner) Decommission(logger *log.Entry, cluster *api.Clus
 		logger.Errorf("Unable to downscale the deployments, proceeding anyway: %s", err)
 	}
 
-	// we don't support cancelling decommission operations yet
-	ctx := context.Background()
-
 	// delete all cluster infrastructure stacks
 	err = p.deleteClusterStacks(ctx, awsAdapter, cluster)
 	if err != nil {
@@ -555,14 +809,15 @@ func waitForAPIServer(logger *log.Entry, server string, maxTimeout time.Duration
 		time.Sleep(15 * time.Second)
 	}
 
-	return fmt.Errorf("'%s' was not ready after %s", server, maxTimeout.String())
+	return newAPIServerUnreachableError(server, fmt.Errorf("not ready after %s", maxTimeout.String()))
 }
 
 // prepareProvision checks that a cluster can be handled by the provisioner and
-// prepares to provision a cluster by initializing the aws adapter.
+// prepares to provision a cluster by initializing the aws adapter. AWS calls
+// are traced as children of the span of ctx.
 // TODO: this is doing a lot of things to glue everything together, this should
 // be refactored.
-func (p *clusterpyProvisioner) prepareProvision(logger *log.Entry, cluster *api.Cluster, channelConfig *channel.Config) (*awsAdapter, updatestrategy.UpdateStrategy, updatestrategy.NodePoolManager, error) {
+func (p *clusterpyProvisioner) prepareProvision(ctx context.Context, logger *log.Entry, cluster *api.Cluster, channelConfig *channel.Config) (*awsAdapter, updatestrategy.UpdateStrategy, updatestrategy.NodePoolManager, error) {
 	if cluster.Provider != providerID {
 		return nil, nil, nil, ErrProviderNotSupported
 	}
@@ -587,6 +842,7 @@ func (p *clusterpyProvisioner) prepareProvision(logger *log.Entry, cluster *api.
 	if err != nil {
 		return nil, nil, nil, err
 	}
+	sess = awsUtils.TraceSession(ctx, sess)
 
 	adapter, err := newAWSAdapter(logger, cluster.APIServerURL, cluster.Region, sess, p.tokenSource, p.dryRun)
 	if err != nil {
@@ -595,7 +851,7 @@ func (p *clusterpyProvisioner) prepareProvision(logger *log.Entry, cluster *api.
 
 	err = p.updateDefaults(cluster, channelConfig)
 	if err != nil {
-		return nil, nil, nil, fmt.Errorf("unable to read configuration defaults: %v", err)
+		return nil, nil, nil, errors.Wrap(err, "unable to read configuration defaults")
 	}
 
 	err = p.decryptConfigItems(cluster)
@@ -641,7 +897,7 @@ func (p *clusterpyProvisioner) prepareProvision(logger *log.Entry, cluster *api.
 
 		poolManager = updatestrategy.NewKubernetesNodePoolManager(logger, client, poolBackend, drainConfig)
 
-		updater = updatestrategy.NewRollingUpdateStrategy(logger, poolManager, 3)
+		updater = updatestrategy.NewRollingUpdateStrategy(logger, cluster.ID, poolManager, 3)
 	default:
 		return nil, nil, nil, fmt.Errorf("unknown update strategy: %s", p.updateStrategy)
 	}
@@ -825,6 +1081,14 @@ type resource struct {
 	Labels    labels `yaml:"labels"`
 }
 
+// String returns a human readable description of the resource.
+func (r *resource) String() string {
+	if r.Name != "" {
+		return fmt.Sprintf("%s %s/%s", r.Kind, r.Namespace, r.Name)
+	}
+	return fmt.Sprintf("%s in %s with labels %s", r.Kind, r.Namespace, r.Labels)
+}
+
 // deletions defines two list of resources to be deleted. One before applying
 // all manifests and one after applying all manifests.
 type deletions struct {
@@ -920,13 +1184,21 @@ func parseDeletions(manifestsPath string) (*deletions, error) {
 	return &deletions, nil
 }
 
-func (p *clusterpyProvisioner) renderManifests(cluster *api.Cluster, manifestsPath string) ([]string, error) {
+// manifest is a rendered manifest file.
+type manifest struct {
+	// name is the path of the manifest file relative to the manifests
+	// directory.
+	name    string
+	content string
+}
+
+func (p *clusterpyProvisioner) renderManifests(cluster *api.Cluster, manifestsPath string) ([]manifest, error) {
 	components, err := ioutil.ReadDir(manifestsPath)
 	if err != nil {
 		return nil, errors.Wrapf(err, "cannot read directory: %s", manifestsPath)
 	}
 
-	var result []string
+	var result []manifest
 	applyContext := newTemplateContext(manifestsPath)
 
 	for _, c := range components {
@@ -947,26 +1219,89 @@ func (p *clusterpyProvisioner) renderManifests(cluster *api.Cluster, manifestsPa
 
 		for _, f := range files {
 			file := path.Join(componentFolder, f.Name())
-			manifest, err := renderTemplate(applyContext, file, cluster)
+			name := path.Join(c.Name(), f.Name())
+			content, err := renderTemplate(applyContext, file, cluster)
 			if err != nil {
-				return nil, fmt.Errorf("error rendering template %s/%s: %v", c.Name(), f.Name(), err)
+				return nil, newTemplateError(name, err)
 			}
 
 			// If there's no content we skip the file.
-			if stripWhitespace(manifest) == "" {
+			if stripWhitespace(content) == "" {
 				log.Debugf("Skipping empty file: %s", file)
 				continue
 			}
 
-			result = append(result, manifest)
+			result = append(result, manifest{name: name, content: content})
 		}
 	}
 
 	return result, nil
 }
 
+// diffManifests returns the changes applying the manifests would make to the
+// resources in the cluster. kubectl diff uses a server side dry run so the
+// result includes defaulting and mutations done by admission controllers.
+// Manifests without changes are omitted.
+func (p *clusterpyProvisioner) diffManifests(logger *log.Entry, cluster *api.Cluster, manifests []manifest) ([]*ManifestChange, error) {
+	token, err := p.tokenSource.Token()
+	if err != nil {
+		return nil, errors.Wrapf(err, "no valid token")
+	}
+
+	var result []*ManifestChange
+	for _, m := range manifests {
+		cmd := exec.Command(
+			"kubectl",
+			"diff",
+			fmt.Sprintf("--server=%s", cluster.APIServerURL),
+			fmt.Sprintf("--token=%s", token.AccessToken),
+			"-f",
+			"-",
+		)
+		// prevent kubectl to find the in-cluster config
+		cmd.Env = []string{}
+		cmd.Stdin = strings.NewReader(m.content)
+
+		// kubectl diff exits with 1 if there are differences
+		out, err := cmd.Output()
+		if err != nil {
+			exitErr, ok := err.(*exec.ExitError)
+			if !ok || !kubectlDiffFound(exitErr) {
+				if ok {
+					err = fmt.Errorf("%v: %s", err, string(exitErr.Stderr))
+				}
+				return nil, newApplyError(m.name, err)
+			}
+		}
+
+		if len(out) > 0 {
+			result = append(result, &ManifestChange{
+				Name: m.name,
+				Diff: strings.TrimRight(string(out), "\n"),
+			})
+		}
+	}
+
+	return result, nil
+}
+
+// kubectlDiffAvailable returns true if the installed kubectl supports kubectl
+// diff, which was added in Kubernetes 1.13.
+func kubectlDiffAvailable() bool {
+	cmd := exec.Command("kubectl", "diff", "--help")
+	cmd.Env = []string{}
+	return cmd.Run() == nil
+}
+
+// kubectlDiffFound returns true if kubectl diff exited because it found
+// differences rather than because of an error.
+func kubectlDiffFound(exitErr *exec.ExitError) bool {
+	status, ok := exitErr.Sys().(syscall.WaitStatus)
+	return ok && status.ExitStatus() == 1
+}
+
 // apply runs pre-apply deletions, applies pre-rendered manifests and then runs post-apply deletions
-func (p *clusterpyProvisioner) apply(logger *log.Entry, cluster *api.Cluster, manifestsPath string, renderedManifests []string) error {
+func (p *clusterpyProvisioner) apply(logger *log.Entry, cluster *api.Cluster, manifestsPath string, renderedManifests []manifest) error {
 	logger.Debugf("Checking for deletions.yaml")
 	deletions, err := parseDeletions(manifestsPath)
 	if err != nil {
@@ -991,6 +1326,9 @@ func (p *clusterpyProvisioner) apply(logger *log.Entry, cluster *api.Cluster, ma
 		return errors.Wrapf(err, "no valid token")
 	}
 
+	events := p.eventRecorder(logger, cluster)
+	events.Eventf(kubernetes.NamespaceReference(metav1.NamespaceSystem), v1.EventTypeNormal, eventReasonApplyingManifests, "Applying %d manifests for a cluster update", len(renderedManifests))
+
 	for _, m := range renderedManifests {
 		args := []string{
 			"kubectl",
@@ -1013,13 +1351,14 @@ func (p *clusterpyProvisioner) apply(logger *log.Entry, cluster *api.Cluster, ma
 		} else {
 			applyManifest := func() error {
 				cmd := newApplyCommand()
-				cmd.Stdin = strings.NewReader(m)
+				cmd.Stdin = strings.NewReader(m.content)
 				_, err := command.Run(logger, cmd)
 				return err
 			}
 			err = backoff.Retry(applyManifest, backoff.WithMaxRetries(backoff.NewExponentialBackOff(), maxApplyRetries))
 			if err != nil {
-				return errors.Wrapf(err, "run kubectl failed")
+				events.Eventf(kubernetes.NamespaceReference(metav1.NamespaceSystem), v1.EventTypeWarning, eventReasonApplyFailed, "Failed to apply manifest %s: %v", m.name, err)
+				return newApplyError(m.name, err)
 			}
 		}
 	}
@@ -1033,6 +1372,21 @@ func (p *clusterpyProvisioner) apply(logger *log.Entry, cluster *api.Cluster, ma
 	return nil
 }
 
+// eventRecorder returns a recorder for the Kubernetes events of a cluster.
+// No events are recorded in dry run mode or if the client can't be set up.
+func (p *clusterpyProvisioner) eventRecorder(logger *log.Entry, cluster *api.Cluster) *kubernetes.EventRecorder {
+	if p.dryRun {
+		return nil
+	}
+
+	client, err := kubernetes.NewKubeClientWithTokenSource(cluster.APIServerURL, p.tokenSource)
+	if err != nil {
+		logger.Warnf("Unable to record Kubernetes events: %v", err)
+		return nil
+	}
+	return kubernetes.NewEventRecorder(logger, client)
+}
+
 func stripWhitespace(content string) string {
 	return strings.Map(func(r rune) rune {
 		if unicode.IsSpace(r) {
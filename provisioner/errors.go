@@ -0,0 +1,185 @@
+package provisioner
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/zalando-incubator/cluster-lifecycle-manager/api"
+)
+
+// throttlingErrCodes are the error codes returned by the AWS APIs when
+// requests are throttled.
+var throttlingErrCodes = map[string]bool{
+	"Throttling":                             true,
+	"ThrottlingException":                    true,
+	"ThrottledException":                     true,
+	"RequestThrottledException":              true,
+	"TooManyRequestsException":               true,
+	"ProvisionedThroughputExceededException": true,
+	"RequestLimitExceeded":                   true,
+	"RequestThrottled":                       true,
+	"SlowDown":                               true,
+}
+
+const (
+	errTypePrefix = "https://cluster-lifecycle-manager.zalando.org/problems/"
+
+	// ErrTypeStackFailed is the problem type of a CloudFormation stack
+	// which failed to be created, updated or deleted.
+	ErrTypeStackFailed = errTypePrefix + "cloudformation-stack-failed"
+	// ErrTypeDrainTimeout is the problem type of a node pool update which
+	// didn't finish in time, e.g. because nodes couldn't be drained.
+	ErrTypeDrainTimeout = errTypePrefix + "drain-timeout"
+	// ErrTypeApplyFailed is the problem type of a manifest which couldn't
+	// be applied with kubectl.
+	ErrTypeApplyFailed = errTypePrefix + "kubectl-apply-failed"
+	// ErrTypeTemplateRender is the problem type of a template which
+	// couldn't be rendered.
+	ErrTypeTemplateRender = errTypePrefix + "template-render-failed"
+	// ErrTypeAWSThrottling is the problem type of an AWS API call which
+	// was throttled.
+	ErrTypeAWSThrottling = errTypePrefix + "aws-throttling"
+	// ErrTypeAPIServerUnreachable is the problem type of a cluster API
+	// server which couldn't be reached.
+	ErrTypeAPIServerUnreachable = errTypePrefix + "api-server-unreachable"
+)
+
+// Error is an error of a known category returned by a provisioner. It
+// carries enough information to be reported as an api.Problem.
+type Error struct {
+	// Type is the problem type of the error.
+	Type string
+	// Title is a short summary of the error which doesn't change between
+	// occurrences of the same type.
+	Title string
+	// Instance identifies the resource which caused the error, e.g. the
+	// name of a stack or a manifest.
+	Instance string
+	// Status is the HTTP status code which best describes the error.
+	Status int32
+	// Err is the underlying error.
+	Err error
+}
+
+func (e *Error) Error() string {
+	if e.Instance == "" {
+		return fmt.Sprintf("%s: %v", e.Title, e.Err)
+	}
+	return fmt.Sprintf("%s (%s): %v", e.Title, e.Instance, e.Err)
+}
+
+// Cause returns the underlying error. It makes Error compatible with
+// errors.Cause from github.com/pkg/errors.
+func (e *Error) Cause() error {
+	return e.Err
+}
+
+// Problem returns the error as an api.Problem.
+func (e *Error) Problem() *api.Problem {
+	return &api.Problem{
+		Type:     e.Type,
+		Title:    e.Title,
+		Detail:   e.Err.Error(),
+		Instance: e.Instance,
+		Status:   e.Status,
+	}
+}
+
+func newStackError(stackName string, err error) error {
+	return &Error{
+		Type:     ErrTypeStackFailed,
+		Title:    "CloudFormation stack failed",
+		Instance: stackName,
+		Status:   http.StatusInternalServerError,
+		Err:      err,
+	}
+}
+
+func newDrainTimeoutError(nodePool string, err error) error {
+	return &Error{
+		Type:     ErrTypeDrainTimeout,
+		Title:    "node pool update timed out",
+		Instance: nodePool,
+		Status:   http.StatusGatewayTimeout,
+		Err:      err,
+	}
+}
+
+func newApplyError(manifest string, err error) error {
+	return &Error{
+		Type:     ErrTypeApplyFailed,
+		Title:    "kubectl apply failed",
+		Instance: manifest,
+		Status:   http.StatusInternalServerError,
+		Err:      err,
+	}
+}
+
+func newTemplateError(template string, err error) error {
+	return &Error{
+		Type:     ErrTypeTemplateRender,
+		Title:    "failed to render template",
+		Instance: template,
+		Status:   http.StatusUnprocessableEntity,
+		Err:      err,
+	}
+}
+
+func newAPIServerUnreachableError(server string, err error) error {
+	return &Error{
+		Type:     ErrTypeAPIServerUnreachable,
+		Title:    "API server unreachable",
+		Instance: server,
+		Status:   http.StatusServiceUnavailable,
+		Err:      err,
+	}
+}
+
+// Problem converts an error returned by a provisioner into an api.Problem.
+// Throttled AWS API calls are always reported as ErrTypeAWSThrottling since
+// they are usually the root cause of whatever operation failed. nil is
+// returned for errors of unknown category.
+func Problem(err error) *api.Problem {
+	var typed *Error
+	for err != nil {
+		if isThrottlingErr(err) {
+			problem := &api.Problem{
+				Type:   ErrTypeAWSThrottling,
+				Title:  "AWS API request throttled",
+				Detail: err.Error(),
+				Status: http.StatusTooManyRequests,
+			}
+			if typed != nil {
+				problem.Instance = typed.Instance
+			}
+			return problem
+		}
+
+		if e, ok := err.(*Error); ok && typed == nil {
+			typed = e
+		}
+
+		cause, ok := err.(interface {
+			Cause() error
+		})
+		if !ok {
+			break
+		}
+		err = cause.Cause()
+	}
+
+	if typed != nil {
+		return typed.Problem()
+	}
+	return nil
+}
+
+// isThrottlingErr returns true if the error is of type awserr.Error and
+// describes a throttled request.
+func isThrottlingErr(err error) bool {
+	if awsErr, ok := err.(awserr.Error); ok {
+		return throttlingErrCodes[awsErr.Code()]
+	}
+	return false
+}
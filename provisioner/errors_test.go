@@ -0,0 +1,82 @@
+package provisioner
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	pkgerrors "github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+	"github.com/zalando-incubator/cluster-lifecycle-manager/api"
+)
+
+func TestProblem(t *testing.T) {
+	cause := errors.New("wait for stack failed with ROLLBACK_COMPLETE")
+	throttled := awserr.New("Throttling", "Rate exceeded", nil)
+
+	for _, tc := range []struct {
+		msg      string
+		err      error
+		expected *api.Problem
+	}{
+		{
+			msg:      "unknown errors are not converted",
+			err:      errors.New("failed"),
+			expected: nil,
+		},
+		{
+			msg: "typed error",
+			err: newStackError("etcd-cluster-etcd", cause),
+			expected: &api.Problem{
+				Type:     ErrTypeStackFailed,
+				Title:    "CloudFormation stack failed",
+				Detail:   cause.Error(),
+				Instance: "etcd-cluster-etcd",
+				Status:   http.StatusInternalServerError,
+			},
+		},
+		{
+			msg: "wrapped typed error",
+			err: pkgerrors.Wrap(newTemplateError("kube-system/deployment.yaml", cause), "failed to render manifests"),
+			expected: &api.Problem{
+				Type:     ErrTypeTemplateRender,
+				Title:    "failed to render template",
+				Detail:   cause.Error(),
+				Instance: "kube-system/deployment.yaml",
+				Status:   http.StatusUnprocessableEntity,
+			},
+		},
+		{
+			msg: "throttling",
+			err: pkgerrors.Wrap(throttled, "failed to describe stacks"),
+			expected: &api.Problem{
+				Type:   ErrTypeAWSThrottling,
+				Title:  "AWS API request throttled",
+				Detail: throttled.Error(),
+				Status: http.StatusTooManyRequests,
+			},
+		},
+		{
+			msg: "throttling takes precedence over the typed error",
+			err: newStackError("etcd-cluster-etcd", throttled),
+			expected: &api.Problem{
+				Type:     ErrTypeAWSThrottling,
+				Title:    "AWS API request throttled",
+				Detail:   throttled.Error(),
+				Instance: "etcd-cluster-etcd",
+				Status:   http.StatusTooManyRequests,
+			},
+		},
+	} {
+		t.Run(tc.msg, func(t *testing.T) {
+			require.Equal(t, tc.expected, Problem(tc.err))
+		})
+	}
+}
+
+func TestErrorMessage(t *testing.T) {
+	err := newAPIServerUnreachableError("https://api.example.org", errors.New("not ready after 15m0s"))
+	require.Equal(t, "API server unreachable (https://api.example.org): not ready after 15m0s", err.Error())
+	require.Equal(t, "not ready after 15m0s", pkgerrors.Cause(err).Error())
+}
@@ -0,0 +1,29 @@
+package provisioner
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/zalando-incubator/cluster-lifecycle-manager/api"
+	"github.com/zalando-incubator/cluster-lifecycle-manager/pkg/updatestrategy"
+)
+
+// updateNodePool updates a single node pool with the update strategy. If
+// timeout is positive, the update is aborted once it takes longer and a drain
+// timeout error is returned. Cancellations and deadlines of ctx itself are
+// returned as they are.
+func updateNodePool(ctx context.Context, updater updatestrategy.UpdateStrategy, nodePool *api.NodePool, timeout time.Duration) error {
+	updateCtx := ctx
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		updateCtx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	err := updater.Update(updateCtx, nodePool)
+	if err != nil && errors.Cause(err) == context.DeadlineExceeded && ctx.Err() == nil {
+		return newDrainTimeoutError(nodePool.Name, err)
+	}
+	return err
+}
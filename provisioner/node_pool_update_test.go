@@ -0,0 +1,51 @@
+package provisioner
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/zalando-incubator/cluster-lifecycle-manager/api"
+)
+
+// blockingUpdateStrategy blocks until the context of the update is done.
+type blockingUpdateStrategy struct{}
+
+func (s *blockingUpdateStrategy) Update(ctx context.Context, nodePool *api.NodePool) error {
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func TestUpdateNodePoolTimeout(t *testing.T) {
+	nodePool := &api.NodePool{Name: "default-worker"}
+
+	err := updateNodePool(context.Background(), &blockingUpdateStrategy{}, nodePool, time.Millisecond)
+	require.Error(t, err)
+	problem := Problem(err)
+	require.NotNil(t, problem)
+	require.Equal(t, ErrTypeDrainTimeout, problem.Type)
+	require.Equal(t, "default-worker", problem.Instance)
+}
+
+func TestUpdateNodePoolCanceled(t *testing.T) {
+	nodePool := &api.NodePool{Name: "default-worker"}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		cancel()
+	}()
+
+	err := updateNodePool(ctx, &blockingUpdateStrategy{}, nodePool, time.Hour)
+	require.Equal(t, context.Canceled, err)
+	require.Nil(t, Problem(err))
+
+	// the deadline of the whole update isn't a node pool timeout
+	ctx, cancel = context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+
+	err = updateNodePool(ctx, &blockingUpdateStrategy{}, nodePool, time.Hour)
+	require.Equal(t, context.DeadlineExceeded, err)
+	require.Nil(t, Problem(err))
+}
@@ -19,6 +19,7 @@ import (
 	log "github.com/sirupsen/logrus"
 	"github.com/zalando-incubator/cluster-lifecycle-manager/api"
 	awsExt "github.com/zalando-incubator/cluster-lifecycle-manager/pkg/aws"
+	"github.com/zalando-incubator/cluster-lifecycle-manager/pkg/tracing"
 	"github.com/zalando-incubator/cluster-lifecycle-manager/pkg/updatestrategy"
 )
 
@@ -29,6 +30,9 @@ const (
 	nodePoolTagKey        = "kubernetes.io/node-pool"
 	nodePoolRoleTagKey    = "kubernetes.io/role/node-pool"
 	nodePoolProfileTagKey = "kubernetes.io/node-pool/profile"
+
+	launchConfigurationResourceType = "AWS::AutoScaling::LaunchConfiguration"
+	launchTemplateResourceType      = "AWS::EC2::LaunchTemplate"
 )
 
 // NodePoolProvisioner is able to provision node pools for a cluster.
@@ -47,6 +51,11 @@ type AWSNodePoolProvisioner struct {
 	cfgBaseDir      string
 	Cluster         *api.Cluster
 	logger          *log.Entry
+
+	// skipUserDataUpload skips uploading the user data to S3 when
+	// generating node pool stack templates. The S3 objects are named by the
+	// hash of their content, so the generated templates are the same.
+	skipUserDataUpload bool
 }
 
 // stackParams defined the parameters expected by a node pool stack template.
@@ -94,11 +103,16 @@ func (p *AWSNodePoolProvisioner) generateNodePoolStackTemplate(nodePool *api.Nod
 	}
 
 	stackFilePath := path.Join(nodePoolProfilesPath, stackFileName)
-	return renderTemplate(newTemplateContext(nodePoolProfilesPath), stackFilePath, params)
+	template, err := renderTemplate(newTemplateContext(nodePoolProfilesPath), stackFilePath, params)
+	if err != nil {
+		return "", newTemplateError(stackFilePath, err)
+	}
+	return template, nil
 }
 
-// Provision provisions node pools of the cluster.
-func (p *AWSNodePoolProvisioner) Provision(values map[string]interface{}) error {
+// Provision provisions node pools of the cluster. Provisioning every node
+// pool is traced as a child of the span of ctx.
+func (p *AWSNodePoolProvisioner) Provision(ctx context.Context, values map[string]interface{}) error {
 	// create S3 bucket if it doesn't exist
 	// the bucket is used for storing the ignition userdata for the node
 	// pools.
@@ -123,7 +137,9 @@ func (p *AWSNodePoolProvisioner) Provision(values map[string]interface{}) error
 		}
 
 		go func(nodePool api.NodePool, errorsc chan error) {
+			_, span := tracing.Start(ctx, "node-pool-stack", "node_pool", nodePool.Name)
 			err := p.provisionNodePool(&nodePool, poolValues)
+			span.End(err)
 			if err != nil {
 				err = fmt.Errorf("failed to provision node pool %s: %s", nodePool.Name, err)
 			}
@@ -148,6 +164,73 @@ func (p *AWSNodePoolProvisioner) Provision(values map[string]interface{}) error
 
 // provisionNodePool provisions a single node pool.
 func (p *AWSNodePoolProvisioner) provisionNodePool(nodePool *api.NodePool, values map[string]interface{}) error {
+	stackName, template, tags, err := p.nodePoolStack(nodePool, values)
+	if err != nil {
+		return err
+	}
+
+	err = p.awsAdapter.applyStack(stackName, template, "", tags, true)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), maxWaitTimeout)
+	defer cancel()
+	err = p.awsAdapter.waitForStack(ctx, waitTime, stackName)
+	if err != nil {
+		return newStackError(stackName, err)
+	}
+
+	return nil
+}
+
+// Plan returns the changes provisioning the node pools of the cluster would
+// make to the node pool stacks, including the stacks of node pools which
+// would be decommissioned.
+func (p *AWSNodePoolProvisioner) Plan(ctx context.Context, values map[string]interface{}) ([]*StackChange, error) {
+	var result []*StackChange
+
+	for _, nodePool := range p.Cluster.NodePools {
+		poolValuesCopy, err := copystructure.Copy(values)
+		if err != nil {
+			return nil, err
+		}
+
+		poolValues, ok := poolValuesCopy.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("unable to copy values for node pool %s", nodePool.Name)
+		}
+
+		stackName, template, tags, err := p.nodePoolStack(nodePool, poolValues)
+		if err != nil {
+			return nil, err
+		}
+
+		change, err := p.awsAdapter.planStack(ctx, stackName, template, "", tags)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, change)
+	}
+
+	orphaned, err := p.orphanedStacks()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, stack := range orphaned {
+		result = append(result, &StackChange{
+			Name:   aws.StringValue(stack.StackName),
+			Action: StackActionDelete,
+		})
+	}
+
+	return result, nil
+}
+
+// nodePoolStack returns the name, the template and the tags of the stack of
+// a node pool.
+func (p *AWSNodePoolProvisioner) nodePoolStack(nodePool *api.NodePool, values map[string]interface{}) (string, string, []*cloudformation.Tag, error) {
 	values["supports_t2_unlimited"] = strings.HasPrefix(nodePool.InstanceType, "t2")
 	values["spot_price"] = ""
 
@@ -157,26 +240,25 @@ func (p *AWSNodePoolProvisioner) provisionNodePool(nodePool *api.NodePool, value
 	case api.DiscountStrategySpot:
 		instanceInfo, err := awsExt.InstanceInfo(nodePool.InstanceType)
 		if err != nil {
-			return err
+			return "", "", nil, err
 		}
 
 		onDemandPrice, ok := instanceInfo.Pricing[p.Cluster.Region]
 		if !ok {
-			return fmt.Errorf("no price data for region %s, instance type %s", p.Cluster.Region, nodePool.InstanceType)
+			return "", "", nil, fmt.Errorf("no price data for region %s, instance type %s", p.Cluster.Region, nodePool.InstanceType)
 		}
 
 		values["spot_price"] = onDemandPrice
 	default:
-		return fmt.Errorf("unsupported node pool discount_strategy %s", nodePool.DiscountStrategy)
+		return "", "", nil, fmt.Errorf("unsupported node pool discount_strategy %s", nodePool.DiscountStrategy)
 	}
 
 	template, err := p.generateNodePoolStackTemplate(nodePool, values)
 	if err != nil {
-		return err
+		return "", "", nil, err
 	}
 
-	// TODO: stackname pattern
-	stackName := fmt.Sprintf("nodepool-%s-%s", nodePool.Name, strings.Replace(p.Cluster.ID, ":", "-", -1))
+	stackName := nodePoolStackName(p.Cluster, nodePool)
 
 	tags := []*cloudformation.Tag{
 		{
@@ -201,38 +283,18 @@ func (p *AWSNodePoolProvisioner) provisionNodePool(nodePool *api.NodePool, value
 		},
 	}
 
-	err = p.awsAdapter.applyStack(stackName, template, "", tags, true)
-	if err != nil {
-		return err
-	}
-
-	ctx, cancel := context.WithTimeout(context.Background(), maxWaitTimeout)
-	defer cancel()
-	err = p.awsAdapter.waitForStack(ctx, waitTime, stackName)
-	if err != nil {
-		return err
-	}
-
-	return nil
+	return stackName, template, tags, nil
 }
 
 // Reconcile finds all orphaned node pool stacks and decommission the node
 // pools by scaling them down gracefully and deleting the corresponding stacks.
 func (p *AWSNodePoolProvisioner) Reconcile(ctx context.Context) error {
 	// decommission orphaned node pools
-	tags := map[string]string{
-		tagNameKubernetesClusterPrefix + p.Cluster.ID: resourceLifecycleOwned,
-		nodePoolRoleTagKey:                            "true",
-	}
-
-	nodePoolStacks, err := p.awsAdapter.ListStacks(tags)
+	orphaned, err := p.orphanedStacks()
 	if err != nil {
 		return err
 	}
 
-	// find orphaned by comparing node pool stacks to node pools defined for cluster
-	orphaned := orphanedNodePoolStacks(nodePoolStacks, p.Cluster.NodePools)
-
 	if len(orphaned) > 0 {
 		p.logger.Infof("Found %d node pool stacks to decommission", len(orphaned))
 	}
@@ -256,13 +318,36 @@ func (p *AWSNodePoolProvisioner) Reconcile(ctx context.Context) error {
 	return nil
 }
 
+// nodePoolStackName returns the name of the stack of a node pool.
+// TODO: stackname pattern
+func nodePoolStackName(cluster *api.Cluster, nodePool *api.NodePool) string {
+	return fmt.Sprintf("nodepool-%s-%s", nodePool.Name, strings.Replace(cluster.ID, ":", "-", -1))
+}
+
+// orphanedStacks returns the node pool stacks of the cluster which don't
+// belong to any of the node pools defined for the cluster.
+func (p *AWSNodePoolProvisioner) orphanedStacks() ([]*cloudformation.Stack, error) {
+	tags := map[string]string{
+		tagNameKubernetesClusterPrefix + p.Cluster.ID: resourceLifecycleOwned,
+		nodePoolRoleTagKey:                            "true",
+	}
+
+	nodePoolStacks, err := p.awsAdapter.ListStacks(tags)
+	if err != nil {
+		return nil, err
+	}
+
+	// find orphaned by comparing node pool stacks to node pools defined for cluster
+	return orphanedNodePoolStacks(nodePoolStacks, p.Cluster.NodePools), nil
+}
+
 // prepareUserData prepares the user data by rendering the golang template
 // and uploading the User Data to S3. A EC2 UserData ready base64 string will
 // be returned.
 func (p *AWSNodePoolProvisioner) prepareUserData(basedir, clcPath string, config interface{}) (string, error) {
 	rendered, err := renderTemplate(newTemplateContext(basedir), clcPath, config)
 	if err != nil {
-		return "", err
+		return "", newTemplateError(clcPath, err)
 	}
 
 	// convert to ignition
@@ -296,6 +381,10 @@ func (p *AWSNodePoolProvisioner) uploadUserDataToS3(userData []byte, bucketName
 
 	objectName := fmt.Sprintf("%s.userdata", sha)
 
+	if p.skipUserDataUpload {
+		return fmt.Sprintf("s3://%s/%s", bucketName, objectName), nil
+	}
+
 	// Upload the stack template to S3
 	_, err = p.awsAdapter.s3Uploader.Upload(&s3manager.UploadInput{
 		Bucket: aws.String(bucketName),
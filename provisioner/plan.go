@@ -0,0 +1,130 @@
+package provisioner
+
+import (
+	"fmt"
+	"io"
+)
+
+const (
+	// StackActionCreate means that the stack doesn't exist yet and would
+	// be created.
+	StackActionCreate = "create"
+	// StackActionUpdate means that the stack exists and would be updated.
+	StackActionUpdate = "update"
+	// StackActionDelete means that the stack would be deleted.
+	StackActionDelete = "delete"
+	// StackActionNone means that the stack is up to date.
+	StackActionNone = "none"
+)
+
+// Plan describes the changes provisioning a cluster would make.
+type Plan struct {
+	ClusterID string
+	Stacks    []*StackChange
+	NodePools []*NodePoolChange
+	Manifests []*ManifestChange
+	// ManifestDiffUnavailable is set if the manifests couldn't be diffed
+	// against the cluster. Manifests then lists all manifests without a
+	// diff, as all of them would be applied.
+	ManifestDiffUnavailable bool
+	Deletions               []string
+}
+
+// StackChange describes how a CloudFormation stack would change.
+type StackChange struct {
+	Name    string
+	Action  string
+	Changes []*ResourceChange
+}
+
+// ResourceChange describes how a single resource of a CloudFormation stack
+// would change.
+type ResourceChange struct {
+	Action       string
+	LogicalID    string
+	ResourceType string
+	Replacement  string
+}
+
+// NodePoolChange describes a node pool which would be rolled.
+type NodePoolChange struct {
+	Name   string
+	Reason string
+}
+
+// ManifestChange describes how applying a manifest would change the
+// resources in the cluster.
+type ManifestChange struct {
+	Name string
+	Diff string
+}
+
+// Write writes a human readable description of the plan formatted as
+// markdown.
+func (p *Plan) Write(w io.Writer) error {
+	ew := &errWriter{w: w}
+
+	ew.printf("## Cluster %s\n\n", p.ClusterID)
+
+	ew.printf("### CloudFormation stacks\n\n")
+	if len(p.Stacks) == 0 {
+		ew.printf("No stacks.\n")
+	}
+	for _, stack := range p.Stacks {
+		ew.printf("* `%s`: %s\n", stack.Name, stack.Action)
+		for _, change := range stack.Changes {
+			ew.printf("  * %s `%s` (%s)", change.Action, change.LogicalID, change.ResourceType)
+			if change.Replacement != "" {
+				ew.printf(", replacement: %s", change.Replacement)
+			}
+			ew.printf("\n")
+		}
+	}
+
+	ew.printf("\n### Node pools to roll\n\n")
+	if len(p.NodePools) == 0 {
+		ew.printf("No node pools would be rolled.\n")
+	}
+	for _, nodePool := range p.NodePools {
+		ew.printf("* `%s`: %s\n", nodePool.Name, nodePool.Reason)
+	}
+
+	ew.printf("\n### Manifests\n\n")
+	if p.ManifestDiffUnavailable {
+		ew.printf("Diff unavailable, kubectl doesn't support kubectl diff. All manifests would be applied:\n\n")
+		for _, manifest := range p.Manifests {
+			ew.printf("* `%s`\n", manifest.Name)
+		}
+	} else {
+		if len(p.Manifests) == 0 {
+			ew.printf("No manifests would change.\n")
+		}
+		for _, manifest := range p.Manifests {
+			ew.printf("#### `%s`\n\n```diff\n%s\n```\n\n", manifest.Name, manifest.Diff)
+		}
+	}
+
+	ew.printf("\n### Deletions\n\n")
+	if len(p.Deletions) == 0 {
+		ew.printf("No resources would be deleted.\n")
+	}
+	for _, deletion := range p.Deletions {
+		ew.printf("* %s\n", deletion)
+	}
+
+	return ew.err
+}
+
+// errWriter is a writer which remembers the first error and skips all
+// writes after it.
+type errWriter struct {
+	w   io.Writer
+	err error
+}
+
+func (ew *errWriter) printf(format string, args ...interface{}) {
+	if ew.err != nil {
+		return
+	}
+	_, ew.err = fmt.Fprintf(ew.w, format, args...)
+}
@@ -0,0 +1,89 @@
+package provisioner
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPlanWrite(t *testing.T) {
+	plan := &Plan{
+		ClusterID: "aws:123456789012:eu-central-1:kube-1",
+		Stacks: []*StackChange{
+			{
+				Name:   "kube-1",
+				Action: StackActionUpdate,
+				Changes: []*ResourceChange{
+					{
+						Action:       "Modify",
+						LogicalID:    "MasterLoadBalancer",
+						ResourceType: "AWS::ElasticLoadBalancing::LoadBalancer",
+						Replacement:  "True",
+					},
+				},
+			},
+			{
+				Name:   "nodepool-default-worker-kube-1",
+				Action: StackActionNone,
+			},
+		},
+		NodePools: []*NodePoolChange{
+			{Name: "default-worker", Reason: "launch configuration changes"},
+		},
+		Manifests: []*ManifestChange{
+			{Name: "kube-proxy", Diff: "-image: kube-proxy:v1.9.6\n+image: kube-proxy:v1.9.7"},
+		},
+	}
+
+	buf := &bytes.Buffer{}
+	err := plan.Write(buf)
+	assert.NoError(t, err)
+
+	out := buf.String()
+	assert.Contains(t, out, "## Cluster aws:123456789012:eu-central-1:kube-1")
+	assert.Contains(t, out, "* `kube-1`: update\n")
+	assert.Contains(t, out, "  * Modify `MasterLoadBalancer` (AWS::ElasticLoadBalancing::LoadBalancer), replacement: True\n")
+	assert.Contains(t, out, "* `nodepool-default-worker-kube-1`: none\n")
+	assert.Contains(t, out, "* `default-worker`: launch configuration changes\n")
+	assert.Contains(t, out, "+image: kube-proxy:v1.9.7")
+	assert.Contains(t, out, "No resources would be deleted.")
+}
+
+func TestPlanWriteManifestDiffUnavailable(t *testing.T) {
+	plan := &Plan{
+		ClusterID: "aws:123456789012:eu-central-1:kube-1",
+		Manifests: []*ManifestChange{
+			{Name: "kube-proxy"},
+			{Name: "coredns"},
+		},
+		ManifestDiffUnavailable: true,
+	}
+
+	buf := &bytes.Buffer{}
+	err := plan.Write(buf)
+	assert.NoError(t, err)
+
+	out := buf.String()
+	assert.Contains(t, out, "Diff unavailable")
+	assert.Contains(t, out, "* `kube-proxy`\n")
+	assert.Contains(t, out, "* `coredns`\n")
+	assert.NotContains(t, out, "No manifests would change.")
+	assert.NotContains(t, out, "```diff")
+}
+
+type failingWriter struct {
+	writes int
+}
+
+func (w *failingWriter) Write(p []byte) (int, error) {
+	w.writes++
+	return 0, assert.AnError
+}
+
+func TestPlanWriteError(t *testing.T) {
+	w := &failingWriter{}
+	err := (&Plan{}).Write(w)
+	assert.Equal(t, assert.AnError, err)
+	assert.Equal(t, 1, w.writes)
+}
@@ -32,3 +32,15 @@ type Provisioner interface {
 	Provision(ctx context.Context, logger *log.Entry, cluster *api.Cluster, channelConfig *channel.Config) error
 	Decommission(logger *log.Entry, cluster *api.Cluster, channelConfig *channel.Config) error
 }
+
+// Planner is implemented by provisioners which can report the changes
+// provisioning a cluster would make without applying them.
+type Planner interface {
+	Plan(ctx context.Context, logger *log.Entry, cluster *api.Cluster, channelConfig *channel.Config) (*Plan, error)
+}
+
+// HealthChecker is implemented by provisioners which can verify that a
+// cluster works as expected after it has been provisioned.
+type HealthChecker interface {
+	HealthCheck(ctx context.Context, logger *log.Entry, cluster *api.Cluster, channelConfig *channel.Config) error
+}